@@ -33,6 +33,8 @@ func NewFramebuffer(width, height int) (*Framebuffer, error) {
 
 	// Check framebuffer completeness
 	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		framebuffer.Cleanup()
 		return nil, fmt.Errorf("Framebuffer incomplete: %x", status)
 	}
 
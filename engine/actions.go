@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"encoding/json"
+	"math"
+
+	remotevm "github.com/rolfrm/remotevm"
+	"github.com/supersdf-go/engine/save"
+	"github.com/supersdf-go/engine/vec2"
+)
+
+// BindingKind is the kind of input a binding reads.
+type BindingKind int
+
+const (
+	BindKey BindingKind = iota
+	BindMouseButton
+	// mouse movement in pixels during the frame.
+	BindMouseX
+	BindMouseY
+	BindScrollX
+	BindScrollY
+)
+
+// Binding connects an input to an action. Keys and buttons read 1 while held,
+// the value is multiplied by Scale.
+type Binding struct {
+	Kind  BindingKind
+	Code  int
+	Scale float32
+}
+
+func KeyBinding(key Key) Binding {
+	return Binding{Kind: BindKey, Code: int(key), Scale: 1}
+}
+
+func MouseButtonBinding(button MouseButton) Binding {
+	return Binding{Kind: BindMouseButton, Code: int(button), Scale: 1}
+}
+
+// AxisBinding binds mouse movement or scrolling, kind is one of BindMouseX, BindMouseY, BindScrollX or BindScrollY.
+func AxisBinding(kind BindingKind, scale float32) Binding {
+	return Binding{Kind: kind, Scale: scale}
+}
+
+// Axis2Binding is a composite of four directions, for example WASD.
+type Axis2Binding struct {
+	Up, Down, Left, Right []Binding
+}
+
+// InputMap maps named actions to rebindable inputs.
+type InputMap struct {
+	Events *EventManager
+	// DeadZone is the axis magnitude below which axes read zero.
+	DeadZone float32
+
+	buttons map[string][]Binding
+	axes    map[string][]Binding
+	axes2   map[string]Axis2Binding
+}
+
+func NewInputMap(events *EventManager) *InputMap {
+	return &InputMap{
+		Events:  events,
+		buttons: map[string][]Binding{},
+		axes:    map[string][]Binding{},
+		axes2:   map[string]Axis2Binding{},
+	}
+}
+
+// BindButton binds a digital action, like Jump.
+func (m *InputMap) BindButton(action string, bindings ...Binding) {
+	m.buttons[action] = bindings
+}
+
+// BindAxis binds an analog action. The bindings are summed.
+func (m *InputMap) BindAxis(action string, bindings ...Binding) {
+	m.axes[action] = bindings
+}
+
+// BindAxis2 binds a two dimensional action, like MoveForward/strafe on WASD.
+func (m *InputMap) BindAxis2(action string, binding Axis2Binding) {
+	m.axes2[action] = binding
+}
+
+// Bindings returns the bindings of a button or axis action.
+func (m *InputMap) Bindings(action string) []Binding {
+	if b, ok := m.buttons[action]; ok {
+		return b
+	}
+	return m.axes[action]
+}
+
+func (m *InputMap) value(b Binding) float32 {
+	var v float64
+	switch b.Kind {
+	case BindKey:
+		if m.Events.IsKeyDown(Key(b.Code)) {
+			v = 1
+		}
+	case BindMouseButton:
+		if m.Events.IsMouseButtonDown(MouseButton(b.Code)) {
+			v = 1
+		}
+	case BindMouseX:
+		v, _ = m.Events.MouseDelta()
+	case BindMouseY:
+		_, v = m.Events.MouseDelta()
+	case BindScrollX:
+		v, _ = m.Events.ScrollDelta()
+	case BindScrollY:
+		_, v = m.Events.ScrollDelta()
+	}
+	return float32(v) * b.Scale
+}
+
+func (m *InputMap) pressed(b Binding) bool {
+	switch b.Kind {
+	case BindKey:
+		return m.Events.WasKeyPressed(Key(b.Code))
+	case BindMouseButton:
+		return m.Events.WasMouseButtonPressed(MouseButton(b.Code))
+	}
+	return false
+}
+
+func (m *InputMap) sum(bindings []Binding) float32 {
+	var v float32
+	for _, b := range bindings {
+		v += m.value(b)
+	}
+	return v
+}
+
+// Down returns true while any input of the action is held.
+func (m *InputMap) Down(action string) bool {
+	for _, b := range m.buttons[action] {
+		if m.value(b) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Pressed returns true if a key or button of the action was pressed during the current frame.
+func (m *InputMap) Pressed(action string) bool {
+	for _, b := range m.buttons[action] {
+		if m.pressed(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// Picks returns the pick events of the current frame made with a mouse button of the action.
+func (m *InputMap) Picks(action string) []PickEvent {
+	var picks, out []PickEvent
+	m.Events.ReadPickEvents(&picks)
+	for _, p := range picks {
+		for _, b := range m.buttons[action] {
+			if b.Kind == BindMouseButton && MouseButton(b.Code) == p.Button {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// applyDeadZone zeroes magnitudes below the dead zone and rescales the rest so unit input stays unit.
+func (m *InputMap) applyDeadZone(magnitude float32) float32 {
+	if magnitude < m.DeadZone {
+		return 0
+	}
+	if magnitude > 1 || m.DeadZone >= 1 {
+		return magnitude
+	}
+	return (magnitude - m.DeadZone) / (1 - m.DeadZone)
+}
+
+// Axis returns the value of an analog action.
+func (m *InputMap) Axis(action string) float32 {
+	v := m.sum(m.axes[action])
+	if v < 0 {
+		return -m.applyDeadZone(-v)
+	}
+	return m.applyDeadZone(v)
+}
+
+// Axis2 returns the value of a composite action with a magnitude of at most 1, so diagonals are not faster.
+func (m *InputMap) Axis2(action string) vec2.Vec2 {
+	b := m.axes2[action]
+	x := m.sum(b.Right) - m.sum(b.Left)
+	y := m.sum(b.Up) - m.sum(b.Down)
+	length := float32(math.Sqrt(float64(x*x + y*y)))
+	if length == 0 {
+		return vec2.Vec2{}
+	}
+	scaled := m.applyDeadZone(min(length, 1))
+	return vec2.New(x/length*scaled, y/length*scaled)
+}
+
+// CaptureBinding returns a binding for the first key or mouse button pressed
+// during the current frame, for letting users pick a new binding.
+func CaptureBinding(events *EventManager) (Binding, bool) {
+	for _, e := range events.frame.keys {
+		if e.Action == Pressed {
+			return KeyBinding(e.KeyCode), true
+		}
+	}
+	for _, e := range events.frame.buttons {
+		if e.Action == Pressed {
+			return MouseButtonBinding(e.Button), true
+		}
+	}
+	return Binding{}, false
+}
+
+type savedBindings struct {
+	Buttons map[string][]Binding
+	Axes    map[string][]Binding
+	Axes2   map[string]Axis2Binding
+}
+
+// SaveProvider stores the bindings in save files. Saved bindings replace the
+// defaults of the same action, actions added since the save keep their defaults.
+func (m *InputMap) SaveProvider(name string) save.Provider {
+	return save.FuncProvider{
+		Command: remotevm.Command{
+			Name:      name,
+			Arguments: []remotevm.Type{remotevm.Type_U8_Array},
+			Func: func(data []byte) error {
+				saved := savedBindings{}
+				if err := json.Unmarshal(data, &saved); err != nil {
+					return err
+				}
+				for action, b := range saved.Buttons {
+					m.buttons[action] = b
+				}
+				for action, b := range saved.Axes {
+					m.axes[action] = b
+				}
+				for action, b := range saved.Axes2 {
+					m.axes2[action] = b
+				}
+				return nil
+			},
+		},
+		SaveFn: func(w *save.Writer) error {
+			data, err := json.Marshal(savedBindings{Buttons: m.buttons, Axes: m.axes, Axes2: m.axes2})
+			if err != nil {
+				return err
+			}
+			return w.Call(name, data)
+		},
+	}
+}
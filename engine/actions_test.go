@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/supersdf-go/engine/save"
+)
+
+func newTestInputMap() (*InputMap, *EventManager) {
+	events := NewEventManager()
+	m := NewInputMap(events)
+	m.BindButton("Jump", KeyBinding(KeySpace), MouseButtonBinding(MouseButtonRight))
+	m.BindAxis("Turn", AxisBinding(BindMouseX, 0.5), KeyBinding(KeyE), Binding{Kind: BindKey, Code: int(KeyQ), Scale: -1})
+	m.BindAxis2("Move", Axis2Binding{
+		Up:    []Binding{KeyBinding(KeyW)},
+		Down:  []Binding{KeyBinding(KeyS)},
+		Left:  []Binding{KeyBinding(KeyA)},
+		Right: []Binding{KeyBinding(KeyD)},
+	})
+	return m, events
+}
+
+func TestInputMapButtons(t *testing.T) {
+	m, events := newTestInputMap()
+	events.PushKey(KeyEvent{KeyCode: KeySpace, Action: Pressed})
+	events.BeginFrame()
+	if !m.Down("Jump") || !m.Pressed("Jump") {
+		t.Error("Expected Jump to be pressed")
+	}
+	events.BeginFrame()
+	if !m.Down("Jump") || m.Pressed("Jump") {
+		t.Error("Expected Jump to be held")
+	}
+	events.PushKey(KeyEvent{KeyCode: KeySpace, Action: Released})
+	events.PushMouseButton(MouseButtonEvent{Button: MouseButtonRight, Action: Pressed})
+	events.BeginFrame()
+	if !m.Pressed("Jump") {
+		t.Error("Expected Jump to be pressed with the mouse")
+	}
+	if m.Down("Missing") || m.Pressed("Missing") {
+		t.Error("Unbound actions should never be active")
+	}
+}
+
+func TestInputMapAxes(t *testing.T) {
+	m, events := newTestInputMap()
+
+	events.PushKey(KeyEvent{KeyCode: KeyW, Action: Pressed})
+	events.PushKey(KeyEvent{KeyCode: KeyD, Action: Pressed})
+	events.PushMouseMove(MouseMoveEvent{X: 100, Y: 100})
+	events.BeginFrame()
+	move := m.Axis2("Move")
+	if math.Abs(float64(move.X-0.70710677)) > 1e-5 || math.Abs(float64(move.Y-0.70710677)) > 1e-5 {
+		t.Errorf("Expected normalized diagonal, got %v", move)
+	}
+	if turn := m.Axis("Turn"); turn != 0 {
+		t.Errorf("The first mouse position should not turn, got %v", turn)
+	}
+
+	events.PushMouseMove(MouseMoveEvent{X: 104, Y: 100})
+	events.PushKey(KeyEvent{KeyCode: KeyQ, Action: Pressed})
+	events.BeginFrame()
+	if turn := m.Axis("Turn"); turn != 1 {
+		t.Errorf("Expected turn of 1, got %v", turn)
+	}
+
+	m.DeadZone = 0.5
+	events.PushMouseMove(MouseMoveEvent{X: 105, Y: 100})
+	events.PushKey(KeyEvent{KeyCode: KeyQ, Action: Released})
+	events.BeginFrame()
+	if turn := m.Axis("Turn"); turn != 0 {
+		t.Errorf("Expected turn inside the dead zone, got %v", turn)
+	}
+	events.PushMouseMove(MouseMoveEvent{X: 106.5, Y: 100})
+	events.BeginFrame()
+	if turn := m.Axis("Turn"); math.Abs(float64(turn-0.5)) > 1e-5 {
+		t.Errorf("Expected rescaled turn of 0.5, got %v", turn)
+	}
+}
+
+func TestInputMapRebindAndSave(t *testing.T) {
+	m, events := newTestInputMap()
+
+	events.PushKey(KeyEvent{KeyCode: KeyJ, Action: Pressed})
+	events.BeginFrame()
+	b, ok := CaptureBinding(events)
+	if !ok || b != KeyBinding(KeyJ) {
+		t.Fatalf("Expected to capture J, got %v", b)
+	}
+	m.BindButton("Jump", b)
+
+	saves := save.New(1)
+	saves.Register(m.SaveProvider("input-bindings"))
+	buf := &bytes.Buffer{}
+	if err := saves.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, loadedEvents := newTestInputMap()
+	loadSaves := save.New(1)
+	loadSaves.Register(loaded.SaveProvider("input-bindings"))
+	if err := loadSaves.Read(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	bindings := loaded.Bindings("Jump")
+	if len(bindings) != 1 || bindings[0] != KeyBinding(KeyJ) {
+		t.Errorf("Expected the rebound Jump, got %v", bindings)
+	}
+	loadedEvents.PushKey(KeyEvent{KeyCode: KeyJ, Action: Pressed})
+	loadedEvents.BeginFrame()
+	if !loaded.Pressed("Jump") {
+		t.Error("Expected Jump on J after loading")
+	}
+}
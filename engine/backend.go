@@ -0,0 +1,29 @@
+package engine
+
+import (
+	"image"
+
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
+)
+
+// Backend does the drawing for a Screen. RunApp uses OpenGL, RunHeadless
+// rasterizes and raymarches on the CPU.
+type Backend interface {
+	// SetScene prepares the SDF scene raymarched by DrawSdf.
+	SetScene(scene sdf.Sdf) error
+	// Viewport sets the target area in pixels, with y going up from the bottom like GL.
+	Viewport(x, y, width, height int)
+	// Clear clears the color and depth of the current target.
+	Clear()
+	// DrawSdf raymarches the scene from the surface of polygon. transform
+	// includes the camera, model is the transform to world space.
+	DrawSdf(polygon Polygon, transform, model Mat4, cameraPosition vec3.Vec3, color vec4.Vec4)
+	DrawTextured(polygon Polygon, transform, model Mat4, texture uint32)
+	NewFramebuffer(width, height int) (*Framebuffer, error)
+	// BindFramebuffer sets the render target, nil targets the screen.
+	BindFramebuffer(fb *Framebuffer)
+	// ReadPixels returns an area of the screen, in window coordinates like Viewport.
+	ReadPixels(area image.Rectangle) *image.RGBA
+}
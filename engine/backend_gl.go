@@ -0,0 +1,254 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"slices"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
+)
+
+// glBackend draws with OpenGL, it needs a current GL context.
+type glBackend struct {
+	s, s1, s2 ShaderProgram
+	// brickMap is the brick map of the scene and bricks its textures.
+	brickMap *sdf.BrickMap
+	bricks   *BrickMapTextures
+	// indirection is the uploaded table with the culled bricks left out.
+	indirection []int32
+}
+
+// Texture units of the brick map samplers, unit 0 is used by DrawTextured.
+const (
+	brickIndirectionUnit = 1
+	brickAtlasUnit       = 2
+)
+
+// setBrickSamplers points the brick map samplers of program at their texture units.
+func setBrickSamplers(program uint32) {
+	gl.UseProgram(program)
+	gl.Uniform1i(gl.GetUniformLocation(program, gl.Str("brickIndirection\x00")), brickIndirectionUnit)
+	gl.Uniform1i(gl.GetUniformLocation(program, gl.Str("brickAtlas\x00")), brickAtlasUnit)
+}
+
+func newGLBackend() (*glBackend, error) {
+	fragment, err := genGlslFragment()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Shader code: %v\n", fragment)
+	var shaderProgram uint32
+	compileTime := measureTime(func() {
+		shaderProgram, err = compileShaders(vertexShaderSource, fragment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Compiled shader: %v\n", compileTime.String())
+	setBrickSamplers(shaderProgram)
+
+	shaderProgram2, err := compileShaders(vertexShader2Source, fragmentShader2Source)
+	if err != nil {
+		gl.DeleteProgram(shaderProgram)
+		return nil, err
+	}
+	b := &glBackend{s1: NewShaderProgram(shaderProgram), s2: NewShaderProgram(shaderProgram2)}
+	b.s.program = 100000
+	b.UseProgram(b.s1)
+	return b, nil
+}
+
+func (b *glBackend) UseProgram(newShader ShaderProgram) {
+	if b.s.program != newShader.program {
+		b.s = newShader
+		gl.UseProgram(newShader.program)
+		//fmt.Printf("use program %v\n", newShader.program)
+	}
+}
+
+// SetScene swaps in a newly compiled SDF shader program and uploads the
+// textures of a new brick map, on errors the old program is kept.
+func (b *glBackend) SetScene(scene sdf.Sdf) error {
+	src, err := SDF2GLSL(scene)
+	if err != nil {
+		return err
+	}
+	brickMap, err := findBrickMap(scene)
+	if err != nil {
+		return err
+	}
+	program, err := compileShaders(vertexShaderSource, src)
+	if err != nil {
+		return err
+	}
+	setBrickSamplers(program)
+	gl.UseProgram(b.s.program)
+	old := b.s1.program
+	b.s1 = NewShaderProgram(program)
+	if b.s.program == old {
+		b.UseProgram(b.s1)
+	}
+	gl.DeleteProgram(old)
+
+	if brickMap != b.brickMap {
+		if b.bricks != nil {
+			b.bricks.Cleanup()
+			b.bricks = nil
+		}
+		if brickMap != nil {
+			b.bricks = NewBrickMapTextures(brickMap)
+		}
+		b.brickMap = brickMap
+		b.indirection = nil
+	}
+	return nil
+}
+
+// SetVisibleBricks marks the stored bricks that are not visible as empty, so
+// the shader skips them. The table is only uploaded when it changes.
+func (b *glBackend) SetVisibleBricks(visible []int) {
+	if b.bricks == nil {
+		return
+	}
+	table := make([]int32, len(b.brickMap.Indirection))
+	for i, idx := range b.brickMap.Indirection {
+		if idx >= 0 {
+			idx = sdf.BrickEmptyOutside
+		}
+		table[i] = idx
+	}
+	for _, i := range visible {
+		table[i] = b.brickMap.Indirection[i]
+	}
+	if slices.Equal(table, b.indirection) {
+		return
+	}
+	b.bricks.UploadIndirection(b.brickMap.Dims, table)
+	b.indirection = table
+}
+
+func (b *glBackend) Viewport(x, y, width, height int) {
+	gl.Viewport(int32(x), int32(y), int32(width), int32(height))
+}
+
+func (b *glBackend) Clear() {
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+}
+
+// upload creates or updates the GL buffers of a polygon's mesh.
+func (b *glBackend) upload(m *mesh) {
+	if !m.dirty {
+		return
+	}
+	m.dirty = false
+	vbo := m.buffer
+	if vbo == 0 {
+		var vao uint32
+		gl.GenVertexArrays(1, &vao)
+
+		gl.GenBuffers(1, &vbo)
+		m.buffer = vbo
+		m.vao = vao
+		gl.BindVertexArray(m.vao)
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.buffer)
+		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
+		gl.EnableVertexAttribArray(0)
+	}
+	if m.uvs != nil && m.buffer2 == 0 {
+		gl.BindVertexArray(m.vao)
+		gl.GenBuffers(1, &m.buffer2)
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.buffer2)
+		gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
+		gl.EnableVertexAttribArray(1)
+	}
+	gl.BindVertexArray(m.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	if len(m.vertices) > 0 {
+		arr := make([]float32, len(m.vertices)*3)
+		for i, v := range m.vertices {
+			arr[i*3] = v.X
+			arr[i*3+1] = v.Y
+			arr[i*3+2] = v.Z
+		}
+		gl.BufferData(gl.ARRAY_BUFFER, 3*4*len(m.vertices), unsafe.Pointer(&arr[0]), gl.STATIC_DRAW)
+	}
+	if len(m.uvs) > 0 {
+		gl.BindBuffer(gl.ARRAY_BUFFER, m.buffer2)
+
+		arr := make([]float32, len(m.uvs)*2)
+		for i, v := range m.uvs {
+			arr[i*2] = v.X
+			arr[i*2+1] = v.Y
+		}
+		gl.BufferData(gl.ARRAY_BUFFER, 2*4*len(m.uvs), unsafe.Pointer(&arr[0]), gl.STATIC_DRAW)
+	}
+	gl.BindVertexArray(0)
+}
+
+func (b *glBackend) drawArrays(polygon Polygon) {
+	if polygon.mesh == nil {
+		return
+	}
+	b.upload(polygon.mesh)
+	gl.BindVertexArray(polygon.mesh.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(polygon.mesh.vertices)))
+	gl.BindVertexArray(0)
+}
+
+func (b *glBackend) DrawSdf(polygon Polygon, transform, model Mat4, cameraPosition vec3.Vec3, color vec4.Vec4) {
+	b.UseProgram(b.s1)
+	gl.UniformMatrix4fv(b.s.modelView, 1, false, &transform[0])
+	gl.UniformMatrix4fv(b.s.model, 1, false, &model[0])
+	gl.Uniform3f(b.s.cameraPosition, cameraPosition.X, cameraPosition.Y, cameraPosition.Z)
+
+	gl.Uniform4f(b.s.color, color.X, color.Y, color.Z, color.W)
+	if b.bricks != nil {
+		b.bricks.Bind(brickIndirectionUnit, brickAtlasUnit)
+	}
+	b.drawArrays(polygon)
+}
+
+func (b *glBackend) DrawTextured(polygon Polygon, transform, model Mat4, texture uint32) {
+	b.UseProgram(b.s2)
+	gl.UniformMatrix4fv(b.s.modelView, 1, false, &transform[0])
+	gl.UniformMatrix4fv(b.s.model, 1, false, &model[0])
+	gl.Uniform1i(b.s.texture, 0)
+
+	// Activate texture unit 0
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	// Bind the texture to texture unit 0
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+
+	b.drawArrays(polygon)
+}
+
+func (b *glBackend) NewFramebuffer(width, height int) (*Framebuffer, error) {
+	return NewFramebuffer(width, height)
+}
+
+func (b *glBackend) BindFramebuffer(fb *Framebuffer) {
+	if fb == nil {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		return
+	}
+	fb.Bind()
+}
+
+func (b *glBackend) ReadPixels(area image.Rectangle) *image.RGBA {
+	width, height := area.Dx(), area.Dy()
+	pixels := make([]byte, width*height*4)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.ReadPixels(int32(area.Min.X), int32(area.Min.Y), int32(width), int32(height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	// GL rows start at the bottom.
+	for y := 0; y < height; y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+width*4], pixels[(height-1-y)*width*4:(height-y)*width*4])
+	}
+	return img
+}
@@ -0,0 +1,272 @@
+package engine
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec2"
+	"github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
+)
+
+// softwareTarget is a render target of the software backend. Rows are stored
+// top first like image.RGBA, window coordinates go up from the bottom like GL.
+type softwareTarget struct {
+	color *image.RGBA
+	// depth is nil for framebuffers, which have no depth attachment.
+	depth []float32
+}
+
+func newSoftwareTarget(width, height int, withDepth bool) *softwareTarget {
+	t := &softwareTarget{color: image.NewRGBA(image.Rect(0, 0, width, height))}
+	if withDepth {
+		t.depth = make([]float32, width*height)
+	}
+	return t
+}
+
+// softwareBackend rasterizes polygons and raymarches the scene on the CPU,
+// following the GL pipeline and shaders: back faces are culled, depth is
+// tested with GL_LESS and the SDF fragment shader is mirrored by sdfFragment.
+type softwareBackend struct {
+	screen      *softwareTarget
+	target      *softwareTarget
+	viewport    image.Rectangle
+	scene       sdf.Sdf
+	lipschitz   float32
+	targets     map[uint32]*softwareTarget
+	nextTexture uint32
+}
+
+func newSoftwareBackend(width, height int) *softwareBackend {
+	screen := newSoftwareTarget(width, height, true)
+	b := &softwareBackend{
+		screen:      screen,
+		target:      screen,
+		viewport:    image.Rect(0, 0, width, height),
+		scene:       defaultScene(),
+		lipschitz:   1,
+		targets:     map[uint32]*softwareTarget{},
+		nextTexture: 1,
+	}
+	b.Clear()
+	return b
+}
+
+func (b *softwareBackend) SetScene(scene sdf.Sdf) error {
+	b.scene = scene
+	b.lipschitz = sdf.Lipschitz(scene)
+	return nil
+}
+
+func (b *softwareBackend) Viewport(x, y, width, height int) {
+	b.viewport = image.Rect(x, y, x+width, y+height)
+}
+
+func (b *softwareBackend) Clear() {
+	clear(b.target.color.Pix)
+	for i := range b.target.depth {
+		b.target.depth[i] = 1
+	}
+}
+
+func (b *softwareBackend) NewFramebuffer(width, height int) (*Framebuffer, error) {
+	fb := &Framebuffer{Texture: b.nextTexture, Width: width, Height: height}
+	b.nextTexture++
+	b.targets[fb.Texture] = newSoftwareTarget(width, height, false)
+	return fb, nil
+}
+
+func (b *softwareBackend) BindFramebuffer(fb *Framebuffer) {
+	if fb == nil {
+		b.target = b.screen
+		return
+	}
+	b.target = b.targets[fb.Texture]
+}
+
+func (b *softwareBackend) ReadPixels(area image.Rectangle) *image.RGBA {
+	src := b.screen.color
+	height := src.Bounds().Dy()
+	// flip the area to image rows, which start at the top.
+	rows := image.Rect(area.Min.X, height-area.Max.Y, area.Max.X, height-area.Min.Y)
+	img := image.NewRGBA(image.Rect(0, 0, area.Dx(), area.Dy()))
+	draw.Draw(img, img.Bounds(), src, rows.Min, draw.Src)
+	return img
+}
+
+// sdfFragment mirrors main in the SDF fragment shader, distances are divided
+// by the Lipschitz factor of the scene like SDF2GLSL does.
+func sdfFragment(scene sdf.Sdf, lipschitz float32, wp, cameraPosition vec3.Vec3) vec4.Vec4 {
+	loc := wp
+	dir := wp.Subtract(cameraPosition).Normalize()
+	var dist float32
+	for i := 0; i < 20; i++ {
+		dist = scene.Distance(loc) / lipschitz
+		loc = loc.Add(dir.MultiplyScalar(dist * 1.2))
+	}
+	if dist < 0.1 {
+		return vec4.New(1.0, 0.1, 0.1, 1)
+	}
+	return vec4.New(0.1, 0.1, 0.1, 1)
+}
+
+func (b *softwareBackend) DrawSdf(polygon Polygon, transform, model Mat4, cameraPosition vec3.Vec3, _ vec4.Vec4) {
+	b.rasterize(polygon, transform, model, func(wp vec3.Vec3, _ vec2.Vec2) vec4.Vec4 {
+		return sdfFragment(b.scene, b.lipschitz, wp, cameraPosition)
+	})
+}
+
+func (b *softwareBackend) DrawTextured(polygon Polygon, transform, model Mat4, texture uint32) {
+	tex := b.targets[texture]
+	b.rasterize(polygon, transform, model, func(_ vec3.Vec3, uv vec2.Vec2) vec4.Vec4 {
+		return sampleTexture(tex, uv)
+	})
+}
+
+// sampleTexture reads the nearest texel with repeat wrapping. Missing
+// textures read as black, like incomplete textures in GL.
+func sampleTexture(tex *softwareTarget, uv vec2.Vec2) vec4.Vec4 {
+	if tex == nil {
+		return vec4.New(0, 0, 0, 1)
+	}
+	size := tex.color.Bounds().Size()
+	u := uv.X - float32(math.Floor(float64(uv.X)))
+	v := uv.Y - float32(math.Floor(float64(uv.Y)))
+	x := min(int(u*float32(size.X)), size.X-1)
+	// texture coordinates start at the bottom row.
+	y := size.Y - 1 - min(int(v*float32(size.Y)), size.Y-1)
+	c := tex.color.RGBAAt(x, y)
+	return vec4.New(float32(c.R)/255, float32(c.G)/255, float32(c.B)/255, float32(c.A)/255)
+}
+
+// clipVertex is a vertex in clip space with the attributes interpolated for the fragments.
+type clipVertex struct {
+	pos   vec4.Vec4
+	world vec3.Vec3
+	uv    vec2.Vec2
+}
+
+func lerpClipVertex(a, b clipVertex, t float32) clipVertex {
+	lerp := func(x, y float32) float32 { return x + (y-x)*t }
+	return clipVertex{
+		pos:   vec4.New(lerp(a.pos.X, b.pos.X), lerp(a.pos.Y, b.pos.Y), lerp(a.pos.Z, b.pos.Z), lerp(a.pos.W, b.pos.W)),
+		world: vec3.New(lerp(a.world.X, b.world.X), lerp(a.world.Y, b.world.Y), lerp(a.world.Z, b.world.Z)),
+		uv:    vec2.New(lerp(a.uv.X, b.uv.X), lerp(a.uv.Y, b.uv.Y)),
+	}
+}
+
+// clipPolygon clips against the near and far planes, -w <= z <= w.
+func clipPolygon(vertices []clipVertex) []clipVertex {
+	planes := []func(v vec4.Vec4) float32{
+		func(v vec4.Vec4) float32 { return v.W + v.Z },
+		func(v vec4.Vec4) float32 { return v.W - v.Z },
+	}
+	for _, plane := range planes {
+		var out []clipVertex
+		for i, cur := range vertices {
+			prev := vertices[(i+len(vertices)-1)%len(vertices)]
+			dc, dp := plane(cur.pos), plane(prev.pos)
+			if (dc >= 0) != (dp >= 0) {
+				out = append(out, lerpClipVertex(prev, cur, dp/(dp-dc)))
+			}
+			if dc >= 0 {
+				out = append(out, cur)
+			}
+		}
+		vertices = out
+		if len(vertices) < 3 {
+			return nil
+		}
+	}
+	return vertices
+}
+
+func (b *softwareBackend) rasterize(polygon Polygon, transform, model Mat4, shade func(wp vec3.Vec3, uv vec2.Vec2) vec4.Vec4) {
+	if polygon.mesh == nil || b.target == nil {
+		return
+	}
+	m := polygon.mesh
+	for i := 0; i+2 < len(m.vertices); i += 3 {
+		var tri [3]clipVertex
+		for j := range tri {
+			v := m.vertices[i+j]
+			tri[j] = clipVertex{pos: transform.MultiplyVec4(vec4.New(v.X, v.Y, v.Z, 1)), world: model.Apply(v)}
+			if i+j < len(m.uvs) {
+				tri[j].uv = m.uvs[i+j]
+			}
+		}
+		clipped := clipPolygon(tri[:])
+		for j := 1; j+1 < len(clipped); j++ {
+			b.rasterizeTriangle(clipped[0], clipped[j], clipped[j+1], shade)
+		}
+	}
+}
+
+func (b *softwareBackend) rasterizeTriangle(v0, v1, v2 clipVertex, shade func(wp vec3.Vec3, uv vec2.Vec2) vec4.Vec4) {
+	vs := [3]clipVertex{v0, v1, v2}
+	var sx, sy, sz, invW [3]float32
+	vp := b.viewport
+	for i, v := range vs {
+		invW[i] = 1 / v.pos.W
+		sx[i] = float32(vp.Min.X) + (v.pos.X*invW[i]+1)/2*float32(vp.Dx())
+		sy[i] = float32(vp.Min.Y) + (v.pos.Y*invW[i]+1)/2*float32(vp.Dy())
+		sz[i] = (v.pos.Z*invW[i] + 1) / 2
+	}
+	area := (sx[1]-sx[0])*(sy[2]-sy[0]) - (sx[2]-sx[0])*(sy[1]-sy[0])
+	// counter clockwise triangles face the camera, the others are culled.
+	if !(area > 0) {
+		return
+	}
+
+	height := b.target.color.Bounds().Dy()
+	bounds := vp.Intersect(b.target.color.Bounds())
+	minX := max(bounds.Min.X, int(math.Floor(float64(min(sx[0], sx[1], sx[2])))))
+	maxX := min(bounds.Max.X, int(math.Ceil(float64(max(sx[0], sx[1], sx[2])))))
+	minY := max(bounds.Min.Y, int(math.Floor(float64(min(sy[0], sy[1], sy[2])))))
+	maxY := min(bounds.Max.Y, int(math.Ceil(float64(max(sy[0], sy[1], sy[2])))))
+
+	edge := func(a, c int, x, y float32) float32 {
+		return (sx[c]-sx[a])*(y-sy[a]) - (sy[c]-sy[a])*(x-sx[a])
+	}
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			px, py := float32(x)+0.5, float32(y)+0.5
+			w0, w1, w2 := edge(1, 2, px, py), edge(2, 0, px, py), edge(0, 1, px, py)
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			w0, w1, w2 = w0/area, w1/area, w2/area
+			depth := w0*sz[0] + w1*sz[1] + w2*sz[2]
+			row := height - 1 - y
+			if b.target.depth != nil {
+				idx := row*b.target.color.Bounds().Dx() + x
+				if !(depth < b.target.depth[idx]) {
+					continue
+				}
+				b.target.depth[idx] = depth
+			}
+
+			// perspective correct interpolation.
+			p0, p1, p2 := w0*invW[0], w1*invW[1], w2*invW[2]
+			norm := 1 / (p0 + p1 + p2)
+			p0, p1, p2 = p0*norm, p1*norm, p2*norm
+			wp := vec3.New(
+				p0*v0.world.X+p1*v1.world.X+p2*v2.world.X,
+				p0*v0.world.Y+p1*v1.world.Y+p2*v2.world.Y,
+				p0*v0.world.Z+p1*v1.world.Z+p2*v2.world.Z)
+			uv := vec2.New(p0*v0.uv.X+p1*v1.uv.X+p2*v2.uv.X, p0*v0.uv.Y+p1*v1.uv.Y+p2*v2.uv.Y)
+			b.target.color.SetRGBA(x, row, toRGBA(shade(wp, uv)))
+		}
+	}
+}
+
+func toRGBA(c vec4.Vec4) color.RGBA {
+	unorm := func(v float32) uint8 {
+		return uint8(max(0, min(1, v))*255 + 0.5)
+	}
+	return color.RGBA{R: unorm(c.X), G: unorm(c.Y), B: unorm(c.Z), A: unorm(c.W)}
+}
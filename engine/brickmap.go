@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"github.com/go-gl/gl/v4.1-core/gl"
+	sdf "github.com/supersdf-go/engine/sdf"
+)
+
+// BrickMapTextures holds the GPU textures of a baked brick map.
+type BrickMapTextures struct {
+	Indirection uint32
+	Atlas       uint32
+}
+
+// NewBrickMapTextures uploads the brick map atlas and indirection table as 3D textures.
+func NewBrickMapTextures(brickMap *sdf.BrickMap) *BrickMapTextures {
+	layout := brickMap.GPULayout()
+	textures := &BrickMapTextures{}
+
+	gl.GenTextures(1, &textures.Indirection)
+	gl.BindTexture(gl.TEXTURE_3D, textures.Indirection)
+	gl.TexImage3D(gl.TEXTURE_3D, 0, gl.R32I,
+		int32(layout.IndirectionDims[0]), int32(layout.IndirectionDims[1]), int32(layout.IndirectionDims[2]),
+		0, gl.RED_INTEGER, gl.INT, gl.Ptr(layout.Indirection))
+	setNearest3D()
+
+	size := layout.AtlasSize()
+	gl.GenTextures(1, &textures.Atlas)
+	gl.BindTexture(gl.TEXTURE_3D, textures.Atlas)
+	gl.TexImage3D(gl.TEXTURE_3D, 0, gl.R32F,
+		int32(size[0]), int32(size[1]), int32(size[2]),
+		0, gl.RED, gl.FLOAT, gl.Ptr(layout.Atlas))
+	setNearest3D()
+
+	gl.BindTexture(gl.TEXTURE_3D, 0)
+	return textures
+}
+
+func setNearest3D() {
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+}
+
+// UploadIndirection replaces the indirection table, which has the size of the
+// brick map it was created for.
+func (t *BrickMapTextures) UploadIndirection(dims [3]int, indirection []int32) {
+	gl.BindTexture(gl.TEXTURE_3D, t.Indirection)
+	gl.TexSubImage3D(gl.TEXTURE_3D, 0, 0, 0, 0, int32(dims[0]), int32(dims[1]), int32(dims[2]),
+		gl.RED_INTEGER, gl.INT, gl.Ptr(indirection))
+	gl.BindTexture(gl.TEXTURE_3D, 0)
+}
+
+// Bind binds the indirection and atlas textures to the given texture units.
+func (t *BrickMapTextures) Bind(indirectionUnit, atlasUnit uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + indirectionUnit)
+	gl.BindTexture(gl.TEXTURE_3D, t.Indirection)
+	gl.ActiveTexture(gl.TEXTURE0 + atlasUnit)
+	gl.BindTexture(gl.TEXTURE_3D, t.Atlas)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// Cleanup releases the textures.
+func (t *BrickMapTextures) Cleanup() {
+	gl.DeleteTextures(1, &t.Indirection)
+	gl.DeleteTextures(1, &t.Atlas)
+}
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"math"
+
+	"github.com/supersdf-go/engine/quat"
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+// maxPitch keeps the camera from flipping over when looking straight up or down.
+const maxPitch = math.Pi/2 - 0.001
+
+// Camera is a perspective camera oriented by yaw and pitch. At zero yaw and
+// pitch it looks down -Z with +Y up, positive yaw turns left and positive
+// pitch looks up. Angles are in radians.
+type Camera struct {
+	Position   vec3.Vec3
+	Yaw, Pitch float32
+	// Fov is the vertical field of view.
+	Fov       float32
+	Near, Far float32
+}
+
+func NewCamera(position vec3.Vec3) Camera {
+	return Camera{Position: position, Fov: 1.2, Near: 1, Far: 1000}
+}
+
+// SetPitch sets the pitch, clamped to just short of straight up or down.
+func (c *Camera) SetPitch(pitch float32) {
+	c.Pitch = max(-maxPitch, min(maxPitch, pitch))
+}
+
+// Forward is the direction the camera looks in.
+func (c *Camera) Forward() vec3.Vec3 {
+	sy, cy := math.Sincos(float64(c.Yaw))
+	sp, cp := math.Sincos(float64(c.Pitch))
+	return vec3.New(float32(-sy*cp), float32(sp), float32(-cy*cp))
+}
+
+// Right is always horizontal, so the camera never rolls.
+func (c *Camera) Right() vec3.Vec3 {
+	sy, cy := math.Sincos(float64(c.Yaw))
+	return vec3.New(float32(cy), 0, float32(-sy))
+}
+
+func (c *Camera) Up() vec3.Vec3 {
+	return c.Right().CrossProduct(c.Forward())
+}
+
+// Orientation is the rotation from camera space to world space.
+func (c *Camera) Orientation() quat.Quat {
+	return quat.FromEuler(c.Yaw, c.Pitch, 0)
+}
+
+// LookAt turns the camera towards target.
+func (c *Camera) LookAt(target vec3.Vec3) {
+	d := target.Subtract(c.Position)
+	if d.Length() == 0 {
+		return
+	}
+	c.Yaw = float32(math.Atan2(float64(-d.X), float64(-d.Z)))
+	c.SetPitch(float32(math.Atan2(float64(d.Y), math.Hypot(float64(d.X), float64(d.Z)))))
+}
+
+// Lerp interpolates the position and orientation towards next, for drawing between fixed updates.
+func (c Camera) Lerp(next Camera, t float32) Camera {
+	lerp := func(a, b float32) float32 { return a + (b-a)*t }
+	c.Position = vec3.New(lerp(c.Position.X, next.Position.X), lerp(c.Position.Y, next.Position.Y), lerp(c.Position.Z, next.Position.Z))
+	c.Yaw = lerp(c.Yaw, next.Yaw)
+	c.Pitch = lerp(c.Pitch, next.Pitch)
+	return c
+}
+
+// View transforms world space into camera space.
+func (c *Camera) View() Mat4 {
+	p := c.Position
+	return RotationMatrix2(c.Up(), c.Right()).Multiply(Mat4Translation(-p.X, -p.Y, -p.Z))
+}
+
+func (c *Camera) Projection(aspect float32) Mat4 {
+	return PerspectiveMatrix(c.Fov, aspect, c.Near, c.Far)
+}
+
+// Apply sets the camera of the screen, aspect is width / height of the render target.
+func (c *Camera) Apply(screen *Screen, aspect float32) {
+	screen.SetCamera(c.Projection(aspect), c.Position, c.Up(), c.Right())
+}
+
+// ScreenRay returns the world space ray through a pixel, with x and y from
+// the top left corner of a render target of the given size.
+func (c *Camera) ScreenRay(x, y float32, width, height int) sdf.Ray {
+	aspect := float32(width) / float32(height)
+	tanHalf := float32(math.Tan(float64(c.Fov) / 2))
+	nx := (2*x/float32(width) - 1) * tanHalf * aspect
+	ny := (1 - 2*y/float32(height)) * tanHalf
+	dir := c.Forward().Add(c.Right().MultiplyScalar(nx)).Add(c.Up().MultiplyScalar(ny))
+	return sdf.Ray{Origin: c.Position, Dir: dir.Normalize()}
+}
+
+// Actions read by the camera controllers, see BindCameraDefaults.
+const (
+	// ActionMove is an Axis2 action, Y moves forward and X strafes right.
+	ActionMove = "Move"
+	// ActionLift is an axis moving the fly camera up.
+	ActionLift = "Lift"
+	// ActionLook is a button enabling mouse look while held.
+	ActionLook  = "Look"
+	ActionLookX = "LookX"
+	ActionLookY = "LookY"
+	// ActionZoom is an axis moving the orbit camera closer to its target.
+	ActionZoom = "Zoom"
+)
+
+// BindCameraDefaults binds the camera actions to WASD, space/shift, the right
+// mouse button, mouse movement and the scroll wheel.
+func BindCameraDefaults(input *InputMap) {
+	input.BindAxis2(ActionMove, Axis2Binding{
+		Up:    []Binding{KeyBinding(KeyW), KeyBinding(KeyUp)},
+		Down:  []Binding{KeyBinding(KeyS), KeyBinding(KeyDown)},
+		Left:  []Binding{KeyBinding(KeyA), KeyBinding(KeyLeft)},
+		Right: []Binding{KeyBinding(KeyD), KeyBinding(KeyRight)},
+	})
+	input.BindAxis(ActionLift, KeyBinding(KeySpace), Binding{Kind: BindKey, Code: int(KeyLeftShift), Scale: -1})
+	input.BindButton(ActionLook, MouseButtonBinding(MouseButtonRight))
+	input.BindAxis(ActionLookX, AxisBinding(BindMouseX, 1))
+	input.BindAxis(ActionLookY, AxisBinding(BindMouseY, 1))
+	input.BindAxis(ActionZoom, AxisBinding(BindScrollY, 1))
+}
+
+// look turns the camera by the mouse movement while ActionLook is held.
+func look(camera *Camera, input *InputMap, sensitivity float32) {
+	if !input.Down(ActionLook) {
+		return
+	}
+	camera.Yaw -= input.Axis(ActionLookX) * sensitivity
+	camera.SetPitch(camera.Pitch - input.Axis(ActionLookY)*sensitivity)
+}
+
+// FlyCamera moves freely in the direction the camera looks.
+type FlyCamera struct {
+	Camera *Camera
+	// Speed is in units per second.
+	Speed float32
+	// Sensitivity is in radians per pixel of mouse movement.
+	Sensitivity float32
+}
+
+func NewFlyCamera(camera *Camera) *FlyCamera {
+	return &FlyCamera{Camera: camera, Speed: 5, Sensitivity: 0.005}
+}
+
+// Update applies the input of the current frame, dt is the time step in seconds.
+func (f *FlyCamera) Update(input *InputMap, dt float32) {
+	look(f.Camera, input, f.Sensitivity)
+	move := input.Axis2(ActionMove)
+	lift := input.Axis(ActionLift)
+	step := f.Camera.Forward().MultiplyScalar(move.Y)
+	step = step.Add(f.Camera.Right().MultiplyScalar(move.X))
+	step = step.Add(vec3.New(0, lift, 0))
+	f.Camera.Position = f.Camera.Position.Add(step.MultiplyScalar(f.Speed * dt))
+}
+
+// OrbitCamera circles a target at a distance, always looking at it.
+type OrbitCamera struct {
+	Camera   *Camera
+	Target   vec3.Vec3
+	Distance float32
+
+	MinDistance, MaxDistance float32
+	Sensitivity              float32
+	// ZoomSpeed is the fraction of the distance moved per scroll step.
+	ZoomSpeed float32
+}
+
+func NewOrbitCamera(camera *Camera, target vec3.Vec3, distance float32) *OrbitCamera {
+	return &OrbitCamera{
+		Camera:      camera,
+		Target:      target,
+		Distance:    distance,
+		MinDistance: 0.1,
+		MaxDistance: 1000,
+		Sensitivity: 0.005,
+		ZoomSpeed:   0.1,
+	}
+}
+
+func (o *OrbitCamera) Update(input *InputMap, dt float32) {
+	look(o.Camera, input, o.Sensitivity)
+	o.Distance *= 1 - input.Axis(ActionZoom)*o.ZoomSpeed
+	o.Distance = max(o.MinDistance, min(o.MaxDistance, o.Distance))
+	o.Camera.Position = o.Target.Subtract(o.Camera.Forward().MultiplyScalar(o.Distance))
+}
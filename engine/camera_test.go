@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
+)
+
+// transformPoint multiplies a column-major matrix with a point.
+func transformPoint(m Mat4, p vec3.Vec3) vec3.Vec3 {
+	v := [4]float32{p.X, p.Y, p.Z, 1}
+	var out [3]float32
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 4; c++ {
+			out[r] += m.Get(r, c) * v[c]
+		}
+	}
+	return vec3.New(out[0], out[1], out[2])
+}
+
+func TestCameraOrientation(t *testing.T) {
+	c := NewCamera(vec3.New(0, 0, 0))
+	if !c.Forward().ApproxEqual(vec3.New(0, 0, -1), 1e-4) || !c.Right().ApproxEqual(vec3.New(1, 0, 0), 1e-4) || !c.Up().ApproxEqual(vec3.New(0, 1, 0), 1e-4) {
+		t.Errorf("Unexpected default basis %v %v %v", c.Forward(), c.Right(), c.Up())
+	}
+	c.Yaw = math.Pi / 2
+	if !c.Forward().ApproxEqual(vec3.New(-1, 0, 0), 1e-4) {
+		t.Errorf("Positive yaw should turn left, got %v", c.Forward())
+	}
+	c.Yaw = 0
+	c.Pitch = math.Pi / 4
+	if c.Forward().Y <= 0 {
+		t.Errorf("Positive pitch should look up, got %v", c.Forward())
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		c.Yaw = r.Float32()*20 - 10
+		c.SetPitch(r.Float32()*4 - 2)
+		f, right, up := c.Forward(), c.Right(), c.Up()
+		for _, v := range []vec3.Vec3{f, right, up} {
+			if math.Abs(float64(v.Length()-1)) > 1e-4 {
+				t.Fatalf("Expected unit vectors, got %v", v)
+			}
+		}
+		if math.Abs(float64(f.DotProduct(right))) > 1e-4 || math.Abs(float64(f.DotProduct(up))) > 1e-4 || math.Abs(float64(up.DotProduct(right))) > 1e-4 {
+			t.Fatalf("Expected an orthogonal basis, got %v %v %v", f, right, up)
+		}
+		if right.Y != 0 || up.Y < 0 {
+			t.Fatalf("The camera should not roll or flip, got %v %v", right, up)
+		}
+	}
+}
+
+func TestCameraPitchClamp(t *testing.T) {
+	c := NewCamera(vec3.New(0, 0, 0))
+	c.SetPitch(10)
+	if c.Pitch >= math.Pi/2 {
+		t.Errorf("Pitch should be clamped, got %v", c.Pitch)
+	}
+	c.SetPitch(-10)
+	if c.Pitch <= -math.Pi/2 {
+		t.Errorf("Pitch should be clamped, got %v", c.Pitch)
+	}
+}
+
+func TestCameraView(t *testing.T) {
+	c := NewCamera(vec3.New(1, 2, 3))
+	c.Yaw = 0.7
+	c.SetPitch(-0.3)
+	view := c.View()
+	if p := transformPoint(view, c.Position); !p.ApproxEqual(vec3.Vec3{}, 1e-4) {
+		t.Errorf("The camera position should map to the origin, got %v", p)
+	}
+	ahead := c.Position.Add(c.Forward().MultiplyScalar(2))
+	if p := transformPoint(view, ahead); !p.ApproxEqual(vec3.New(0, 0, -2), 1e-4) {
+		t.Errorf("Forward should map to -Z, got %v", p)
+	}
+	above := c.Position.Add(c.Up())
+	if p := transformPoint(view, above); !p.ApproxEqual(vec3.New(0, 1, 0), 1e-4) {
+		t.Errorf("Up should map to +Y, got %v", p)
+	}
+}
+
+func TestCameraLookAt(t *testing.T) {
+	c := NewCamera(vec3.New(1, 2, 3))
+	target := vec3.New(-4, 5, 0)
+	c.LookAt(target)
+	dir := target.Subtract(c.Position).Normalize()
+	if !c.Forward().ApproxEqual(dir, 1e-4) {
+		t.Errorf("Expected to look at %v, got %v", dir, c.Forward())
+	}
+}
+
+func TestCameraLerp(t *testing.T) {
+	a := NewCamera(vec3.New(0, 0, 0))
+	b := NewCamera(vec3.New(2, 4, 6))
+	b.Yaw, b.Pitch = 1, -0.5
+	c := a.Lerp(b, 0.5)
+	if !c.Position.ApproxEqual(vec3.New(1, 2, 3), 1e-4) || c.Yaw != 0.5 || c.Pitch != -0.25 {
+		t.Errorf("Unexpected interpolation %+v", c)
+	}
+}
+
+func TestFlyCamera(t *testing.T) {
+	events := NewEventManager()
+	input := NewInputMap(events)
+	BindCameraDefaults(input)
+	camera := NewCamera(vec3.New(0, 0, 0))
+	fly := NewFlyCamera(&camera)
+	fly.Speed = 2
+
+	events.PushKey(KeyEvent{KeyCode: KeyW, Action: Pressed})
+	events.BeginFrame()
+	fly.Update(input, 0.5)
+	if !camera.Position.ApproxEqual(vec3.New(0, 0, -1), 1e-4) {
+		t.Errorf("Expected to move forward, got %v", camera.Position)
+	}
+
+	events.PushMouseMove(MouseMoveEvent{X: 10, Y: 10})
+	events.BeginFrame()
+	events.PushMouseMove(MouseMoveEvent{X: 20, Y: 10})
+	events.BeginFrame()
+	fly.Update(input, 0)
+	if camera.Yaw != 0 {
+		t.Errorf("The camera should only turn while looking, got %v", camera.Yaw)
+	}
+	events.PushMouseButton(MouseButtonEvent{Button: MouseButtonRight, Action: Pressed})
+	events.PushMouseMove(MouseMoveEvent{X: 30, Y: 10})
+	events.BeginFrame()
+	fly.Update(input, 0)
+	if math.Abs(float64(camera.Yaw+10*fly.Sensitivity)) > 1e-6 {
+		t.Errorf("Moving the mouse right should turn right, got %v", camera.Yaw)
+	}
+}
+
+func TestOrbitCamera(t *testing.T) {
+	events := NewEventManager()
+	input := NewInputMap(events)
+	BindCameraDefaults(input)
+	camera := NewCamera(vec3.New(0, 0, 0))
+	target := vec3.New(1, 1, 1)
+	orbit := NewOrbitCamera(&camera, target, 4)
+
+	events.PushMouseButton(MouseButtonEvent{Button: MouseButtonRight, Action: Pressed})
+	events.PushMouseMove(MouseMoveEvent{X: 0, Y: 0})
+	for i := 1; i < 20; i++ {
+		events.BeginFrame()
+		orbit.Update(input, 0.016)
+		if d := camera.Position.Subtract(target).Length(); math.Abs(float64(d-4)) > 1e-4 {
+			t.Fatalf("Expected distance 4, got %v", d)
+		}
+		if !camera.Position.Add(camera.Forward().MultiplyScalar(4)).ApproxEqual(target, 1e-4) {
+			t.Fatalf("Expected to look at the target, got %v", camera.Forward())
+		}
+		events.PushMouseMove(MouseMoveEvent{X: float64(i * 40), Y: float64(i * 15)})
+	}
+	if camera.Yaw == 0 || camera.Pitch == 0 {
+		t.Error("Expected the camera to orbit")
+	}
+
+	events.PushScroll(ScrollEvent{DY: 1})
+	events.BeginFrame()
+	orbit.Update(input, 0.016)
+	if math.Abs(float64(orbit.Distance-3.6)) > 1e-4 {
+		t.Errorf("Expected to zoom in, got %v", orbit.Distance)
+	}
+}
+
+func TestScreenRay(t *testing.T) {
+	c := NewCamera(vec3.New(1, 2, 3))
+	c.Yaw, c.Pitch = 0.4, 0.2
+	if r := c.ScreenRay(50, 25, 100, 50); !r.Dir.ApproxEqual(c.Forward(), 1e-4) || r.Origin != c.Position {
+		t.Errorf("Expected the center ray to look forward, got %v", r)
+	}
+	// the top right corner is at the edge of the field of view.
+	r := c.ScreenRay(100, 0, 100, 50)
+	tanHalf := float32(math.Tan(float64(c.Fov) / 2))
+	expected := c.Forward().Add(c.Up().MultiplyScalar(tanHalf)).Add(c.Right().MultiplyScalar(tanHalf * 2)).Normalize()
+	if !r.Dir.ApproxEqual(expected, 1e-4) {
+		t.Errorf("Expected %v, got %v", expected, r.Dir)
+	}
+
+	screen := Screen{backend: newSoftwareBackend(100, 50)}
+	screen.layout(&layoutContext{}, 100, 50, 100, 50)
+	c.Apply(&screen, 2)
+	for _, p := range [][2]float32{{50, 25}, {0, 0}, {100, 50}, {20, 40}} {
+		fromCamera := c.ScreenRay(p[0], p[1], 100, 50)
+		fromScreen := screen.ScreenRay(p[0], p[1])
+		if !fromCamera.Dir.ApproxEqual(fromScreen.Dir, 1e-4) {
+			t.Errorf("Expected the screen ray through %v to match the camera, got %v and %v", p, fromScreen.Dir, fromCamera.Dir)
+		}
+		// the screen ray starts on the near plane.
+		if d := fromScreen.Origin.Sub(c.Position).DotProduct(c.Forward()); math.Abs(float64(d-c.Near)) > 1e-3 {
+			t.Errorf("Expected the ray to start at the near plane, got %v", d)
+		}
+	}
+	if r := screen.MouseRay(50, 25); !r.Dir.ApproxEqual(c.Forward(), 1e-4) {
+		t.Errorf("Expected the mouse ray at the center to look forward, got %v", r.Dir)
+	}
+}
+
+func TestPickEvents(t *testing.T) {
+	screen := Screen{backend: newSoftwareBackend(100, 50)}
+	screen.layout(&layoutContext{}, 100, 50, 100, 50)
+	scene := sdf.Union{sdf.Sphere{Center: vec3.New(0, 0, -10), Radius: 2}, sdf.Sphere{Center: vec3.New(10, 0, -10), Radius: 2}}
+	if err := screen.SetScene(scene); err != nil {
+		t.Fatal(err)
+	}
+	camera := NewCamera(vec3.New(0, 0, 0))
+	camera.Apply(&screen, 2)
+	var cube Polygon
+	cube.Load3D(cubeVertices())
+	screen.Draw(cube, Mat4Translation(0, 0, -10), vec4.New(1, 1, 1, 1))
+	// drawing the framebuffer afterwards does not change the picking camera.
+	screen.SetCamera(Mat4Identity(), vec3.New(0, 0, 0), vec3.New(0, 1, 0), vec3.New(1, 0, 0))
+
+	events := NewEventManager()
+	input := NewInputMap(events)
+	input.BindButton("Pick", MouseButtonBinding(MouseButtonLeft))
+	events.PushMouseButton(MouseButtonEvent{Button: MouseButtonLeft, Action: Pressed, X: 50, Y: 25})
+	events.PushMouseButton(MouseButtonEvent{Button: MouseButtonRight, Action: Pressed, X: 0, Y: 0})
+	events.PushMouseButton(MouseButtonEvent{Button: MouseButtonLeft, Action: Released, X: 50, Y: 25})
+	events.BeginFrame()
+	screen.pickEvents(events)
+
+	var all []PickEvent
+	events.ReadPickEvents(&all)
+	if len(all) != 2 {
+		t.Fatalf("Expected a pick event for each press, got %v", len(all))
+	}
+	picks := input.Picks("Pick")
+	if len(picks) != 1 || !picks[0].OK {
+		t.Fatalf("Expected one left click hit, got %v", picks)
+	}
+	hit := picks[0].Hit
+	if len(hit.Path) != 1 || hit.Path[0] != 0 || !hit.Normal.ApproxEqual(vec3.New(0, 0, 1), 1e-4) || math.Abs(float64(hit.Point.Z+8)) > 1e-3 {
+		t.Errorf("Expected the front of the first sphere, got %+v", hit)
+	}
+	if all[1].OK {
+		t.Errorf("Expected the corner click to miss, got %+v", all[1].Hit)
+	}
+
+	events.BeginFrame()
+	screen.pickEvents(events)
+	if picks := input.Picks("Pick"); len(picks) != 0 {
+		t.Errorf("Expected the pick events to last one frame")
+	}
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"math"
+
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+// CharacterController moves an upright capsule through an Sdf world without
+// physics. It walks up gentle slopes and low steps, slides along walls and
+// falls with gravity.
+type CharacterController struct {
+	// Position is the bottom of the capsule.
+	Position       vec3.Vec3
+	Radius, Height float32
+	// Velocity is in units per second. Move sets the horizontal part, set
+	// Velocity.Y before Move to jump.
+	Velocity vec3.Vec3
+	Gravity  float32
+	// MaxSlope is the steepest walkable ground in radians.
+	MaxSlope float32
+	// StepHeight is the highest ledge climbed without jumping.
+	StepHeight float32
+	// SkinWidth is the gap kept to the surface so sweeps do not start inside.
+	SkinWidth float32
+
+	Grounded     bool
+	GroundNormal vec3.Vec3
+}
+
+func NewCharacterController(position vec3.Vec3) *CharacterController {
+	return &CharacterController{
+		Position:   position,
+		Radius:     0.3,
+		Height:     1.8,
+		Gravity:    9.81,
+		MaxSlope:   math.Pi / 4,
+		StepHeight: 0.3,
+		SkinWidth:  0.01,
+	}
+}
+
+// segment returns the ends of the capsule axis for the bottom at p.
+func (c *CharacterController) segment(p vec3.Vec3) (vec3.Vec3, vec3.Vec3) {
+	return p.Add(vec3.New(0, c.Radius, 0)), p.Add(vec3.New(0, max(c.Height-c.Radius, c.Radius), 0))
+}
+
+func (c *CharacterController) walkable(n vec3.Vec3) bool {
+	return n.Y >= float32(math.Cos(float64(c.MaxSlope)))
+}
+
+// sweep moves the capsule at p by motion until it touches the world, keeping
+// SkinWidth to the surface. It returns the new bottom and the part of the
+// motion that is left.
+func (c *CharacterController) sweep(world sdf.Sdf, p, motion vec3.Vec3) (vec3.Vec3, vec3.Vec3, sdf.Contact, bool) {
+	a, b := c.segment(p)
+	t, contact, hit := sdf.SweepCapsule(world, a, b, c.Radius, motion)
+	if !hit {
+		return p.Add(motion), vec3.Vec3{}, contact, false
+	}
+	p = p.Add(motion.MultiplyScalar(t)).Add(contact.Normal.MultiplyScalar(contact.Depth + c.SkinWidth))
+	return p, motion.MultiplyScalar(1 - t), contact, true
+}
+
+// slide moves along the surfaces it touches. It reports whether a wall or a
+// ceiling stopped part of the motion.
+func (c *CharacterController) slide(world sdf.Sdf, p, motion vec3.Vec3) (vec3.Vec3, bool) {
+	blocked := false
+	for i := 0; i < 4 && motion.Length() > 1e-6; i++ {
+		var contact sdf.Contact
+		var hit bool
+		p, motion, contact, hit = c.sweep(world, p, motion)
+		if !hit {
+			break
+		}
+		n := contact.Normal
+		if !c.walkable(n) {
+			blocked = true
+			// walking into a steep slope must not climb it.
+			if c.Grounded && n.Y > 0 {
+				n = vec3.New(n.X, 0, n.Z).Normalize()
+			}
+		}
+		motion = motion.Subtract(n.MultiplyScalar(motion.DotProduct(n)))
+	}
+	return p, blocked
+}
+
+// walk slides horizontally and climbs onto steps lower than StepHeight.
+// Climbing moves at least Radius forward, so the round bottom of the capsule
+// does not end on the edge of the step.
+func (c *CharacterController) walk(world sdf.Sdf, p, motion vec3.Vec3) vec3.Vec3 {
+	moved, blocked := c.slide(world, p, motion)
+	if !blocked || !c.Grounded || c.StepHeight <= 0 {
+		return moved
+	}
+	if l := motion.Length(); l > 0 && l < c.Radius {
+		motion = motion.MultiplyScalar(c.Radius / l)
+	}
+	up, _, _, _ := c.sweep(world, p, vec3.New(0, c.StepHeight, 0))
+	stepped, _ := c.slide(world, up, motion)
+	down, _, contact, hit := c.sweep(world, stepped, vec3.New(0, p.Y-up.Y-c.SkinWidth, 0))
+	if !hit || !c.walkable(contact.Normal) {
+		return moved
+	}
+	if horizontalDistance(p, down) <= horizontalDistance(p, moved)+1e-5 {
+		return moved
+	}
+	return down
+}
+
+func horizontalDistance(a, b vec3.Vec3) float32 {
+	return a.XZ().Distance(b.XZ())
+}
+
+// ground returns the normal of walkable ground under the capsule at p.
+func (c *CharacterController) ground(world sdf.Sdf, p vec3.Vec3) (vec3.Vec3, bool) {
+	a, _ := c.segment(p)
+	contact, ok := sdf.SphereOverlap(world, a, c.Radius+2*c.SkinWidth)
+	if !ok || !c.walkable(contact.Normal) {
+		return vec3.Vec3{}, false
+	}
+	return contact.Normal, true
+}
+
+// Move walks with the horizontal velocity walk for dt seconds. It applies
+// gravity, keeps the capsule out of world and updates Grounded.
+func (c *CharacterController) Move(world sdf.Sdf, walk vec3.Vec3, dt float32) {
+	p := c.Position
+	// push out of anything that moved into the capsule.
+	a, b := c.segment(p)
+	if contact, ok := sdf.CapsuleOverlap(world, a, b, c.Radius); ok {
+		p = p.Add(contact.Normal.MultiplyScalar(contact.Depth + c.SkinWidth))
+	}
+
+	onGround := c.Grounded && c.Velocity.Y <= 0
+	if onGround {
+		c.Velocity.Y = 0
+	} else {
+		c.Velocity.Y -= c.Gravity * dt
+	}
+	c.Velocity.X, c.Velocity.Z = walk.X, walk.Z
+
+	p = c.walk(world, p, vec3.New(walk.X, 0, walk.Z).MultiplyScalar(dt))
+	if onGround {
+		// stay on the ground walking down slopes and steps.
+		if down, _, contact, hit := c.sweep(world, p, vec3.New(0, -c.StepHeight-c.SkinWidth, 0)); hit && c.walkable(contact.Normal) {
+			p = down
+		}
+	} else {
+		var blocked bool
+		p, blocked = c.slide(world, p, vec3.New(0, c.Velocity.Y*dt, 0))
+		if blocked && c.Velocity.Y > 0 {
+			c.Velocity.Y = 0
+		}
+	}
+
+	c.Position = p
+	c.GroundNormal, c.Grounded = c.ground(world, p)
+	if c.Grounded && c.Velocity.Y < 0 {
+		c.Velocity.Y = 0
+	}
+}
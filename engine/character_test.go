@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"hash"
+	"math"
+	"testing"
+
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+// testPlane is the half space below a plane through the origin, for slopes.
+type testPlane struct {
+	Normal vec3.Vec3
+}
+
+func (p testPlane) Distance(v vec3.Vec3) float32 {
+	return v.DotProduct(p.Normal)
+}
+
+func (p testPlane) Hash(h hash.Hash) {}
+
+func slope(angle float64) testPlane {
+	return testPlane{Normal: vec3.New(float32(-math.Sin(angle)), float32(math.Cos(angle)), 0)}
+}
+
+var floor = sdf.Cube{Center: vec3.New(0, -1, 0), HalfSize: vec3.New(100, 1, 100)}
+
+func simulate(c *CharacterController, world sdf.Sdf, walk vec3.Vec3, seconds float32) {
+	const dt = 1.0 / 60
+	for i := 0; i < int(seconds/dt); i++ {
+		c.Move(world, walk, dt)
+	}
+}
+
+func TestCharacterFalls(t *testing.T) {
+	c := NewCharacterController(vec3.New(0, 3, 0))
+	simulate(c, floor, vec3.Vec3{}, 2)
+	if !c.Grounded || math.Abs(float64(c.Position.Y-c.SkinWidth)) > 1e-3 || c.Velocity.Y != 0 {
+		t.Errorf("Expected to land on the floor, got %v %v %v", c.Position, c.Grounded, c.Velocity)
+	}
+	if !c.GroundNormal.ApproxEqual(vec3.New(0, 1, 0), 1e-4) {
+		t.Errorf("Expected a flat ground normal, got %v", c.GroundNormal)
+	}
+	// walking on flat ground keeps the height.
+	simulate(c, floor, vec3.New(2, 0, 0), 1)
+	if math.Abs(float64(c.Position.X-2)) > 0.05 || math.Abs(float64(c.Position.Y-c.SkinWidth)) > 1e-3 {
+		t.Errorf("Expected to walk 2 units, got %v", c.Position)
+	}
+}
+
+func TestCharacterSteps(t *testing.T) {
+	low := sdf.Union{floor, sdf.Cube{Center: vec3.New(2, 0.1, 0), HalfSize: vec3.New(0.5, 0.1, 5)}}
+	c := NewCharacterController(vec3.New(0, 0.01, 0))
+	c.Grounded = true
+	simulate(c, low, vec3.New(2, 0, 0), 1)
+	if !c.Grounded || math.Abs(float64(c.Position.Y-0.2-c.SkinWidth)) > 1e-2 {
+		t.Errorf("Expected to step up onto the ledge, got %v %v", c.Position, c.Grounded)
+	}
+	// and back down on the other side.
+	simulate(c, low, vec3.New(2, 0, 0), 1)
+	if !c.Grounded || c.Position.X < 3.5 || math.Abs(float64(c.Position.Y-c.SkinWidth)) > 1e-2 {
+		t.Errorf("Expected to walk down the ledge, got %v %v", c.Position, c.Grounded)
+	}
+
+	high := sdf.Union{floor, sdf.Cube{Center: vec3.New(2, 0.3, 0), HalfSize: vec3.New(0.5, 0.3, 5)}}
+	c = NewCharacterController(vec3.New(0, 0.01, 0))
+	c.Grounded = true
+	simulate(c, high, vec3.New(2, 0, 0.5), 1)
+	if c.Position.X > 1.5-c.Radius+1e-3 || math.Abs(float64(c.Position.Y-c.SkinWidth)) > 1e-2 {
+		t.Errorf("Expected to stop at a wall higher than a step, got %v", c.Position)
+	}
+	if c.Position.Z < 0.4 {
+		t.Errorf("Expected to slide along the wall, got %v", c.Position)
+	}
+}
+
+func TestCharacterSlopes(t *testing.T) {
+	gentle := slope(math.Pi / 9)
+	c := NewCharacterController(vec3.New(0, 0.5, 0))
+	simulate(c, gentle, vec3.Vec3{}, 1)
+	start := c.Position
+	simulate(c, gentle, vec3.New(1, 0, 0), 1)
+	rise := (c.Position.X - start.X) * float32(math.Tan(math.Pi/9))
+	if !c.Grounded || c.Position.X-start.X < 0.7 || math.Abs(float64(c.Position.Y-start.Y-rise)) > 0.01 {
+		t.Errorf("Expected to walk up a gentle slope, got %v from %v", c.Position, start)
+	}
+
+	steep := slope(math.Pi / 3)
+	c = NewCharacterController(vec3.New(0, 0.5, 0))
+	simulate(c, steep, vec3.Vec3{}, 0.5)
+	if c.Grounded {
+		t.Errorf("Expected a steep slope not to count as ground")
+	}
+	start = c.Position
+	simulate(c, steep, vec3.New(1, 0, 0), 1)
+	if c.Position.Y >= start.Y || c.Position.X >= start.X {
+		t.Errorf("Expected to slide down a steep slope while walking up, got %v from %v", c.Position, start)
+	}
+}
+
+func TestCharacterJump(t *testing.T) {
+	c := NewCharacterController(vec3.New(0, 0.01, 0))
+	simulate(c, floor, vec3.Vec3{}, 0.1)
+	c.Velocity.Y = 5
+	c.Move(floor, vec3.Vec3{}, 1.0/60)
+	if c.Grounded || c.Position.Y < 0.05 {
+		t.Errorf("Expected to leave the ground, got %v", c.Position)
+	}
+	ceiling := sdf.Union{floor, sdf.Cube{Center: vec3.New(0, 3, 0), HalfSize: vec3.New(5, 0.5, 5)}}
+	simulate(c, ceiling, vec3.Vec3{}, 2)
+	if !c.Grounded || math.Abs(float64(c.Position.Y-c.SkinWidth)) > 1e-3 {
+		t.Errorf("Expected to fall back after hitting the ceiling, got %v", c.Position)
+	}
+}
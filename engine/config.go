@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AppConfig holds the window and context options of RunAppWithConfig.
+type AppConfig struct {
+	// Width and Height is the window size in screen coordinates, ignored when fullscreen.
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Title  string `json:"title"`
+	// GLMajor and GLMinor is the requested core profile version.
+	GLMajor    int  `json:"gl-major"`
+	GLMinor    int  `json:"gl-minor"`
+	VSync      bool `json:"vsync"`
+	Fullscreen bool `json:"fullscreen"`
+	// Samples is the MSAA sample count, 0 disables multisampling.
+	Samples int `json:"samples"`
+	// TickRate is the number of updates per second.
+	TickRate int `json:"tick-rate"`
+	// GLDebug requests a debug context, which reports more through KHR_debug.
+	GLDebug bool `json:"gl-debug"`
+	// Console starts the remote console of a ConsoleContext, off by default
+	// since anything local can connect to it.
+	Console bool `json:"console"`
+	// ScreenshotDir is the directory console screenshots are written to.
+	ScreenshotDir string `json:"screenshot-dir"`
+}
+
+func DefaultAppConfig() AppConfig {
+	return AppConfig{Width: 512, Height: 512, Title: "Testing", GLMajor: 4, GLMinor: 1, TickRate: 60, ScreenshotDir: "screenshots"}
+}
+
+// configOption is a field of AppConfig with the name used by flags, the environment and files.
+type configOption struct {
+	name  string
+	usage string
+	value interface{}
+}
+
+func (c *AppConfig) options() []configOption {
+	return []configOption{
+		{"width", "window width", &c.Width},
+		{"height", "window height", &c.Height},
+		{"title", "window title", &c.Title},
+		{"gl-major", "OpenGL major version", &c.GLMajor},
+		{"gl-minor", "OpenGL minor version", &c.GLMinor},
+		{"vsync", "wait for vertical sync", &c.VSync},
+		{"fullscreen", "fullscreen on the primary monitor", &c.Fullscreen},
+		{"samples", "MSAA samples, 0 disables multisampling", &c.Samples},
+		{"tick-rate", "updates per second", &c.TickRate},
+		{"gl-debug", "create an OpenGL debug context", &c.GLDebug},
+		{"console", "accept remote console commands", &c.Console},
+		{"screenshot-dir", "directory for console screenshots", &c.ScreenshotDir},
+	}
+}
+
+func (o configOption) set(s string) error {
+	switch p := o.value.(type) {
+	case *int:
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%v: %w", o.name, err)
+		}
+		*p = v
+	case *bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%v: %w", o.name, err)
+		}
+		*p = v
+	case *string:
+		*p = s
+	}
+	return nil
+}
+
+// LoadFile reads options from a JSON file, options missing from the file are kept.
+func (c *AppConfig) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+	return nil
+}
+
+// LoadEnv reads options from environment variables named by prefix and the
+// upper case option, for example SUPERSDF_WIDTH or SUPERSDF_GL_MAJOR.
+func (c *AppConfig) LoadEnv(prefix string) error {
+	for _, o := range c.options() {
+		name := prefix + strings.ToUpper(strings.ReplaceAll(o.name, "-", "_"))
+		if s, ok := os.LookupEnv(name); ok {
+			if err := o.set(s); err != nil {
+				return fmt.Errorf("%v: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RegisterFlags defines a flag for each option, defaulting to the current
+// values, so parsing the flags overrides the file and environment.
+func (c *AppConfig) RegisterFlags(flags *flag.FlagSet) {
+	for _, o := range c.options() {
+		switch p := o.value.(type) {
+		case *int:
+			flags.IntVar(p, o.name, *p, o.usage)
+		case *bool:
+			flags.BoolVar(p, o.name, *p, o.usage)
+		case *string:
+			flags.StringVar(p, o.name, *p, o.usage)
+		}
+	}
+}
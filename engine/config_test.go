@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.json")
+	if err := os.WriteFile(path, []byte(`{"width": 800, "height": 600, "title": "From file", "samples": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_HEIGHT", "700")
+	t.Setenv("TEST_GL_MINOR", "3")
+	t.Setenv("TEST_VSYNC", "true")
+
+	config := DefaultAppConfig()
+	if err := config.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadEnv("TEST_"); err != nil {
+		t.Fatal(err)
+	}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.RegisterFlags(flags)
+	if err := flags.Parse([]string{"-title", "From flags", "-fullscreen", "-console"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := AppConfig{Width: 800, Height: 700, Title: "From flags", GLMajor: 4, GLMinor: 3, VSync: true, Fullscreen: true, Samples: 2, TickRate: 60, Console: true, ScreenshotDir: "screenshots"}
+	if config != expected {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+}
+
+func TestAppConfigErrors(t *testing.T) {
+	config := DefaultAppConfig()
+	if err := config.LoadFile(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected a not exist error, got %v", err)
+	}
+	t.Setenv("TEST_WIDTH", "wide")
+	if err := config.LoadEnv("TEST_"); err == nil {
+		t.Error("Expected an error for an invalid width")
+	}
+	if config.Width != 512 {
+		t.Errorf("Expected the width to be kept, got %v", config.Width)
+	}
+}
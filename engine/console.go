@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/supersdf-go/engine/console"
+	sdf "github.com/supersdf-go/engine/sdf"
+)
+
+// ConsoleContext is implemented by contexts that can be controlled remotely.
+// When AppConfig.Console is set, RunApp listens on ConsoleAddress and runs the
+// commands from its main loop.
+type ConsoleContext interface {
+	// ConsoleAddress returns the network and address to listen on, for example "tcp", "localhost:4243".
+	ConsoleAddress() (network, address string)
+	// SetupConsole lets the context add or replace handlers. The engine
+	// handles add-primitive, remove-node, screenshot and reload-shader.
+	SetupConsole(handlers *console.Handlers)
+}
+
+// consoleNode is an Sdf added through the console, drawn together with the scene.
+type consoleNode struct {
+	name string
+	sdf  sdf.Sdf
+}
+
+// startConsole listens for console commands. Screenshots are written within
+// screenshotDir.
+func startConsole(c ConsoleContext, screen *Screen, reloader *SceneReloader, screenshotDir string) *console.Server {
+	handlers := console.Handlers{
+		AddPrimitive: screen.addConsoleNode,
+		RemoveNode:   screen.removeConsoleNode,
+		Screenshot: func(path string) error {
+			path = filepath.Join(screenshotDir, path)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			return screen.Screenshot(path)
+		},
+		ReloadShader: func() error {
+			scene := screen.scene
+			if reloader != nil {
+				loaded, err := LoadSceneFile(reloader.Path)
+				if err != nil {
+					return err
+				}
+				reloader.Scene = loaded
+				scene = loaded
+			}
+			return screen.SetScene(scene)
+		},
+	}
+	c.SetupConsole(&handlers)
+	network, address := c.ConsoleAddress()
+	server, err := console.Listen(network, address, handlers)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "console disabled: %v\n", err)
+		return nil
+	}
+	fmt.Printf("console listening on %v\n", server.Addr())
+	return server
+}
+
+// composeScene adds the node and console SDFs to scene.
+func (s *Screen) composeScene(scene sdf.Sdf) sdf.Sdf {
+	if s.nodeScene == nil && len(s.consoleNodes) == 0 {
+		return scene
+	}
+	result := sdf.Union{scene}
+	if s.nodeScene != nil {
+		result = append(result, s.nodeScene)
+	}
+	for _, n := range s.consoleNodes {
+		result = append(result, n.sdf)
+	}
+	return result
+}
+
+func (s *Screen) addConsoleNode(node sdf.Sdf) (string, error) {
+	name := fmt.Sprintf("node-%v", s.nextConsoleNode)
+	s.consoleNodes = append(s.consoleNodes, consoleNode{name: name, sdf: node})
+	if err := s.SetScene(s.scene); err != nil {
+		s.consoleNodes = s.consoleNodes[:len(s.consoleNodes)-1]
+		return "", err
+	}
+	s.nextConsoleNode++
+	return name, nil
+}
+
+func (s *Screen) removeConsoleNode(name string) error {
+	for i, n := range s.consoleNodes {
+		if n.name != name {
+			continue
+		}
+		old := s.consoleNodes
+		s.consoleNodes = append(append([]consoleNode{}, old[:i]...), old[i+1:]...)
+		if err := s.SetScene(s.scene); err != nil {
+			s.consoleNodes = old
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("no node named %q", name)
+}
+
+// Screenshot writes the current contents of the screen to a PNG file.
+func (s *Screen) Screenshot(path string) error {
+	img := s.Image()
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,148 @@
+package console
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+
+	remotevm "github.com/rolfrm/remotevm"
+)
+
+// Client sends commands to a console Server.
+type Client struct {
+	conn   net.Conn
+	stream remotevm.CodeStream
+	reader *bufio.Reader
+}
+
+func Dial(network, address string) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, stream: remotevm.CodeStream{Stream: conn}, reader: bufio.NewReader(conn)}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// call invokes a command and reads back its result. Error results are returned as errors.
+func (c *Client) call(id int, args ...interface{}) (interface{}, error) {
+	// remotevm pops the arguments, so they are pushed in reverse.
+	for i := len(args) - 1; i >= 0; i-- {
+		c.stream.Write(remotevm.Op_Ld, args[i])
+	}
+	c.stream.Write(remotevm.Op_Call, byte(id), remotevm.Op_Return)
+	value, err := readValue(c.reader)
+	if err == io.EOF {
+		return nil, errors.New("console: connection closed by server")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := value.(error); ok {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *Client) SetCamera(x, y, z float32) error {
+	_, err := c.call(cmdSetCamera, float64(x), float64(y), float64(z))
+	return err
+}
+
+// AddPrimitive adds a primitive written in the sdf text format and returns the name of the new node.
+func (c *Client) AddPrimitive(src string) (string, error) {
+	value, err := c.call(cmdAddPrimitive, src)
+	if err != nil {
+		return "", err
+	}
+	name, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("console: unexpected result %v", value)
+	}
+	return name, nil
+}
+
+func (c *Client) RemoveNode(name string) error {
+	_, err := c.call(cmdRemoveNode, name)
+	return err
+}
+
+// Screenshot saves the next frame as a PNG at path on the server side.
+func (c *Client) Screenshot(path string) error {
+	_, err := c.call(cmdScreenshot, path)
+	return err
+}
+
+func (c *Client) ReloadShader() error {
+	_, err := c.call(cmdReloadShader)
+	return err
+}
+
+func readSleb(r *bufio.Reader) (int64, error) {
+	var value int64
+	var shift uint
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				value |= -1 << shift
+			}
+			return value, nil
+		}
+	}
+}
+
+// readValue decodes a value written by remotevm's Op_Return.
+func readValue(r *bufio.Reader) (interface{}, error) {
+	t, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch remotevm.Type(t) {
+	case remotevm.Type_Nothing:
+		return nil, nil
+	case remotevm.Type_I64:
+		return readSleb(r)
+	case remotevm.Type_F64:
+		var bits [8]byte
+		if _, err := io.ReadFull(r, bits[:]); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(bits[:])), nil
+	case remotevm.Type_String, remotevm.Type_U8_Array:
+		n, err := readSleb(r)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("console: invalid length %v", n)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if remotevm.Type(t) == remotevm.Type_String {
+			return string(data), nil
+		}
+		return data, nil
+	case remotevm.Type_Error:
+		msg, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		return errors.New(fmt.Sprint(msg)), nil
+	}
+	return nil, fmt.Errorf("console: cannot read value of type %v", remotevm.Type(t))
+}
@@ -0,0 +1,232 @@
+// Remote control of a running app.
+//
+// The server accepts remotevm code streams on a local socket. Streams are
+// decoded on a goroutine per connection, but the commands themselves are
+// queued and executed when the main loop calls Poll, so handlers can safely
+// use GL and the game state.
+
+package console
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+
+	remotevm "github.com/rolfrm/remotevm"
+	sdf "github.com/supersdf-go/engine/sdf"
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+// Command ids, the order of the command table shared by Server and Client.
+const (
+	cmdSetCamera = iota
+	cmdAddPrimitive
+	cmdRemoveNode
+	cmdScreenshot
+	cmdReloadShader
+)
+
+var ErrNotSupported = errors.New("console: command not supported")
+
+var ErrClosed = errors.New("console: server closed")
+
+// ErrInvalidPath is returned for screenshot paths that are absolute or leave
+// the directory of the server.
+var ErrInvalidPath = errors.New("console: path must be relative and not contain ..")
+
+// Handlers implement the console commands. Nil handlers report ErrNotSupported.
+type Handlers struct {
+	SetCamera func(position vec3.Vec3) error
+	// AddPrimitive adds an Sdf to the scene and returns the name of the new node.
+	AddPrimitive func(s sdf.Sdf) (string, error)
+	RemoveNode   func(name string) error
+	// Screenshot gets a relative path that stays within its directory.
+	Screenshot   func(path string) error
+	ReloadShader func() error
+}
+
+type job struct {
+	fn   func() interface{}
+	done chan interface{}
+}
+
+type Server struct {
+	listener net.Listener
+	commands []remotevm.Command
+	jobs     chan job
+	closed   chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+// Listen starts accepting connections on the given network and address, for
+// example "tcp", "localhost:4243" or "unix", "/tmp/supersdf.sock".
+func Listen(network, address string, handlers Handlers) (*Server, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener: listener,
+		jobs:     make(chan job),
+		closed:   make(chan struct{}),
+		conns:    map[net.Conn]struct{}{},
+	}
+	s.commands = s.buildCommands(handlers)
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			remotevm.EvalStream(s.commands, conn, conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// Poll runs the queued commands on the calling goroutine. Call it once per frame from the main loop.
+func (s *Server) Poll() {
+	for {
+		select {
+		case j := <-s.jobs:
+			j.done <- runJob(j.fn)
+		default:
+			return
+		}
+	}
+}
+
+func runJob(fn func() interface{}) (result interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = fmt.Errorf("console: %v", r)
+		}
+	}()
+	return fn()
+}
+
+// run queues fn for the main loop and waits for its result.
+func (s *Server) run(fn func() interface{}) interface{} {
+	j := job{fn: fn, done: make(chan interface{}, 1)}
+	select {
+	case s.jobs <- j:
+	case <-s.closed:
+		return ErrClosed
+	}
+	return <-j.done
+}
+
+// Close stops accepting connections and closes the open ones.
+func (s *Server) Close() error {
+	close(s.closed)
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// result turns a handler error into the value written back to the client.
+func result(value interface{}, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return value
+}
+
+func (s *Server) buildCommands(h Handlers) []remotevm.Command {
+	commands := make([]remotevm.Command, cmdReloadShader+1)
+	commands[cmdSetCamera] = remotevm.Command{
+		Name:      "set-camera",
+		Arguments: []remotevm.Type{remotevm.Type_F64, remotevm.Type_F64, remotevm.Type_F64},
+		Func: func(x, y, z float64) interface{} {
+			if h.SetCamera == nil {
+				return ErrNotSupported
+			}
+			return s.run(func() interface{} {
+				return result(nil, h.SetCamera(vec3.New(float32(x), float32(y), float32(z))))
+			})
+		},
+	}
+	commands[cmdAddPrimitive] = remotevm.Command{
+		Name:      "add-primitive",
+		Arguments: []remotevm.Type{remotevm.Type_String},
+		Func: func(src string) interface{} {
+			if h.AddPrimitive == nil {
+				return ErrNotSupported
+			}
+			primitive, err := sdf.Parse(src)
+			if err != nil {
+				return err
+			}
+			return s.run(func() interface{} {
+				return result(h.AddPrimitive(primitive))
+			})
+		},
+	}
+	commands[cmdRemoveNode] = remotevm.Command{
+		Name:      "remove-node",
+		Arguments: []remotevm.Type{remotevm.Type_String},
+		Func: func(name string) interface{} {
+			if h.RemoveNode == nil {
+				return ErrNotSupported
+			}
+			return s.run(func() interface{} {
+				return result(nil, h.RemoveNode(name))
+			})
+		},
+	}
+	commands[cmdScreenshot] = remotevm.Command{
+		Name:      "screenshot",
+		Arguments: []remotevm.Type{remotevm.Type_String},
+		Func: func(path string) interface{} {
+			if h.Screenshot == nil {
+				return ErrNotSupported
+			}
+			if !filepath.IsLocal(path) {
+				return ErrInvalidPath
+			}
+			return s.run(func() interface{} {
+				return result(nil, h.Screenshot(path))
+			})
+		},
+	}
+	commands[cmdReloadShader] = remotevm.Command{
+		Name: "reload-shader",
+		Func: func() interface{} {
+			if h.ReloadShader == nil {
+				return ErrNotSupported
+			}
+			return s.run(func() interface{} {
+				return result(nil, h.ReloadShader())
+			})
+		},
+	}
+	return commands
+}
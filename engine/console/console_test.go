@@ -0,0 +1,144 @@
+package console
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestConsoleLoopback(t *testing.T) {
+	camera := vec3.Vec3{}
+	nodes := map[string]sdf.Sdf{}
+	reloads := 0
+	var screenshots []string
+	var polling atomic.Bool
+	onMainLoop := func() {
+		if !polling.Load() {
+			t.Error("Handler called outside of Poll")
+		}
+	}
+
+	server, err := Listen("tcp", "127.0.0.1:0", Handlers{
+		SetCamera: func(p vec3.Vec3) error {
+			onMainLoop()
+			camera = p
+			return nil
+		},
+		AddPrimitive: func(s sdf.Sdf) (string, error) {
+			onMainLoop()
+			name := "node-" + string(rune('a'+len(nodes)))
+			nodes[name] = s
+			return name, nil
+		},
+		RemoveNode: func(name string) error {
+			onMainLoop()
+			if _, ok := nodes[name]; !ok {
+				return errors.New("no such node: " + name)
+			}
+			delete(nodes, name)
+			return nil
+		},
+		Screenshot: func(path string) error {
+			onMainLoop()
+			screenshots = append(screenshots, path)
+			return nil
+		},
+		ReloadShader: func() error {
+			onMainLoop()
+			reloads++
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the main loop, handlers may only run while Poll is called.
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			polling.Store(true)
+			server.Poll()
+			polling.Store(false)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	client, err := Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.SetCamera(1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	name, err := client.AddPrimitive("(sphere 0 1 0 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddPrimitive("(sphere 0 1"); err == nil || !strings.Contains(err.Error(), "1:") {
+		t.Errorf("Expected a parse error with a position, got %v", err)
+	}
+	if err := client.RemoveNode("missing"); err == nil {
+		t.Error("Expected an error for a missing node")
+	}
+	if err := client.ReloadShader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Screenshot("shots/out.png"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"../out.png", "/tmp/out.png", "shots/../../out.png", ""} {
+		if err := client.Screenshot(path); err == nil || err.Error() != ErrInvalidPath.Error() {
+			t.Errorf("Expected %q to be rejected, got %v", path, err)
+		}
+	}
+	client.Close()
+
+	close(stop)
+	<-stopped
+	if err := server.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if camera != vec3.New(1, 2, 3) {
+		t.Errorf("Expected camera at 1 2 3, got %v", camera)
+	}
+	if !sdf.CompareSdfs(nodes[name], sdf.Sphere{Center: vec3.New(0, 1, 0), Radius: 2}) {
+		t.Errorf("Expected primitive %v to be added, got %v", name, nodes)
+	}
+	if reloads != 1 {
+		t.Errorf("Expected one reload, got %v", reloads)
+	}
+	if len(screenshots) != 1 || screenshots[0] != "shots/out.png" {
+		t.Errorf("Expected only the relative screenshot, got %v", screenshots)
+	}
+}
+
+func TestConsoleNotSupported(t *testing.T) {
+	server, err := Listen("tcp", "127.0.0.1:0", Handlers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, err := Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.Screenshot("out.png"); err == nil || err.Error() != ErrNotSupported.Error() {
+		t.Errorf("Expected not supported error, got %v", err)
+	}
+}
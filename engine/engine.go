@@ -1,13 +1,15 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
+	"image"
 	"runtime"
 	"time"
-	"unsafe"
 
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/supersdf-go/engine/console"
 	sdf "github.com/supersdf-go/engine/sdf"
 	"github.com/supersdf-go/engine/vec2"
 	"github.com/supersdf-go/engine/vec3"
@@ -15,19 +17,15 @@ import (
 	"github.com/supersdf-go/engine/vec4"
 )
 
-type EventManager struct {
-}
-type KeyEvent struct {
-	KeyCode int
-}
-
-func (evtMgt *EventManager) ReadKeyEvents(output *[]KeyEvent) {
-
-}
-
+// MainContext is the game run by RunApp. Update is called at a fixed rate
+// with dt in seconds, Draw once per frame with dt the seconds since the last
+// frame and alpha from 0 to 1 telling how far the frame is between the last
+// update and the next, for interpolation.
 type MainContext interface {
-	Update(eventManager *EventManager)
-	Draw(screen *Screen)
+	Update(eventManager *EventManager, dt float32)
+	Draw(screen *Screen, dt, alpha float32)
+	// Layout gets the window size in screen coordinates when it changes and
+	// returns the wanted screen size, see layoutScreen.
 	Layout(width, height int) (int, int)
 }
 
@@ -54,8 +52,8 @@ func NewShaderProgram(program uint32) ShaderProgram {
 	}
 }
 
-func genGlslFragment() string {
-	s := sdf.Union{sdf.Color{
+func defaultScene() sdf.Sdf {
+	return sdf.Union{sdf.Color{
 		Color: vec3.New(1, 0, 0),
 		Sub:   sdf.Sphere{Center: vec3.New(0, 0, 0), Radius: 1.0},
 	}, sdf.Color{
@@ -69,8 +67,11 @@ func genGlslFragment() string {
 		Sub:   sdf.Sphere{Center: vec3.New(1, 0, 1), Radius: 1.0},
 	},
 	}
+}
+
+func genGlslFragment() (string, error) {
 	//s := sdf.Sphere{Center: vec3.New(0, 0, 0), Radius: 1}
-	return SDF2GLSL(s)
+	return SDF2GLSL(defaultScene())
 
 }
 func measureTime(fn func()) time.Duration {
@@ -86,65 +87,116 @@ func measureTime(fn func()) time.Duration {
 }
 
 func RunApp(ctx MainContext) error {
+	return RunAppWithConfig(ctx, DefaultAppConfig())
+}
+
+// RunAppWithConfig opens a window and runs ctx until the window is closed.
+// Errors setting up the window, GL or the shaders are returned.
+func RunAppWithConfig(ctx MainContext, config AppConfig) error {
 	runtime.LockOSThread()
-	err := glfw.Init()
-	if err != nil {
-		panic(err)
+	defer runtime.UnlockOSThread()
+	if err := glfw.Init(); err != nil {
+		return fmt.Errorf("initializing glfw: %w", err)
 	}
 	defer glfw.Terminate()
-	glfw.WindowHint(glfw.ContextVersionMajor, 4)
-	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.ContextVersionMajor, config.GLMajor)
+	glfw.WindowHint(glfw.ContextVersionMinor, config.GLMinor)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, gl.TRUE)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
-	window, err := glfw.CreateWindow(512, 512, "Testing", nil, nil)
+	glfw.WindowHint(glfw.Samples, config.Samples)
+	if config.GLDebug {
+		glfw.WindowHint(glfw.OpenGLDebugContext, glfw.True)
+	}
+	// size the window by the monitor scale, the framebuffer is in pixels.
+	glfw.WindowHint(glfw.ScaleToMonitor, glfw.True)
+	glfw.WindowHint(glfw.CocoaRetinaFramebuffer, glfw.True)
+	var monitor *glfw.Monitor
+	width, height := config.Width, config.Height
+	if config.Fullscreen {
+		monitor = glfw.GetPrimaryMonitor()
+		if monitor == nil {
+			return errors.New("fullscreen: no monitor found")
+		}
+		mode := monitor.GetVideoMode()
+		width, height = mode.Width, mode.Height
+	}
+	window, err := glfw.CreateWindow(width, height, config.Title, monitor, nil)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("creating window: %w", err)
 	}
+	defer window.Destroy()
+	window.MakeContextCurrent()
 	if err := gl.Init(); err != nil {
-		panic(err)
+		return fmt.Errorf("initializing OpenGL: %w", err)
 	}
+	if config.VSync {
+		glfw.SwapInterval(1)
+	} else {
+		glfw.SwapInterval(0)
+	}
+	enableGLDebug()
 
-	window.MakeContextCurrent()
 	gl.Enable(gl.CULL_FACE)
 	gl.Enable(gl.DEPTH_TEST)
-	fmt.Printf("Shader code: %v\n", genGlslFragment())
-	shaderProgram, e := compileShaders(vertexShaderSource, genGlslFragment())
-	if e != nil {
-		panic(e)
+	if config.Samples > 0 {
+		gl.Enable(gl.MULTISAMPLE)
 	}
-	time := measureTime(func() {
-		for i := 0; i < 0; i++ {
-			sp, e := compileShaders(vertexShaderSource, genGlslFragment())
-			if e != nil {
-				panic(e)
-			}
-			gl.DeleteProgram(sp)
-		}
-	})
-	fmt.Printf("Compiled shader: %v", time.String())
-
-	shaderProgram2, e := compileShaders(vertexShader2Source, fragmentShader2Source)
-	if e != nil {
-		panic(e)
+	backend, err := newGLBackend()
+	if err != nil {
+		return err
 	}
 
-	s1 := NewShaderProgram(shaderProgram)
-
-	screen := Screen{cameraTransform: Mat4Identity()}
-	eventMgr := EventManager{}
-	screen.ScreenWidth, screen.ScreenHeight = window.GetSize()
+	screen := Screen{cameraTransform: Mat4Identity(), scene: defaultScene(), backend: backend}
+	eventMgr := NewEventManager()
+	eventMgr.Attach(glfwEventSource{window: window})
+	layoutChanged := true
+	window.SetFramebufferSizeCallback(func(w *glfw.Window, width, height int) {
+		layoutChanged = true
+	})
 
-	screen.s.program = 100000
-	screen.s1 = s1
-	screen.s2 = NewShaderProgram(shaderProgram2)
-	screen.UseProgram(s1)
+	var reloader *SceneReloader
+	if src, ok := ctx.(SceneSource); ok {
+		reloader = NewSceneReloader(src.SceneFile())
+		reloader.Title = config.Title
+	}
+	if c, ok := ctx.(ConsoleContext); ok && config.Console {
+		consoleServer := startConsole(c, &screen, reloader, config.ScreenshotDir)
+		if consoleServer != nil {
+			defer consoleServer.Close()
+			screen.console = consoleServer
+		}
+	}
+	timestep := newFixedTimestep(config.TickRate)
+	lastFrame := time.Now()
 	for !window.ShouldClose() {
-		ctx.Update(&eventMgr)
-		w, h := window.GetSize()
-		ctx.Layout(w, h)
-		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+		if reloader != nil {
+			screen.reloadScene(reloader, window)
+		}
+		now := time.Now()
+		elapsed := now.Sub(lastFrame)
+		lastFrame = now
+		screen.stats.Add(elapsed)
+		ticks, alpha := timestep.advance(elapsed)
+		for i := 0; i < ticks; i++ {
+			// events are delivered to the first update of a frame.
+			eventMgr.BeginFrame()
+			screen.pickEvents(eventMgr)
+			ctx.Update(eventMgr, timestep.dt())
+		}
+		if layoutChanged {
+			layoutChanged = false
+			w, h := window.GetSize()
+			fbWidth, fbHeight := window.GetFramebufferSize()
+			screen.layout(ctx, w, h, fbWidth, fbHeight)
+		}
+		screen.Clear()
+		screen.beginFrame()
 
-		ctx.Draw(&screen)
+		ctx.Draw(&screen, float32(elapsed.Seconds()), alpha)
+		if screen.console != nil {
+			// after drawing, so screenshots see the finished frame.
+			screen.console.Poll()
+		}
 
 		window.SwapBuffers()
 		glfw.PollEvents()
@@ -160,6 +212,7 @@ func compileShaders(vertexSource string, fragmentSource string) (uint32, error)
 
 	fragmentShader, err := compileShader(fragmentSource, gl.FRAGMENT_SHADER)
 	if err != nil {
+		gl.DeleteShader(vertexShader)
 		return 0, err
 	}
 
@@ -177,7 +230,10 @@ func compileShaders(vertexSource string, fragmentSource string) (uint32, error)
 		log := make([]byte, logLength)
 		gl.GetProgramInfoLog(shaderProgram, logLength, nil, &log[0])
 
-		return 0, fmt.Errorf("linking program failed: %v", string(log))
+		gl.DeleteProgram(shaderProgram)
+		gl.DeleteShader(vertexShader)
+		gl.DeleteShader(fragmentShader)
+		return 0, &ShaderError{Stage: "link", Log: string(log)}
 	}
 
 	gl.DeleteShader(vertexShader)
@@ -202,19 +258,41 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 		log := make([]byte, logLength)
 		gl.GetShaderInfoLog(shader, logLength, nil, &log[0])
 
-		return 0, fmt.Errorf("compiling shader failed: %v", string(log))
+		gl.DeleteShader(shader)
+		stage := "vertex"
+		if shaderType == gl.FRAGMENT_SHADER {
+			stage = "fragment"
+		}
+		return 0, &ShaderError{Stage: stage, Log: string(log), Source: source}
 	}
 
 	return shader, nil
 }
 
 type Screen struct {
-	cameraPosition            vec3.Vec3
-	cameraTransform           Mat4
-	s                         ShaderProgram
-	s1                        ShaderProgram
-	s2                        ShaderProgram
+	cameraPosition  vec3.Vec3
+	cameraTransform Mat4
+	backend         Backend
+	// ScreenWidth and ScreenHeight is the size of the screen in pixels.
 	ScreenWidth, ScreenHeight int
+	// Scale is the number of pixels per window coordinate, 2 on most high DPI displays.
+	Scale           float32
+	area            image.Rectangle
+	fbHeight        int
+	scene           sdf.Sdf
+	consoleNodes    []consoleNode
+	nextConsoleNode int
+	console         *console.Server
+	stats           FrameStats
+	// culling counts the current frame, lastCulling the one before.
+	culling, lastCulling CullStats
+	// sdfCamera is the camera transform of the last DrawSdf, used for picking.
+	sdfCamera Mat4
+	// brickMap is the brick map of the scene, culled with brickCamera.
+	brickMap    *sdf.BrickMap
+	brickCamera Mat4
+	// nodeScene is the Sdf of the drawn nodes, triedNodeScene the last one set.
+	nodeScene, triedNodeScene sdf.Sdf
 }
 
 func (s *Screen) SetCamera(viewTransform Mat4, cameraPosition Vec3, cameraUp Vec3, cameraRight Vec3) {
@@ -224,45 +302,46 @@ func (s *Screen) SetCamera(viewTransform Mat4, cameraPosition Vec3, cameraUp Vec
 	s.cameraTransform = viewTransform.Multiply(camRotation.Multiply(camTranslation))
 }
 
-func (s *Screen) UseProgram(newShader ShaderProgram) {
-	if s.s.program != newShader.program {
-		s.s = newShader
-		gl.UseProgram(newShader.program)
-		//fmt.Printf("use program %v\n", newShader.program)
-	}
-}
-
 func (s *Screen) Draw(polygon Polygon, modelTransform Mat4, color vec4.Vec4) {
-	s.UseProgram(s.s1)
 	modelView := s.cameraTransform.Multiply(modelTransform)
-	//fmt.Printf("s: %v %v\n", modelView, polygon.buffer2)
-	gl.UniformMatrix4fv(s.s.modelView, 1, false, &modelView[0])
-	gl.UniformMatrix4fv(s.s.model, 1, false, &modelTransform[0])
-	gl.Uniform3f(s.s.cameraPosition, s.cameraPosition.X, s.cameraPosition.Y, s.cameraPosition.Z)
-
-	gl.Uniform4f(s.s.color, color.X, color.Y, color.Z, color.W)
-	gl.BindVertexArray(polygon.vao)
-	gl.DrawArrays(gl.TRIANGLES, 0, int32(polygon.count))
-	gl.BindVertexArray(0)
+	s.sdfCamera = s.cameraTransform
+	s.selectBricks()
+	s.backend.DrawSdf(polygon, modelView, modelTransform, s.cameraPosition, color)
 }
 
 func (s *Screen) DrawTextured(polygon Polygon, modelTransform Mat4, texture uint32) {
-	s.UseProgram(s.s2)
 	modelView := s.cameraTransform.Multiply(modelTransform)
-	gl.UniformMatrix4fv(s.s.modelView, 1, false, &modelView[0])
-	gl.UniformMatrix4fv(s.s.model, 1, false, &modelTransform[0])
-	gl.Uniform3f(s.s.cameraPosition, s.cameraPosition.X, s.cameraPosition.Y, s.cameraPosition.Z)
-	gl.Uniform1i(s.s.texture, 0)
+	s.backend.DrawTextured(polygon, modelView, modelTransform, texture)
+}
+
+// Viewport sets the area drawn to in pixels, from the bottom left corner.
+func (s *Screen) Viewport(x, y, width, height int) {
+	s.backend.Viewport(x, y, width, height)
+}
+
+// Clear clears the color and depth of the current render target.
+func (s *Screen) Clear() {
+	s.backend.Clear()
+}
 
-	// Activate texture unit 0
-	gl.ActiveTexture(gl.TEXTURE0)
+// NewFramebuffer creates a framebuffer that can be drawn to and used as a texture with DrawTextured.
+func (s *Screen) NewFramebuffer(width, height int) (*Framebuffer, error) {
+	return s.backend.NewFramebuffer(width, height)
+}
 
-	// Bind the texture to texture unit 0
-	gl.BindTexture(gl.TEXTURE_2D, texture)
+// BindFramebuffer sets the render target, nil draws to the screen.
+func (s *Screen) BindFramebuffer(fb *Framebuffer) {
+	s.backend.BindFramebuffer(fb)
+}
+
+// FrameStats returns the times of the last frames.
+func (s *Screen) FrameStats() *FrameStats {
+	return &s.stats
+}
 
-	gl.BindVertexArray(polygon.vao)
-	gl.DrawArrays(gl.TRIANGLES, 0, int32(polygon.count))
-	gl.BindVertexArray(0)
+// Image returns the current contents of the screen.
+func (s *Screen) Image() *image.RGBA {
+	return s.backend.ReadPixels(s.area)
 }
 
 var (
@@ -310,61 +389,46 @@ var (
 	` + "\x00"
 )
 
+// Polygon is a triangle list. The vertex data is kept on the CPU and
+// uploaded by the backend when drawn, copies share the same data.
 type Polygon struct {
-	Color   vec4.Vec4
+	Color vec4.Vec4
+	mesh  *mesh
+}
+
+type mesh struct {
+	vertices []Vec3
+	uvs      []vec2.Vec2
+	bounds   AABB
+	dirty    bool
+
+	// GL buffers
 	vao     uint32
 	buffer  uint32
 	buffer2 uint32
-	count   uint32
 }
 
 func (p *Polygon) Load3D(vertices []Vec3) {
 	p.Load3DUv(vertices, nil)
 }
-func (p *Polygon) Load3DUv(vertices []Vec3, uvs []vec2.Vec2) {
-
-	vbo := p.buffer
-	if vbo == 0 {
-		var vao uint32
-		gl.GenVertexArrays(1, &vao)
-
-		gl.GenBuffers(1, &vbo)
-		p.buffer = vbo
-		p.vao = vao
-		gl.BindVertexArray(p.vao)
-		gl.BindBuffer(gl.ARRAY_BUFFER, p.buffer)
-		gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-		gl.EnableVertexAttribArray(0)
-		if uvs != nil {
-			gl.GenBuffers(1, &p.buffer2)
-			gl.BindBuffer(gl.ARRAY_BUFFER, p.buffer2)
-			gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
-			gl.EnableVertexAttribArray(1)
-
-		}
 
+func (p *Polygon) Load3DUv(vertices []Vec3, uvs []vec2.Vec2) {
+	if p.mesh == nil {
+		p.mesh = &mesh{}
 	}
-	gl.BindVertexArray(p.vao)
-	p.count = uint32(len(vertices))
-	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-	arr := make([]float32, len(vertices)*3)
-	for i, v := range vertices {
-		arr[i*3] = v.X
-		arr[i*3+1] = v.Y
-		arr[i*3+2] = v.Z
-	}
-	gl.BufferData(gl.ARRAY_BUFFER, 3*4*len(vertices), unsafe.Pointer(&arr[0]), gl.STATIC_DRAW)
+	p.mesh.vertices = append([]Vec3{}, vertices...)
+	p.mesh.bounds = AABBFromPoints(vertices)
+	p.mesh.uvs = nil
 	if uvs != nil {
-		gl.BindBuffer(gl.ARRAY_BUFFER, p.buffer2)
-
-		arr := make([]float32, len(uvs)*2)
-		for i, v := range uvs {
-			arr[i*2] = v.X
-			arr[i*2+1] = v.Y
-		}
-		gl.BufferData(gl.ARRAY_BUFFER, 2*4*len(uvs), unsafe.Pointer(&arr[0]), gl.STATIC_DRAW)
-
+		p.mesh.uvs = append([]vec2.Vec2{}, uvs...)
 	}
-	gl.BindVertexArray(0)
+	p.mesh.dirty = true
+}
 
+// Bounds is the bounding box of the vertices in model space.
+func (p *Polygon) Bounds() AABB {
+	if p.mesh == nil {
+		return AABB{}
+	}
+	return p.mesh.bounds
 }
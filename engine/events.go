@@ -0,0 +1,255 @@
+package engine
+
+import sdf "github.com/supersdf-go/engine/sdf"
+
+// ButtonAction is the state change reported by key and mouse button events.
+type ButtonAction int
+
+const (
+	Released ButtonAction = iota
+	Pressed
+	Repeated
+)
+
+// Modifier is a bit set of the modifier keys held during an event.
+type Modifier int
+
+const (
+	ModShift Modifier = 1 << iota
+	ModControl
+	ModAlt
+	ModSuper
+)
+
+// Event times are in seconds since the event source started.
+
+type KeyEvent struct {
+	KeyCode  Key
+	Scancode int
+	Action   ButtonAction
+	Mods     Modifier
+	Time     float64
+}
+
+type CharEvent struct {
+	Char rune
+	Time float64
+}
+
+type MouseMoveEvent struct {
+	X, Y float64
+	Time float64
+}
+
+type MouseButtonEvent struct {
+	Button MouseButton
+	Action ButtonAction
+	Mods   Modifier
+	// X and Y is the cursor position when the button changed.
+	X, Y float64
+	Time float64
+}
+
+type ScrollEvent struct {
+	DX, DY float64
+	Time   float64
+}
+
+type ResizeEvent struct {
+	Width, Height int
+	Time          float64
+}
+
+// PickEvent is a mouse button press cast into the SDF scene, with the camera
+// of the last drawn frame.
+type PickEvent struct {
+	MouseButtonEvent
+	Ray sdf.Ray
+	// Hit is only set when OK is true.
+	Hit sdf.Hit
+	OK  bool
+}
+
+// EventSource feeds input events into an EventManager, the GLFW window in
+// RunApp or synthetic events in tests.
+type EventSource interface {
+	Attach(m *EventManager)
+}
+
+type frameEvents struct {
+	keys    []KeyEvent
+	chars   []CharEvent
+	moves   []MouseMoveEvent
+	buttons []MouseButtonEvent
+	scrolls []ScrollEvent
+	resizes []ResizeEvent
+	picks   []PickEvent
+}
+
+func (f *frameEvents) clear() {
+	f.keys = f.keys[:0]
+	f.chars = f.chars[:0]
+	f.moves = f.moves[:0]
+	f.buttons = f.buttons[:0]
+	f.scrolls = f.scrolls[:0]
+	f.resizes = f.resizes[:0]
+	f.picks = f.picks[:0]
+}
+
+// EventManager buffers the input events of a frame. Events pushed by the
+// source are collected until BeginFrame, after which they can be read for
+// the rest of the frame and the polling queries reflect them.
+type EventManager struct {
+	pending, frame frameEvents
+
+	keysDown      map[Key]bool
+	buttonsDown   map[MouseButton]bool
+	mouseX        float64
+	mouseY        float64
+	mouseDX       float64
+	mouseDY       float64
+	mouseKnown    bool
+	width, height int
+}
+
+func NewEventManager() *EventManager {
+	return &EventManager{keysDown: map[Key]bool{}, buttonsDown: map[MouseButton]bool{}}
+}
+
+// Attach connects a source to the event manager.
+func (evtMgt *EventManager) Attach(source EventSource) {
+	source.Attach(evtMgt)
+}
+
+func (evtMgt *EventManager) PushKey(e KeyEvent) {
+	evtMgt.pending.keys = append(evtMgt.pending.keys, e)
+}
+
+func (evtMgt *EventManager) PushChar(e CharEvent) {
+	evtMgt.pending.chars = append(evtMgt.pending.chars, e)
+}
+
+func (evtMgt *EventManager) PushMouseMove(e MouseMoveEvent) {
+	evtMgt.pending.moves = append(evtMgt.pending.moves, e)
+}
+
+func (evtMgt *EventManager) PushMouseButton(e MouseButtonEvent) {
+	evtMgt.pending.buttons = append(evtMgt.pending.buttons, e)
+}
+
+func (evtMgt *EventManager) PushScroll(e ScrollEvent) {
+	evtMgt.pending.scrolls = append(evtMgt.pending.scrolls, e)
+}
+
+func (evtMgt *EventManager) PushResize(e ResizeEvent) {
+	evtMgt.pending.resizes = append(evtMgt.pending.resizes, e)
+}
+
+// BeginFrame makes the events pushed since the last frame readable and updates the polling state.
+func (evtMgt *EventManager) BeginFrame() {
+	evtMgt.frame.clear()
+	evtMgt.pending, evtMgt.frame = evtMgt.frame, evtMgt.pending
+
+	for _, e := range evtMgt.frame.keys {
+		evtMgt.keysDown[e.KeyCode] = e.Action != Released
+	}
+	for _, e := range evtMgt.frame.buttons {
+		evtMgt.buttonsDown[e.Button] = e.Action != Released
+	}
+	evtMgt.mouseDX, evtMgt.mouseDY = 0, 0
+	if n := len(evtMgt.frame.moves); n > 0 {
+		x, y := evtMgt.frame.moves[n-1].X, evtMgt.frame.moves[n-1].Y
+		// the first position is not a movement.
+		if evtMgt.mouseKnown {
+			evtMgt.mouseDX, evtMgt.mouseDY = x-evtMgt.mouseX, y-evtMgt.mouseY
+		}
+		evtMgt.mouseX, evtMgt.mouseY = x, y
+		evtMgt.mouseKnown = true
+	}
+	if n := len(evtMgt.frame.resizes); n > 0 {
+		evtMgt.width, evtMgt.height = evtMgt.frame.resizes[n-1].Width, evtMgt.frame.resizes[n-1].Height
+	}
+}
+
+// ReadKeyEvents appends the key events of the current frame to output.
+func (evtMgt *EventManager) ReadKeyEvents(output *[]KeyEvent) {
+	*output = append(*output, evtMgt.frame.keys...)
+}
+
+func (evtMgt *EventManager) ReadCharEvents(output *[]CharEvent) {
+	*output = append(*output, evtMgt.frame.chars...)
+}
+
+func (evtMgt *EventManager) ReadMouseMoveEvents(output *[]MouseMoveEvent) {
+	*output = append(*output, evtMgt.frame.moves...)
+}
+
+func (evtMgt *EventManager) ReadMouseButtonEvents(output *[]MouseButtonEvent) {
+	*output = append(*output, evtMgt.frame.buttons...)
+}
+
+func (evtMgt *EventManager) ReadScrollEvents(output *[]ScrollEvent) {
+	*output = append(*output, evtMgt.frame.scrolls...)
+}
+
+func (evtMgt *EventManager) ReadResizeEvents(output *[]ResizeEvent) {
+	*output = append(*output, evtMgt.frame.resizes...)
+}
+
+// ReadPickEvents appends the pick events of the current frame to output.
+// RunApp and RunHeadless create one for each mouse button press.
+func (evtMgt *EventManager) ReadPickEvents(output *[]PickEvent) {
+	*output = append(*output, evtMgt.frame.picks...)
+}
+
+func (evtMgt *EventManager) IsKeyDown(key Key) bool {
+	return evtMgt.keysDown[key]
+}
+
+// WasKeyPressed returns true if the key was pressed during the current frame.
+func (evtMgt *EventManager) WasKeyPressed(key Key) bool {
+	for _, e := range evtMgt.frame.keys {
+		if e.KeyCode == key && e.Action == Pressed {
+			return true
+		}
+	}
+	return false
+}
+
+func (evtMgt *EventManager) IsMouseButtonDown(button MouseButton) bool {
+	return evtMgt.buttonsDown[button]
+}
+
+// WasMouseButtonPressed returns true if the button was pressed during the current frame.
+func (evtMgt *EventManager) WasMouseButtonPressed(button MouseButton) bool {
+	for _, e := range evtMgt.frame.buttons {
+		if e.Button == button && e.Action == Pressed {
+			return true
+		}
+	}
+	return false
+}
+
+func (evtMgt *EventManager) MousePosition() (float64, float64) {
+	return evtMgt.mouseX, evtMgt.mouseY
+}
+
+// MouseDelta returns how far the mouse moved during the current frame.
+func (evtMgt *EventManager) MouseDelta() (float64, float64) {
+	return evtMgt.mouseDX, evtMgt.mouseDY
+}
+
+// ScrollDelta returns the total scroll of the current frame.
+func (evtMgt *EventManager) ScrollDelta() (float64, float64) {
+	var dx, dy float64
+	for _, e := range evtMgt.frame.scrolls {
+		dx += e.DX
+		dy += e.DY
+	}
+	return dx, dy
+}
+
+// WindowSize returns the last size reported by a resize event.
+func (evtMgt *EventManager) WindowSize() (int, int) {
+	return evtMgt.width, evtMgt.height
+}
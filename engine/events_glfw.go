@@ -0,0 +1,141 @@
+package engine
+
+import "github.com/go-gl/glfw/v3.3/glfw"
+
+// Key codes use the GLFW values.
+type Key int
+
+const (
+	KeyUnknown      = Key(glfw.KeyUnknown)
+	KeySpace        = Key(glfw.KeySpace)
+	Key0            = Key(glfw.Key0)
+	Key1            = Key(glfw.Key1)
+	Key2            = Key(glfw.Key2)
+	Key3            = Key(glfw.Key3)
+	Key4            = Key(glfw.Key4)
+	Key5            = Key(glfw.Key5)
+	Key6            = Key(glfw.Key6)
+	Key7            = Key(glfw.Key7)
+	Key8            = Key(glfw.Key8)
+	Key9            = Key(glfw.Key9)
+	KeyA            = Key(glfw.KeyA)
+	KeyB            = Key(glfw.KeyB)
+	KeyC            = Key(glfw.KeyC)
+	KeyD            = Key(glfw.KeyD)
+	KeyE            = Key(glfw.KeyE)
+	KeyF            = Key(glfw.KeyF)
+	KeyG            = Key(glfw.KeyG)
+	KeyH            = Key(glfw.KeyH)
+	KeyI            = Key(glfw.KeyI)
+	KeyJ            = Key(glfw.KeyJ)
+	KeyK            = Key(glfw.KeyK)
+	KeyL            = Key(glfw.KeyL)
+	KeyM            = Key(glfw.KeyM)
+	KeyN            = Key(glfw.KeyN)
+	KeyO            = Key(glfw.KeyO)
+	KeyP            = Key(glfw.KeyP)
+	KeyQ            = Key(glfw.KeyQ)
+	KeyR            = Key(glfw.KeyR)
+	KeyS            = Key(glfw.KeyS)
+	KeyT            = Key(glfw.KeyT)
+	KeyU            = Key(glfw.KeyU)
+	KeyV            = Key(glfw.KeyV)
+	KeyW            = Key(glfw.KeyW)
+	KeyX            = Key(glfw.KeyX)
+	KeyY            = Key(glfw.KeyY)
+	KeyZ            = Key(glfw.KeyZ)
+	KeyEscape       = Key(glfw.KeyEscape)
+	KeyEnter        = Key(glfw.KeyEnter)
+	KeyTab          = Key(glfw.KeyTab)
+	KeyBackspace    = Key(glfw.KeyBackspace)
+	KeyDelete       = Key(glfw.KeyDelete)
+	KeyRight        = Key(glfw.KeyRight)
+	KeyLeft         = Key(glfw.KeyLeft)
+	KeyDown         = Key(glfw.KeyDown)
+	KeyUp           = Key(glfw.KeyUp)
+	KeyF1           = Key(glfw.KeyF1)
+	KeyF2           = Key(glfw.KeyF2)
+	KeyF3           = Key(glfw.KeyF3)
+	KeyF4           = Key(glfw.KeyF4)
+	KeyF5           = Key(glfw.KeyF5)
+	KeyF6           = Key(glfw.KeyF6)
+	KeyF7           = Key(glfw.KeyF7)
+	KeyF8           = Key(glfw.KeyF8)
+	KeyF9           = Key(glfw.KeyF9)
+	KeyF10          = Key(glfw.KeyF10)
+	KeyF11          = Key(glfw.KeyF11)
+	KeyF12          = Key(glfw.KeyF12)
+	KeyLeftShift    = Key(glfw.KeyLeftShift)
+	KeyLeftControl  = Key(glfw.KeyLeftControl)
+	KeyLeftAlt      = Key(glfw.KeyLeftAlt)
+	KeyRightShift   = Key(glfw.KeyRightShift)
+	KeyRightControl = Key(glfw.KeyRightControl)
+	KeyRightAlt     = Key(glfw.KeyRightAlt)
+)
+
+type MouseButton int
+
+const (
+	MouseButtonLeft   = MouseButton(glfw.MouseButtonLeft)
+	MouseButtonRight  = MouseButton(glfw.MouseButtonRight)
+	MouseButtonMiddle = MouseButton(glfw.MouseButtonMiddle)
+)
+
+// glfwEventSource forwards the callbacks of a GLFW window.
+type glfwEventSource struct {
+	window *glfw.Window
+}
+
+func glfwAction(action glfw.Action) ButtonAction {
+	switch action {
+	case glfw.Press:
+		return Pressed
+	case glfw.Repeat:
+		return Repeated
+	}
+	return Released
+}
+
+func glfwMods(mods glfw.ModifierKey) Modifier {
+	var result Modifier
+	if mods&glfw.ModShift != 0 {
+		result |= ModShift
+	}
+	if mods&glfw.ModControl != 0 {
+		result |= ModControl
+	}
+	if mods&glfw.ModAlt != 0 {
+		result |= ModAlt
+	}
+	if mods&glfw.ModSuper != 0 {
+		result |= ModSuper
+	}
+	return result
+}
+
+func (s glfwEventSource) Attach(m *EventManager) {
+	s.window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+		m.PushKey(KeyEvent{KeyCode: Key(key), Scancode: scancode, Action: glfwAction(action), Mods: glfwMods(mods), Time: glfw.GetTime()})
+	})
+	s.window.SetCharCallback(func(w *glfw.Window, char rune) {
+		m.PushChar(CharEvent{Char: char, Time: glfw.GetTime()})
+	})
+	s.window.SetCursorPosCallback(func(w *glfw.Window, x, y float64) {
+		m.PushMouseMove(MouseMoveEvent{X: x, Y: y, Time: glfw.GetTime()})
+	})
+	s.window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+		x, y := w.GetCursorPos()
+		m.PushMouseButton(MouseButtonEvent{Button: MouseButton(button), Action: glfwAction(action), Mods: glfwMods(mods), X: x, Y: y, Time: glfw.GetTime()})
+	})
+	s.window.SetScrollCallback(func(w *glfw.Window, dx, dy float64) {
+		m.PushScroll(ScrollEvent{DX: dx, DY: dy, Time: glfw.GetTime()})
+	})
+	s.window.SetSizeCallback(func(w *glfw.Window, width, height int) {
+		m.PushResize(ResizeEvent{Width: width, Height: height, Time: glfw.GetTime()})
+	})
+
+	width, height := s.window.GetSize()
+	m.PushResize(ResizeEvent{Width: width, Height: height, Time: glfw.GetTime()})
+	x, y := s.window.GetCursorPos()
+	m.PushMouseMove(MouseMoveEvent{X: x, Y: y, Time: glfw.GetTime()})
+}
@@ -0,0 +1,86 @@
+package engine
+
+import "testing"
+
+// syntheticSource feeds scripted events instead of a window.
+type syntheticSource struct {
+	m *EventManager
+}
+
+func (s *syntheticSource) Attach(m *EventManager) {
+	s.m = m
+}
+
+func TestEventManagerFrames(t *testing.T) {
+	source := &syntheticSource{}
+	m := NewEventManager()
+	m.Attach(source)
+
+	source.m.PushKey(KeyEvent{KeyCode: KeyW, Action: Pressed, Mods: ModShift, Time: 0.1})
+	source.m.PushChar(CharEvent{Char: 'W', Time: 0.1})
+	source.m.PushMouseMove(MouseMoveEvent{X: 10, Y: 20, Time: 0.12})
+	source.m.PushMouseMove(MouseMoveEvent{X: 15, Y: 25, Time: 0.13})
+	source.m.PushScroll(ScrollEvent{DY: 1, Time: 0.14})
+	source.m.PushScroll(ScrollEvent{DY: 2, Time: 0.15})
+
+	if m.IsKeyDown(KeyW) {
+		t.Error("Events should not be visible before the frame starts")
+	}
+
+	m.BeginFrame()
+	keys := []KeyEvent{}
+	m.ReadKeyEvents(&keys)
+	if len(keys) != 1 || keys[0].KeyCode != KeyW || keys[0].Mods != ModShift || keys[0].Time != 0.1 {
+		t.Errorf("Unexpected key events: %v", keys)
+	}
+	chars := []CharEvent{}
+	m.ReadCharEvents(&chars)
+	if len(chars) != 1 || chars[0].Char != 'W' {
+		t.Errorf("Unexpected char events: %v", chars)
+	}
+	if !m.IsKeyDown(KeyW) || !m.WasKeyPressed(KeyW) {
+		t.Error("Expected W to be pressed and down")
+	}
+	if x, y := m.MousePosition(); x != 15 || y != 25 {
+		t.Errorf("Unexpected mouse position %v %v", x, y)
+	}
+	if _, dy := m.ScrollDelta(); dy != 3 {
+		t.Errorf("Expected scroll of 3, got %v", dy)
+	}
+
+	// the next frame has no new events, but the key is still held.
+	source.m.PushKey(KeyEvent{KeyCode: KeyW, Action: Repeated, Time: 0.5})
+	m.BeginFrame()
+	keys = keys[:0]
+	m.ReadKeyEvents(&keys)
+	if len(keys) != 1 || keys[0].Action != Repeated {
+		t.Errorf("Unexpected key events: %v", keys)
+	}
+	if !m.IsKeyDown(KeyW) || m.WasKeyPressed(KeyW) {
+		t.Error("Expected W to be held without a new press")
+	}
+	if _, dy := m.ScrollDelta(); dy != 0 {
+		t.Errorf("Expected scroll to reset, got %v", dy)
+	}
+
+	source.m.PushKey(KeyEvent{KeyCode: KeyW, Action: Released, Time: 0.6})
+	source.m.PushMouseButton(MouseButtonEvent{Button: MouseButtonLeft, Action: Pressed, X: 15, Y: 25, Time: 0.6})
+	source.m.PushResize(ResizeEvent{Width: 640, Height: 480, Time: 0.7})
+	m.BeginFrame()
+	if m.IsKeyDown(KeyW) {
+		t.Error("Expected W to be released")
+	}
+	if !m.IsMouseButtonDown(MouseButtonLeft) || !m.WasMouseButtonPressed(MouseButtonLeft) || m.IsMouseButtonDown(MouseButtonRight) {
+		t.Error("Expected only the left button to be down")
+	}
+	if w, h := m.WindowSize(); w != 640 || h != 480 {
+		t.Errorf("Unexpected window size %v %v", w, h)
+	}
+
+	m.BeginFrame()
+	keys = keys[:0]
+	m.ReadKeyEvents(&keys)
+	if len(keys) != 0 {
+		t.Errorf("Expected no key events, got %v", keys)
+	}
+}
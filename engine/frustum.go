@@ -0,0 +1,200 @@
+package engine
+
+import (
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+// Plane is the set of points p where Normal·p + D is 0, points on the side
+// the normal points to have a positive distance.
+type Plane struct {
+	Normal vec3.Vec3
+	D      float32
+}
+
+func (p Plane) Distance(point vec3.Vec3) float32 {
+	return p.Normal.DotProduct(point) + p.D
+}
+
+// Frustum is the volume seen by a camera, bounded by planes facing inwards.
+type Frustum [6]Plane
+
+// NewFrustum extracts the planes from a combined projection and view
+// matrix, each plane is where a clip coordinate equals ±w.
+func NewFrustum(viewProjection Mat4) Frustum {
+	row := func(r int) [4]float32 {
+		return [4]float32{viewProjection.Get(r, 0), viewProjection.Get(r, 1), viewProjection.Get(r, 2), viewProjection.Get(r, 3)}
+	}
+	w := row(3)
+	var f Frustum
+	for i := 0; i < 3; i++ {
+		r := row(i)
+		f[i*2] = normalizePlane(w[0]+r[0], w[1]+r[1], w[2]+r[2], w[3]+r[3])
+		f[i*2+1] = normalizePlane(w[0]-r[0], w[1]-r[1], w[2]-r[2], w[3]-r[3])
+	}
+	return f
+}
+
+func normalizePlane(x, y, z, d float32) Plane {
+	n := vec3.New(x, y, z)
+	l := n.Length()
+	if l == 0 {
+		return Plane{Normal: n, D: d}
+	}
+	return Plane{Normal: n.MultiplyScalar(1 / l), D: d / l}
+}
+
+// ContainsPoint returns true if the point is inside or on the frustum.
+func (f *Frustum) ContainsPoint(p vec3.Vec3) bool {
+	for _, plane := range f {
+		if plane.Distance(p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsSphere returns false if the sphere is entirely outside one of the
+// planes. Spheres near the corners outside the frustum can pass, which only
+// means they are drawn.
+func (f *Frustum) IntersectsSphere(s sdf.Sphere) bool {
+	for _, plane := range f {
+		if plane.Distance(s.Center) < -s.Radius {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsAABB returns false if the box is entirely outside one of the
+// planes, testing the corner furthest along each plane's normal.
+func (f *Frustum) IntersectsAABB(b AABB) bool {
+	for _, plane := range f {
+		corner := b.Min
+		if plane.Normal.X >= 0 {
+			corner.X = b.Max.X
+		}
+		if plane.Normal.Y >= 0 {
+			corner.Y = b.Max.Y
+		}
+		if plane.Normal.Z >= 0 {
+			corner.Z = b.Max.Z
+		}
+		if plane.Distance(corner) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AABB is an axis aligned bounding box.
+type AABB struct {
+	Min, Max vec3.Vec3
+}
+
+// AABBFromPoints returns the smallest box containing the points, or an empty box.
+func AABBFromPoints(points []vec3.Vec3) AABB {
+	if len(points) == 0 {
+		return AABB{}
+	}
+	b := AABB{Min: points[0], Max: points[0]}
+	for _, p := range points[1:] {
+		b.Min = vec3.New(min(b.Min.X, p.X), min(b.Min.Y, p.Y), min(b.Min.Z, p.Z))
+		b.Max = vec3.New(max(b.Max.X, p.X), max(b.Max.Y, p.Y), max(b.Max.Z, p.Z))
+	}
+	return b
+}
+
+func (b AABB) Center() vec3.Vec3 {
+	return b.Min.Add(b.Max).MultiplyScalar(0.5)
+}
+
+// BoundingSphere returns the sphere through the corners of the box.
+func (b AABB) BoundingSphere() sdf.Sphere {
+	return sdf.Sphere{Center: b.Center(), Radius: b.Max.Subtract(b.Min).Length() * 0.5}
+}
+
+// Transform returns the box containing the transformed box.
+func (b AABB) Transform(m Mat4) AABB {
+	lo := [3]float32{b.Min.X, b.Min.Y, b.Min.Z}
+	hi := [3]float32{b.Max.X, b.Max.Y, b.Max.Z}
+	var outMin, outMax [3]float32
+	for r := 0; r < 3; r++ {
+		outMin[r] = m.Get(r, 3)
+		outMax[r] = m.Get(r, 3)
+		for c := 0; c < 3; c++ {
+			x, y := m.Get(r, c)*lo[c], m.Get(r, c)*hi[c]
+			outMin[r] += min(x, y)
+			outMax[r] += max(x, y)
+		}
+	}
+	return AABB{Min: vec3.New(outMin[0], outMin[1], outMin[2]), Max: vec3.New(outMax[0], outMax[1], outMax[2])}
+}
+
+// CullStats counts what was drawn and skipped by frustum culling in a frame.
+type CullStats struct {
+	Drawn, Culled             int
+	BricksDrawn, BricksCulled int
+}
+
+// Frustum is the view frustum of the current camera.
+func (s *Screen) Frustum() Frustum {
+	return NewFrustum(s.cameraTransform)
+}
+
+// CullStats returns the culling counts of the last finished frame.
+func (s *Screen) CullStats() CullStats {
+	return s.lastCulling
+}
+
+// beginFrame starts counting the culling of a new frame.
+func (s *Screen) beginFrame() {
+	s.lastCulling, s.culling = s.culling, CullStats{}
+	s.brickCamera = Mat4{}
+}
+
+// brickCuller is implemented by backends that can skip the bricks of the
+// scene brick map outside the view.
+type brickCuller interface {
+	SetVisibleBricks(visible []int)
+}
+
+// selectBricks culls the bricks of the scene brick map once per frame and
+// camera, before the scene is drawn.
+func (s *Screen) selectBricks() {
+	if s.brickMap == nil || s.cameraTransform == s.brickCamera {
+		return
+	}
+	s.brickCamera = s.cameraTransform
+	visible := s.VisibleBricks(s.brickMap)
+	if culler, ok := s.backend.(brickCuller); ok {
+		culler.SetVisibleBricks(visible)
+	}
+}
+
+// VisibleBricks returns the indices of the stored bricks of a brick map
+// inside the view frustum, with the brick map in world space.
+func (s *Screen) VisibleBricks(b *sdf.BrickMap) []int {
+	frustum := s.Frustum()
+	brickWorld := b.VoxelSize * sdf.BrickSize
+	var visible []int
+	for z := 0; z < b.Dims[2]; z++ {
+		for y := 0; y < b.Dims[1]; y++ {
+			for x := 0; x < b.Dims[0]; x++ {
+				idx := x + y*b.Dims[0] + z*b.Dims[0]*b.Dims[1]
+				if b.Indirection[idx] < 0 {
+					continue
+				}
+				origin := b.Min.Add(vec3.New(float32(x), float32(y), float32(z)).MultiplyScalar(brickWorld))
+				bounds := AABB{Min: origin, Max: origin.Add(vec3.New(brickWorld, brickWorld, brickWorld))}
+				if !frustum.IntersectsAABB(bounds) {
+					s.culling.BricksCulled++
+					continue
+				}
+				s.culling.BricksDrawn++
+				visible = append(visible, idx)
+			}
+		}
+	}
+	return visible
+}
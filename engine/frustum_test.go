@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"math"
+	"testing"
+
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
+)
+
+func testFrustum() Frustum {
+	camera := NewCamera(vec3.New(0, 0, 0))
+	return NewFrustum(camera.Projection(1).Multiply(camera.View()))
+}
+
+func TestFrustumPlanes(t *testing.T) {
+	f := testFrustum()
+	inside := []vec3.Vec3{vec3.New(0, 0, -10), vec3.New(5, 5, -20), vec3.New(0, 0, -999)}
+	outside := []vec3.Vec3{vec3.New(0, 0, 10), vec3.New(0, 0, -0.5), vec3.New(100, 0, -10), vec3.New(0, -100, -10), vec3.New(0, 0, -1001)}
+	for _, p := range inside {
+		if !f.ContainsPoint(p) {
+			t.Errorf("Expected %v inside", p)
+		}
+	}
+	for _, p := range outside {
+		if f.ContainsPoint(p) {
+			t.Errorf("Expected %v outside", p)
+		}
+	}
+	for _, plane := range f {
+		if math.Abs(float64(plane.Normal.Length()-1)) > 1e-4 {
+			t.Errorf("Expected normalized planes, got %v", plane)
+		}
+	}
+}
+
+func TestFrustumSphereAndAABB(t *testing.T) {
+	f := testFrustum()
+	if !f.IntersectsSphere(sdf.Sphere{Center: vec3.New(0, 0, 2), Radius: 3}) {
+		t.Errorf("Expected a sphere around the camera to intersect")
+	}
+	if f.IntersectsSphere(sdf.Sphere{Center: vec3.New(0, 0, 5), Radius: 1}) {
+		t.Errorf("Expected a sphere behind the camera to be culled")
+	}
+	if !f.IntersectsAABB(AABB{Min: vec3.New(-100, -1, -11), Max: vec3.New(100, 1, -9)}) {
+		t.Errorf("Expected a box crossing the view to intersect")
+	}
+	if f.IntersectsAABB(AABB{Min: vec3.New(50, -1, -11), Max: vec3.New(60, 1, -9)}) {
+		t.Errorf("Expected a box right of the view to be culled")
+	}
+}
+
+func TestAABBTransform(t *testing.T) {
+	b := AABBFromPoints([]vec3.Vec3{vec3.New(-1, 0, 2), vec3.New(1, 2, 3), vec3.New(0, 1, 2.5)})
+	if b.Min != vec3.New(-1, 0, 2) || b.Max != vec3.New(1, 2, 3) {
+		t.Fatalf("Unexpected bounds %v", b)
+	}
+	moved := b.Transform(Mat4Translation(1, 2, 3).Multiply(Mat4Scale(2, 2, 2)))
+	if !moved.Min.ApproxEqual(vec3.New(-1, 2, 7), 1e-4) || !moved.Max.ApproxEqual(vec3.New(3, 6, 9), 1e-4) {
+		t.Errorf("Unexpected transformed bounds %v", moved)
+	}
+	// The transformed box contains every transformed corner.
+	r := RotationMatrix(0.7, vec3.New(1, 2, 3).Normalize())
+	rotated := b.Transform(r)
+	for i := 0; i < 8; i++ {
+		corner := b.Min
+		if i&1 != 0 {
+			corner.X = b.Max.X
+		}
+		if i&2 != 0 {
+			corner.Y = b.Max.Y
+		}
+		if i&4 != 0 {
+			corner.Z = b.Max.Z
+		}
+		p := transformPoint(r, corner)
+		if p.X < rotated.Min.X-1e-5 || p.Y < rotated.Min.Y-1e-5 || p.Z < rotated.Min.Z-1e-5 ||
+			p.X > rotated.Max.X+1e-5 || p.Y > rotated.Max.Y+1e-5 || p.Z > rotated.Max.Z+1e-5 {
+			t.Errorf("Corner %v outside %v", p, rotated)
+		}
+	}
+}
+
+func TestNodeDrawCulling(t *testing.T) {
+	screen := Screen{backend: newSoftwareBackend(8, 8)}
+	screen.layout(&layoutContext{}, 8, 8, 8, 8)
+	cube := &Polygon{}
+	cube.Load3D(cubeVertices())
+	root := NewNode("root")
+	for i, p := range []vec3.Vec3{vec3.New(0, 0, -10), vec3.New(0, 0, 10), vec3.New(100, 0, -10), vec3.New(2, 0, -10)} {
+		n := NewNode(string(rune('a'+i)), cube)
+		n.SetTransform(Mat4Translation(p.X, p.Y, p.Z))
+		root.AddChild(n)
+	}
+	camera := NewCamera(vec3.New(0, 0, 0))
+	camera.Apply(&screen, 1)
+	screen.beginFrame()
+	root.Draw(&screen)
+	screen.beginFrame()
+	if stats := screen.CullStats(); stats.Drawn != 2 || stats.Culled != 2 {
+		t.Errorf("Expected 2 drawn and 2 culled, got %+v", stats)
+	}
+}
+
+func TestVisibleBricks(t *testing.T) {
+	screen := Screen{}
+	camera := NewCamera(vec3.New(0, 0, 0))
+	camera.Apply(&screen, 1)
+	// one sphere in front of the camera and one behind.
+	scene := sdf.Union{sdf.Sphere{Center: vec3.New(0, 0, -8), Radius: 1}, sdf.Sphere{Center: vec3.New(0, 0, 8), Radius: 1}}
+	b := sdf.BakeBrickMap(scene, vec3.New(-2, -2, -10), vec3.New(2, 2, 10), 0.25, 0.5)
+	visible := screen.VisibleBricks(b)
+	screen.beginFrame()
+	stats := screen.CullStats()
+	if len(visible) == 0 || stats.BricksCulled == 0 {
+		t.Fatalf("Expected visible and culled bricks, got %+v", stats)
+	}
+	if stats.BricksDrawn != len(visible) || stats.BricksDrawn+stats.BricksCulled != b.BrickCount() {
+		t.Errorf("Expected every stored brick counted once, got %+v of %v", stats, b.BrickCount())
+	}
+	for _, idx := range visible {
+		if b.Indirection[idx] < 0 {
+			t.Errorf("Expected only stored bricks, got %v", idx)
+		}
+		z := idx / (b.Dims[0] * b.Dims[1])
+		if b.Min.Z+float32(z)*b.VoxelSize*sdf.BrickSize > 0 {
+			t.Errorf("Expected no bricks behind the camera, got z %v", z)
+		}
+	}
+}
+
+// brickRecorder records the bricks selected for drawing.
+type brickRecorder struct {
+	*softwareBackend
+	visible [][]int
+}
+
+func (b *brickRecorder) SetVisibleBricks(visible []int) {
+	b.visible = append(b.visible, visible)
+}
+
+func TestSelectBricks(t *testing.T) {
+	backend := &brickRecorder{softwareBackend: newSoftwareBackend(8, 8)}
+	screen := Screen{backend: backend}
+	b := sdf.BakeBrickMap(sdf.Sphere{Center: vec3.New(0, 0, -8), Radius: 1}, vec3.New(-2, -2, -10), vec3.New(2, 2, 10), 0.25, 0.5)
+	if err := screen.SetScene(sdf.Union{sdf.Sphere{Radius: 1}, b}); err != nil {
+		t.Fatal(err)
+	}
+	camera := NewCamera(vec3.New(0, 0, 0))
+	camera.Apply(&screen, 1)
+	quad := Polygon{}
+	quad.Load3D([]vec3.Vec3{vec3.New(-1, -1, -2), vec3.New(1, -1, -2), vec3.New(0, 1, -2)})
+	screen.beginFrame()
+	screen.Draw(quad, Mat4Identity(), vec4.Vec4{})
+	screen.Draw(quad, Mat4Identity(), vec4.Vec4{})
+	screen.beginFrame()
+	if len(backend.visible) != 1 || len(backend.visible[0]) != b.BrickCount() {
+		t.Fatalf("Expected the visible bricks selected once, got %v", backend.visible)
+	}
+	if stats := screen.CullStats(); stats.BricksDrawn != b.BrickCount() {
+		t.Errorf("Expected the bricks in front counted, got %+v", stats)
+	}
+
+	// looking away from the sphere culls every brick.
+	camera.Yaw = math.Pi
+	camera.Apply(&screen, 1)
+	screen.Draw(quad, Mat4Identity(), vec4.Vec4{})
+	screen.beginFrame()
+	if len(backend.visible) != 2 || len(backend.visible[1]) != 0 {
+		t.Fatalf("Expected no visible bricks, got %v", backend.visible)
+	}
+	if stats := screen.CullStats(); stats.BricksCulled != b.BrickCount() {
+		t.Errorf("Expected all bricks culled, got %+v", stats)
+	}
+}
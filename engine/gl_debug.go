@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"github.com/go-gl/gl/v4.1-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// enableGLDebug logs GL debug messages to stderr when the context supports
+// KHR_debug. Notifications are left out. Returns false without KHR_debug,
+// for example on macOS.
+func enableGLDebug() bool {
+	if !glfw.ExtensionSupported("GL_KHR_debug") {
+		return false
+	}
+	gl.Enable(gl.DEBUG_OUTPUT)
+	// report messages from the call causing them, so stack traces are useful.
+	gl.Enable(gl.DEBUG_OUTPUT_SYNCHRONOUS)
+	gl.DebugMessageControl(gl.DONT_CARE, gl.DONT_CARE, gl.DEBUG_SEVERITY_NOTIFICATION, 0, nil, false)
+	gl.DebugMessageCallback(func(source, gltype, id, severity uint32, length int32, message string, userParam unsafe.Pointer) {
+		fmt.Fprintf(os.Stderr, "GL %v %v %v: %v\n", debugSeverityName(severity), debugSourceName(source), debugTypeName(gltype), message)
+	}, nil)
+	return true
+}
+
+func debugSeverityName(severity uint32) string {
+	switch severity {
+	case gl.DEBUG_SEVERITY_HIGH:
+		return "high"
+	case gl.DEBUG_SEVERITY_MEDIUM:
+		return "medium"
+	case gl.DEBUG_SEVERITY_LOW:
+		return "low"
+	}
+	return "notification"
+}
+
+func debugSourceName(source uint32) string {
+	switch source {
+	case gl.DEBUG_SOURCE_API:
+		return "api"
+	case gl.DEBUG_SOURCE_WINDOW_SYSTEM:
+		return "window-system"
+	case gl.DEBUG_SOURCE_SHADER_COMPILER:
+		return "shader-compiler"
+	case gl.DEBUG_SOURCE_THIRD_PARTY:
+		return "third-party"
+	case gl.DEBUG_SOURCE_APPLICATION:
+		return "application"
+	}
+	return "other"
+}
+
+func debugTypeName(gltype uint32) string {
+	switch gltype {
+	case gl.DEBUG_TYPE_ERROR:
+		return "error"
+	case gl.DEBUG_TYPE_DEPRECATED_BEHAVIOR:
+		return "deprecated"
+	case gl.DEBUG_TYPE_UNDEFINED_BEHAVIOR:
+		return "undefined-behavior"
+	case gl.DEBUG_TYPE_PORTABILITY:
+		return "portability"
+	case gl.DEBUG_TYPE_PERFORMANCE:
+		return "performance"
+	}
+	return "other"
+}
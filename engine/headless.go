@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"image"
+	"time"
+)
+
+// Size of the headless screen, passed to Layout.
+const (
+	headlessWidth  = 512
+	headlessHeight = 512
+)
+
+// RunHeadless runs ctx for a number of frames without opening a window and
+// returns the frames. It uses the software backend, so it works without a
+// display or GL driver. The screen size is the result of ctx.Layout(512, 512), with a scale of 1.
+// Every frame runs one update at the default tick rate. The scene file of a
+// SceneSource is loaded once, the console is not started.
+func RunHeadless(ctx MainContext, frames int) ([]image.Image, error) {
+	width, height := ctx.Layout(headlessWidth, headlessHeight)
+	if width <= 0 || height <= 0 {
+		width, height = headlessWidth, headlessHeight
+	}
+	screen := Screen{cameraTransform: Mat4Identity(), backend: newSoftwareBackend(width, height)}
+	screen.layout(ctx, width, height, width, height)
+	scene := defaultScene()
+	if src, ok := ctx.(SceneSource); ok {
+		loaded, err := LoadSceneFile(src.SceneFile())
+		if err != nil {
+			return nil, err
+		}
+		scene = loaded
+	}
+	if err := screen.SetScene(scene); err != nil {
+		return nil, err
+	}
+
+	eventMgr := NewEventManager()
+	eventMgr.PushResize(ResizeEvent{Width: width, Height: height})
+	timestep := newFixedTimestep(DefaultAppConfig().TickRate)
+	images := make([]image.Image, 0, frames)
+	for i := 0; i < frames; i++ {
+		start := time.Now()
+		// the simulated time advances one update per frame.
+		ticks, alpha := timestep.advance(timestep.step)
+		for j := 0; j < ticks; j++ {
+			eventMgr.BeginFrame()
+			screen.pickEvents(eventMgr)
+			ctx.Update(eventMgr, timestep.dt())
+		}
+		screen.Clear()
+		screen.beginFrame()
+
+		ctx.Draw(&screen, float32(timestep.step.Seconds()), alpha)
+		images = append(images, screen.Image())
+		screen.stats.Add(time.Since(start))
+	}
+	return images, nil
+}
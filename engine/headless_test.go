@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec2"
+	"github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
+)
+
+// cubeVertices returns the faces of a cube from -1 to 1, wound counter clockwise seen from outside.
+func cubeVertices() []vec3.Vec3 {
+	face := []vec3.Vec3{
+		vec3.New(-1, -1, 1), vec3.New(1, -1, 1), vec3.New(-1, 1, 1),
+		vec3.New(1, -1, 1), vec3.New(1, 1, 1), vec3.New(-1, 1, 1)}
+	var out []vec3.Vec3
+	rotations := []Mat4{}
+	for i := 0; i < 4; i++ {
+		rotations = append(rotations, RotationMatrix(math.Pi/2*float32(i), vec3.New(1, 0, 0)))
+	}
+	for i := 1; i < 4; i += 2 {
+		rotations = append(rotations, RotationMatrix(math.Pi/2*float32(i), vec3.New(0, 1, 0)))
+	}
+	for _, r := range rotations {
+		for _, v := range face {
+			out = append(out, transformPoint(r, v))
+		}
+	}
+	return out
+}
+
+type headlessGame struct {
+	camera    Camera
+	cube      Polygon
+	square    Polygon
+	fb        *Framebuffer
+	updates   int
+	offscreen bool
+	// tickDt and frameDt are the dt of the last Update and Draw.
+	tickDt, frameDt float32
+}
+
+func (g *headlessGame) Update(events *EventManager, dt float32) {
+	if g.updates == 0 {
+		g.cube.Load3D(cubeVertices())
+		g.square.Load3DUv([]vec3.Vec3{
+			vec3.New(-1, -1, 0), vec3.New(1, -1, 0), vec3.New(-1, 1, 0),
+			vec3.New(1, -1, 0), vec3.New(1, 1, 0), vec3.New(-1, 1, 0),
+		}, []vec2.Vec2{
+			vec2.New(0, 0), vec2.New(1, 0), vec2.New(0, 1),
+			vec2.New(1, 0), vec2.New(1, 1), vec2.New(0, 1),
+		})
+	}
+	g.updates++
+	g.tickDt = dt
+}
+
+func (g *headlessGame) Layout(width, height int) (int, int) {
+	return 48, 32
+}
+
+func (g *headlessGame) Draw(screen *Screen, dt, alpha float32) {
+	g.frameDt = dt
+	if !g.offscreen {
+		g.camera.Apply(screen, float32(screen.ScreenWidth)/float32(screen.ScreenHeight))
+		screen.Draw(g.cube, Mat4Identity(), vec4.New(1, 1, 1, 1))
+		return
+	}
+	if g.fb == nil {
+		g.fb, _ = screen.NewFramebuffer(16, 16)
+	}
+	screen.BindFramebuffer(g.fb)
+	screen.Viewport(0, 0, 16, 16)
+	screen.Clear()
+	g.camera.Apply(screen, 1)
+	screen.Draw(g.cube, Mat4Identity(), vec4.New(1, 1, 1, 1))
+	screen.BindFramebuffer(nil)
+	screen.Viewport(0, 0, screen.ScreenWidth, screen.ScreenHeight)
+	screen.SetCamera(Mat4Identity(), vec3.New(0, 0, 0), vec3.New(0, 1, 0), vec3.New(1, 0, 0))
+	screen.DrawTextured(g.square, Mat4Identity(), g.fb.Texture)
+}
+
+var (
+	hitColor   = color.RGBA{255, 26, 26, 255}
+	missColor  = color.RGBA{26, 26, 26, 255}
+	clearColor = color.RGBA{}
+)
+
+func TestRunHeadless(t *testing.T) {
+	game := &headlessGame{camera: NewCamera(vec3.New(0, 0, 5))}
+	frames, err := RunHeadless(game, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 || game.updates != 3 {
+		t.Fatalf("Expected 3 frames and updates, got %v and %v", len(frames), game.updates)
+	}
+	if game.frameDt != game.tickDt {
+		t.Errorf("Expected a frame dt of one tick, got %v", game.frameDt)
+	}
+	img := frames[2]
+	if size := img.Bounds().Size(); size.X != 48 || size.Y != 32 {
+		t.Fatalf("Expected the layout size, got %v", size)
+	}
+	if c := img.At(24, 16); c != hitColor {
+		t.Errorf("Expected the sphere at the center, got %v", c)
+	}
+	if c := img.At(0, 0); c != clearColor {
+		t.Errorf("Expected the clear color outside the cube, got %v", c)
+	}
+	// the default scene has a sphere at (2, 0, 0), the cube ends before it.
+	hits := 0
+	for x := 0; x < 48; x++ {
+		if img.At(x, 16) == hitColor {
+			hits++
+		}
+	}
+	if hits == 0 || hits == 48 {
+		t.Errorf("Expected some hits along the middle row, got %v", hits)
+	}
+
+	game.camera.Position = vec3.New(0, 20, 5)
+	frames, err = RunHeadless(game, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := frames[0].At(24, 16); c != clearColor {
+		t.Errorf("Expected the cube outside the view, got %v", c)
+	}
+}
+
+func TestRunHeadlessFramebuffer(t *testing.T) {
+	game := &headlessGame{camera: NewCamera(vec3.New(0, 0, 5)), offscreen: true}
+	frames, err := RunHeadless(game, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := frames[0]
+	if c := img.At(24, 16); c != hitColor {
+		t.Errorf("Expected the sphere at the center, got %v", c)
+	}
+	// the framebuffer covers the whole screen and is cleared to transparent black.
+	if c := img.At(0, 0); c != clearColor {
+		t.Errorf("Expected the framebuffer clear color, got %v", c)
+	}
+}
+
+func TestSoftwareBackendDepth(t *testing.T) {
+	b := newSoftwareBackend(8, 8)
+	screen := Screen{cameraTransform: Mat4Identity(), backend: b}
+	screen.layout(&layoutContext{}, 8, 8, 8, 8)
+	near := Polygon{}
+	near.Load3D([]vec3.Vec3{vec3.New(-1, -1, 0.5), vec3.New(1, -1, 0.5), vec3.New(-1, 1, 0.5)})
+	far := Polygon{}
+	far.Load3DUv([]vec3.Vec3{vec3.New(-1, -1, 0.9), vec3.New(1, -1, 0.9), vec3.New(-1, 1, 0.9)}, nil)
+
+	// the near triangle hits the scene and is drawn first, the far one would miss.
+	screen.SetCamera(Mat4Identity(), vec3.New(0, 0, 0), vec3.New(0, 1, 0), vec3.New(1, 0, 0))
+	b.SetScene(sdf.Sphere{Radius: 5})
+	screen.Draw(near, Mat4Identity(), vec4.New(1, 1, 1, 1))
+	if c := screen.Image().At(1, 6); c != hitColor {
+		t.Errorf("Expected the near triangle, got %v", c)
+	}
+	b.SetScene(sdf.Sphere{Center: vec3.New(100, 0, 0), Radius: 1})
+	screen.Draw(far, Mat4Identity(), vec4.New(1, 1, 1, 1))
+	if c := screen.Image().At(1, 6); c != hitColor {
+		t.Errorf("Expected the far triangle to fail the depth test, got %v", c)
+	}
+	b.Clear()
+	screen.Draw(far, Mat4Identity(), vec4.New(1, 1, 1, 1))
+	if c := screen.Image().At(1, 6); c != missColor {
+		t.Errorf("Expected the far triangle after clearing, got %v", c)
+	}
+	// the upper right half is outside the triangle.
+	if c := screen.Image().At(6, 1); c != clearColor {
+		t.Errorf("Expected the clear color, got %v", c)
+	}
+}
+
+func TestSoftwareBackendLipschitz(t *testing.T) {
+	ball := sdf.Sphere{Center: vec3.New(0, 0, -10), Radius: 1}
+	// the displacement quadruples the distance, the march overshoots unless it
+	// is divided by the Lipschitz factor.
+	scaled := sdf.Displace{
+		Func:      func(p vec3.Vec3) float32 { return 3 * ball.Distance(p) },
+		Amplitude: 30,
+		Slope:     3,
+		Sub:       ball,
+	}
+	b := newSoftwareBackend(1, 1)
+	if err := b.SetScene(scaled); err != nil {
+		t.Fatal(err)
+	}
+	if c := sdfFragment(b.scene, b.lipschitz, vec3.New(0, 0, 0), vec3.New(0, 0, 1)); c != vec4.New(1.0, 0.1, 0.1, 1) {
+		t.Errorf("Expected a hit, got %v", c)
+	}
+	if c := sdfFragment(b.scene, 1, vec3.New(0, 0, 0), vec3.New(0, 0, 1)); c == vec4.New(1.0, 0.1, 0.1, 1) {
+		t.Errorf("Expected the undivided march to overshoot")
+	}
+}
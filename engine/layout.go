@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"image"
+
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
+)
+
+// layoutScreen computes the screen area in framebuffer pixels. The window
+// size is in screen coordinates, which differ from pixels on high DPI
+// displays. ctx.Layout gets the window size and returns the screen size in
+// the same units. The screen is scaled to pixels, shrunk to fit the
+// framebuffer keeping its aspect and centered. Non positive sizes from
+// Layout use the whole window.
+func layoutScreen(ctx MainContext, windowWidth, windowHeight, fbWidth, fbHeight int) (area image.Rectangle, scale float32) {
+	scale = 1
+	if windowWidth > 0 {
+		scale = float32(fbWidth) / float32(windowWidth)
+	}
+	width, height := ctx.Layout(windowWidth, windowHeight)
+	if width <= 0 || height <= 0 {
+		return image.Rect(0, 0, fbWidth, fbHeight), scale
+	}
+	w, h := float32(width)*scale, float32(height)*scale
+	if fit := min(float32(fbWidth)/w, float32(fbHeight)/h); fit < 1 {
+		w, h = w*fit, h*fit
+	}
+	pw, ph := int(w+0.5), int(h+0.5)
+	x, y := (fbWidth-pw)/2, (fbHeight-ph)/2
+	return image.Rect(x, y, x+pw, y+ph), scale
+}
+
+// layout places the screen in the framebuffer and resets the viewport.
+func (s *Screen) layout(ctx MainContext, windowWidth, windowHeight, fbWidth, fbHeight int) {
+	s.area, s.Scale = layoutScreen(ctx, windowWidth, windowHeight, fbWidth, fbHeight)
+	s.fbHeight = fbHeight
+	s.ScreenWidth, s.ScreenHeight = s.area.Dx(), s.area.Dy()
+	s.ResetViewport()
+}
+
+// ResetViewport draws to the whole screen again, after drawing to a framebuffer.
+func (s *Screen) ResetViewport() {
+	s.backend.Viewport(s.area.Min.X, s.area.Min.Y, s.area.Dx(), s.area.Dy())
+}
+
+// ScreenRay returns the world space ray through a screen pixel for the
+// current camera, from the near plane away from the camera. x and y are from
+// the top left corner like WindowToScreen. Without a camera the ray is zero.
+func (s *Screen) ScreenRay(x, y float32) sdf.Ray {
+	ray, _ := s.unproject(s.cameraTransform, x, y)
+	return ray
+}
+
+// unproject returns the ray through a screen pixel from the near to the far
+// plane of a camera transform, and the distance between the planes.
+func (s *Screen) unproject(transform Mat4, x, y float32) (sdf.Ray, float32) {
+	inv, ok := transform.Inverse()
+	if !ok || s.ScreenWidth == 0 || s.ScreenHeight == 0 {
+		return sdf.Ray{}, 0
+	}
+	nx := 2*x/float32(s.ScreenWidth) - 1
+	ny := 1 - 2*y/float32(s.ScreenHeight)
+	unproject := func(z float32) vec3.Vec3 {
+		p := inv.MultiplyVec4(vec4.New(nx, ny, z, 1))
+		return p.XYZ().MultiplyScalar(1 / p.W)
+	}
+	near, far := unproject(-1), unproject(1)
+	return sdf.Ray{Origin: near, Dir: far.Sub(near).Normalize()}, far.Distance(near)
+}
+
+// Pick casts a ray under a cursor position in window coordinates into the
+// scene set by SetScene, with the camera the scene was last drawn with. When
+// the scene is drawn to a framebuffer it is assumed to cover the screen.
+func (s *Screen) Pick(x, y float64) (sdf.Ray, sdf.Hit, bool) {
+	sx, sy := s.WindowToScreen(x, y)
+	ray, far := s.unproject(s.sdfCamera, sx, sy)
+	if s.scene == nil || far == 0 {
+		return ray, sdf.Hit{}, false
+	}
+	hit, ok := sdf.Pick(s.scene, ray, far, sdf.DefaultRaycastSettings())
+	return ray, hit, ok
+}
+
+// pickEvents adds a pick event for each mouse button press of the current frame.
+func (s *Screen) pickEvents(events *EventManager) {
+	for _, e := range events.frame.buttons {
+		if e.Action != Pressed {
+			continue
+		}
+		ray, hit, ok := s.Pick(e.X, e.Y)
+		events.frame.picks = append(events.frame.picks, PickEvent{MouseButtonEvent: e, Ray: ray, Hit: hit, OK: ok})
+	}
+}
+
+// MouseRay returns the world space ray under a cursor position in window coordinates.
+func (s *Screen) MouseRay(x, y float64) sdf.Ray {
+	return s.ScreenRay(s.WindowToScreen(x, y))
+}
+
+// WindowToScreen converts a cursor position in window coordinates, as in
+// MouseMoveEvent, to screen pixels from the top left corner of the screen.
+func (s *Screen) WindowToScreen(x, y float64) (float32, float32) {
+	// the area is measured from the bottom, the cursor from the top.
+	top := s.fbHeight - s.area.Max.Y
+	return float32(x)*s.Scale - float32(s.area.Min.X), float32(y)*s.Scale - float32(top)
+}
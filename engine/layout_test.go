@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"image"
+	"testing"
+)
+
+type layoutContext struct {
+	headlessGame
+	width, height int
+}
+
+func (c *layoutContext) Layout(width, height int) (int, int) {
+	if c.width == 0 {
+		return width, height
+	}
+	return c.width, c.height
+}
+
+func TestLayoutScreen(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		area          image.Rectangle
+	}{
+		{"window size", 0, 0, image.Rect(0, 0, 800, 600)},
+		{"centered", 200, 200, image.Rect(200, 100, 600, 500)},
+		{"shrunk to fit", 1000, 500, image.Rect(0, 100, 800, 500)},
+		{"invalid size", -1, 10, image.Rect(0, 0, 800, 600)},
+	}
+	for _, test := range tests {
+		ctx := &layoutContext{width: test.width, height: test.height}
+		// a high DPI window, 400x300 in screen coordinates.
+		area, scale := layoutScreen(ctx, 400, 300, 800, 600)
+		if area != test.area || scale != 2 {
+			t.Errorf("%v: expected %v at scale 2, got %v at scale %v", test.name, test.area, area, scale)
+		}
+	}
+}
+
+func TestWindowToScreen(t *testing.T) {
+	ctx := &layoutContext{width: 200, height: 100}
+	screen := Screen{backend: newSoftwareBackend(800, 600)}
+	screen.layout(ctx, 400, 300, 800, 600)
+	if screen.ScreenWidth != 400 || screen.ScreenHeight != 200 || screen.Scale != 2 {
+		t.Fatalf("Unexpected screen %vx%v at %v", screen.ScreenWidth, screen.ScreenHeight, screen.Scale)
+	}
+	// the screen is centered, 100 window coordinates from the top and left.
+	x, y := screen.WindowToScreen(100, 100)
+	if x != 0 || y != 0 {
+		t.Errorf("Expected the top left corner, got %v %v", x, y)
+	}
+	x, y = screen.WindowToScreen(300, 200)
+	if x != 400 || y != 200 {
+		t.Errorf("Expected the bottom right corner, got %v %v", x, y)
+	}
+}
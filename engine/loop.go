@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// fixedTimestep turns the real time between frames into a number of fixed
+// length updates. The time left over is the interpolation alpha for drawing.
+type fixedTimestep struct {
+	step        time.Duration
+	accumulator time.Duration
+	// maxTicks limits the updates of one frame, so a slow frame does not make the next ones slower.
+	maxTicks int
+}
+
+func newFixedTimestep(tickRate int) *fixedTimestep {
+	if tickRate <= 0 {
+		tickRate = 60
+	}
+	return &fixedTimestep{step: time.Second / time.Duration(tickRate), maxTicks: 8}
+}
+
+// advance adds the elapsed time and returns the number of updates to run and
+// how far the frame is between the last update and the next, from 0 to 1.
+func (f *fixedTimestep) advance(elapsed time.Duration) (ticks int, alpha float32) {
+	f.accumulator += elapsed
+	ticks = int(f.accumulator / f.step)
+	f.accumulator -= time.Duration(ticks) * f.step
+	if ticks > f.maxTicks {
+		ticks = f.maxTicks
+	}
+	return ticks, float32(f.accumulator) / float32(f.step)
+}
+
+// dt is the update step in seconds.
+func (f *fixedTimestep) dt() float32 {
+	return float32(f.step.Seconds())
+}
+
+// frameStatsSamples is the number of frames the statistics are computed over.
+const frameStatsSamples = 240
+
+// FrameStats keeps the times of the last frames.
+type FrameStats struct {
+	samples []time.Duration
+	next    int
+}
+
+// Add records the time of a frame.
+func (s *FrameStats) Add(frame time.Duration) {
+	if len(s.samples) < frameStatsSamples {
+		s.samples = append(s.samples, frame)
+		return
+	}
+	s.samples[s.next] = frame
+	s.next = (s.next + 1) % frameStatsSamples
+}
+
+func (s *FrameStats) Count() int {
+	return len(s.samples)
+}
+
+func (s *FrameStats) Min() time.Duration {
+	if len(s.samples) == 0 {
+		return 0
+	}
+	return slices.Min(s.samples)
+}
+
+func (s *FrameStats) Max() time.Duration {
+	if len(s.samples) == 0 {
+		return 0
+	}
+	return slices.Max(s.samples)
+}
+
+func (s *FrameStats) Avg() time.Duration {
+	if len(s.samples) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range s.samples {
+		sum += d
+	}
+	return sum / time.Duration(len(s.samples))
+}
+
+// Percentile returns the frame time that p percent of the frames are faster than or equal to.
+func (s *FrameStats) Percentile(p float64) time.Duration {
+	if len(s.samples) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(s.samples)
+	slices.Sort(sorted)
+	i := int(p/100*float64(len(sorted))+0.5) - 1
+	return sorted[max(0, min(len(sorted)-1, i))]
+}
+
+func (s *FrameStats) P99() time.Duration {
+	return s.Percentile(99)
+}
+
+// FPS is the average number of frames per second.
+func (s *FrameStats) FPS() float64 {
+	avg := s.Avg()
+	if avg == 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(avg)
+}
+
+func (s *FrameStats) String() string {
+	return fmt.Sprintf("%.1f fps, min %v, avg %v, p99 %v", s.FPS(), s.Min(), s.Avg(), s.P99())
+}
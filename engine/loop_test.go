@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixedTimestep(t *testing.T) {
+	f := newFixedTimestep(50)
+	if f.dt() != 0.02 {
+		t.Errorf("Expected a step of 0.02, got %v", f.dt())
+	}
+	ticks, alpha := f.advance(10 * time.Millisecond)
+	if ticks != 0 || alpha != 0.5 {
+		t.Errorf("Expected no update half way, got %v %v", ticks, alpha)
+	}
+	ticks, alpha = f.advance(45 * time.Millisecond)
+	if ticks != 2 || alpha != 0.75 {
+		t.Errorf("Expected 2 updates, got %v %v", ticks, alpha)
+	}
+	// a long stall is limited, but the remainder is kept.
+	ticks, alpha = f.advance(time.Second)
+	if ticks != f.maxTicks || alpha != 0.75 {
+		t.Errorf("Expected %v updates, got %v %v", f.maxTicks, ticks, alpha)
+	}
+
+	total := 0
+	f = newFixedTimestep(60)
+	for i := 0; i < 600; i++ {
+		ticks, _ := f.advance(time.Second / 144)
+		total += ticks
+	}
+	if total < 249 || total > 250 {
+		t.Errorf("Expected 250 updates for 600 frames at 144 Hz, got %v", total)
+	}
+}
+
+func TestFrameStats(t *testing.T) {
+	stats := FrameStats{}
+	if stats.FPS() != 0 || stats.P99() != 0 {
+		t.Error("Expected empty statistics")
+	}
+	for i := 1; i <= 100; i++ {
+		stats.Add(time.Duration(i) * time.Millisecond)
+	}
+	if stats.Min() != time.Millisecond || stats.Max() != 100*time.Millisecond {
+		t.Errorf("Unexpected min and max %v %v", stats.Min(), stats.Max())
+	}
+	if stats.Avg() != 50500*time.Microsecond {
+		t.Errorf("Unexpected average %v", stats.Avg())
+	}
+	if stats.P99() != 99*time.Millisecond || stats.Percentile(50) != 50*time.Millisecond {
+		t.Errorf("Unexpected percentiles %v %v", stats.P99(), stats.Percentile(50))
+	}
+
+	// old frames are dropped.
+	for i := 0; i < frameStatsSamples; i++ {
+		stats.Add(10 * time.Millisecond)
+	}
+	if stats.Count() != frameStatsSamples || stats.Max() != 10*time.Millisecond || stats.FPS() != 100 {
+		t.Errorf("Expected only the last frames, got %v", stats.String())
+	}
+}
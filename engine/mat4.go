@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/supersdf-go/engine/quat"
 	"github.com/supersdf-go/engine/vec3"
 	. "github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
 )
 
-// column-major mat4.
+// Mat4 is a column-major 4x4 matrix, the layout gl.UniformMatrix4fv expects
+// without transposing. Element (r, c) is at index r+c*4, so the translation is
+// at 12, 13 and 14. Matrices multiply column vectors: a.Multiply(b) applies b first.
 type Mat4 [16]float32
 
 func Mat4Identity() Mat4 {
@@ -59,11 +63,13 @@ func (a Mat4) Multiply(b Mat4) Mat4 {
 	return result
 }
 
+// Apply transforms a point, ignoring the projective last row. Use
+// MultiplyVec4 for projections.
 func (m *Mat4) Apply(v Vec3) Vec3 {
 	result := vec3.New(
-		m[0]*v.X+m[1]*v.Y+m[2]*v.Z+m[3],
-		m[4]*v.X+m[5]*v.Y+m[6]*v.Z+m[7],
-		m[8]*v.X+m[9]*v.Y+m[10]*v.Z+m[11],
+		m[0]*v.X+m[4]*v.Y+m[8]*v.Z+m[12],
+		m[1]*v.X+m[5]*v.Y+m[9]*v.Z+m[13],
+		m[2]*v.X+m[6]*v.Y+m[10]*v.Z+m[14],
 	)
 	return result
 }
@@ -93,6 +99,172 @@ func (m Mat4) Transpose() Mat4 {
 	}
 }
 
+// MultiplyVec4 multiplies the matrix with a column vector, including w.
+func (m Mat4) MultiplyVec4(v vec4.Vec4) vec4.Vec4 {
+	in := [4]float32{v.X, v.Y, v.Z, v.W}
+	var out [4]float32
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			out[r] += m.Get(r, c) * in[c]
+		}
+	}
+	return vec4.New(out[0], out[1], out[2], out[3])
+}
+
+// cofactors returns the cofactor expansions used by Determinant and Inverse.
+// The layout does not matter, the inverse of the transpose is the transpose of the inverse.
+func (m Mat4) cofactors() Mat4 {
+	var inv Mat4
+	inv[0] = m[5]*m[10]*m[15] - m[5]*m[11]*m[14] - m[9]*m[6]*m[15] + m[9]*m[7]*m[14] + m[13]*m[6]*m[11] - m[13]*m[7]*m[10]
+	inv[4] = -m[4]*m[10]*m[15] + m[4]*m[11]*m[14] + m[8]*m[6]*m[15] - m[8]*m[7]*m[14] - m[12]*m[6]*m[11] + m[12]*m[7]*m[10]
+	inv[8] = m[4]*m[9]*m[15] - m[4]*m[11]*m[13] - m[8]*m[5]*m[15] + m[8]*m[7]*m[13] + m[12]*m[5]*m[11] - m[12]*m[7]*m[9]
+	inv[12] = -m[4]*m[9]*m[14] + m[4]*m[10]*m[13] + m[8]*m[5]*m[14] - m[8]*m[6]*m[13] - m[12]*m[5]*m[10] + m[12]*m[6]*m[9]
+	inv[1] = -m[1]*m[10]*m[15] + m[1]*m[11]*m[14] + m[9]*m[2]*m[15] - m[9]*m[3]*m[14] - m[13]*m[2]*m[11] + m[13]*m[3]*m[10]
+	inv[5] = m[0]*m[10]*m[15] - m[0]*m[11]*m[14] - m[8]*m[2]*m[15] + m[8]*m[3]*m[14] + m[12]*m[2]*m[11] - m[12]*m[3]*m[10]
+	inv[9] = -m[0]*m[9]*m[15] + m[0]*m[11]*m[13] + m[8]*m[1]*m[15] - m[8]*m[3]*m[13] - m[12]*m[1]*m[11] + m[12]*m[3]*m[9]
+	inv[13] = m[0]*m[9]*m[14] - m[0]*m[10]*m[13] - m[8]*m[1]*m[14] + m[8]*m[2]*m[13] + m[12]*m[1]*m[10] - m[12]*m[2]*m[9]
+	inv[2] = m[1]*m[6]*m[15] - m[1]*m[7]*m[14] - m[5]*m[2]*m[15] + m[5]*m[3]*m[14] + m[13]*m[2]*m[7] - m[13]*m[3]*m[6]
+	inv[6] = -m[0]*m[6]*m[15] + m[0]*m[7]*m[14] + m[4]*m[2]*m[15] - m[4]*m[3]*m[14] - m[12]*m[2]*m[7] + m[12]*m[3]*m[6]
+	inv[10] = m[0]*m[5]*m[15] - m[0]*m[7]*m[13] - m[4]*m[1]*m[15] + m[4]*m[3]*m[13] + m[12]*m[1]*m[7] - m[12]*m[3]*m[5]
+	inv[14] = -m[0]*m[5]*m[14] + m[0]*m[6]*m[13] + m[4]*m[1]*m[14] - m[4]*m[2]*m[13] - m[12]*m[1]*m[6] + m[12]*m[2]*m[5]
+	inv[3] = -m[1]*m[6]*m[11] + m[1]*m[7]*m[10] + m[5]*m[2]*m[11] - m[5]*m[3]*m[10] - m[9]*m[2]*m[7] + m[9]*m[3]*m[6]
+	inv[7] = m[0]*m[6]*m[11] - m[0]*m[7]*m[10] - m[4]*m[2]*m[11] + m[4]*m[3]*m[10] + m[8]*m[2]*m[7] - m[8]*m[3]*m[6]
+	inv[11] = -m[0]*m[5]*m[11] + m[0]*m[7]*m[9] + m[4]*m[1]*m[11] - m[4]*m[3]*m[9] - m[8]*m[1]*m[7] + m[8]*m[3]*m[5]
+	inv[15] = m[0]*m[5]*m[10] - m[0]*m[6]*m[9] - m[4]*m[1]*m[10] + m[4]*m[2]*m[9] + m[8]*m[1]*m[6] - m[8]*m[2]*m[5]
+	return inv
+}
+
+func (m Mat4) Determinant() float32 {
+	inv := m.cofactors()
+	return m[0]*inv[0] + m[1]*inv[4] + m[2]*inv[8] + m[3]*inv[12]
+}
+
+// Inverse returns the inverse of the matrix, or false if it is singular.
+func (m Mat4) Inverse() (Mat4, bool) {
+	inv := m.cofactors()
+	det := m[0]*inv[0] + m[1]*inv[4] + m[2]*inv[8] + m[3]*inv[12]
+	if det == 0 {
+		return Mat4{}, false
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, true
+}
+
+// upper3 returns the rotation and scale part of the matrix.
+func (m Mat4) upper3() [3][3]float32 {
+	var u [3][3]float32
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			u[r][c] = m.Get(r, c)
+		}
+	}
+	return u
+}
+
+// inverse3 inverts a 3x3 matrix, a singular matrix gives zeros.
+func inverse3(u [3][3]float32) [3][3]float32 {
+	var inv [3][3]float32
+	inv[0][0] = u[1][1]*u[2][2] - u[1][2]*u[2][1]
+	inv[0][1] = u[0][2]*u[2][1] - u[0][1]*u[2][2]
+	inv[0][2] = u[0][1]*u[1][2] - u[0][2]*u[1][1]
+	inv[1][0] = u[1][2]*u[2][0] - u[1][0]*u[2][2]
+	inv[1][1] = u[0][0]*u[2][2] - u[0][2]*u[2][0]
+	inv[1][2] = u[0][2]*u[1][0] - u[0][0]*u[1][2]
+	inv[2][0] = u[1][0]*u[2][1] - u[1][1]*u[2][0]
+	inv[2][1] = u[0][1]*u[2][0] - u[0][0]*u[2][1]
+	inv[2][2] = u[0][0]*u[1][1] - u[0][1]*u[1][0]
+	det := u[0][0]*inv[0][0] + u[0][1]*inv[1][0] + u[0][2]*inv[2][0]
+	if det == 0 {
+		return [3][3]float32{}
+	}
+	for r := range inv {
+		for c := range inv[r] {
+			inv[r][c] /= det
+		}
+	}
+	return inv
+}
+
+// InverseAffine inverts a matrix without projection, where the last row is 0 0 0 1.
+// It is cheaper and more precise than Inverse for model and view matrices.
+func (m Mat4) InverseAffine() Mat4 {
+	inv := inverse3(m.upper3())
+	result := Mat4Identity()
+	t := [3]float32{m.Get(0, 3), m.Get(1, 3), m.Get(2, 3)}
+	for r := 0; r < 3; r++ {
+		var tr float32
+		for c := 0; c < 3; c++ {
+			result.Set(r, c, inv[r][c])
+			tr -= inv[r][c] * t[c]
+		}
+		result.Set(r, 3, tr)
+	}
+	return result
+}
+
+// NormalMatrix is the inverse transpose of the rotation and scale part, which
+// keeps normals perpendicular to surfaces under non-uniform scaling.
+func (m Mat4) NormalMatrix() Mat4 {
+	inv := inverse3(m.upper3())
+	result := Mat4Identity()
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			result.Set(r, c, inv[c][r])
+		}
+	}
+	return result
+}
+
+// Decompose splits an affine matrix into translation * rotation * scale.
+// A mirroring matrix is returned with a negative X scale.
+func (m Mat4) Decompose() (translation Vec3, rotation Mat4, scale Vec3) {
+	translation = vec3.New(m.Get(0, 3), m.Get(1, 3), m.Get(2, 3))
+	var axes [3]Vec3
+	for c := 0; c < 3; c++ {
+		axes[c] = vec3.New(m.Get(0, c), m.Get(1, c), m.Get(2, c))
+	}
+	scale = vec3.New(axes[0].Length(), axes[1].Length(), axes[2].Length())
+	if axes[0].CrossProduct(axes[1]).DotProduct(axes[2]) < 0 {
+		scale.X = -scale.X
+	}
+	s := [3]float32{scale.X, scale.Y, scale.Z}
+	rotation = Mat4Identity()
+	for c := 0; c < 3; c++ {
+		if s[c] == 0 {
+			continue
+		}
+		a := axes[c].MultiplyScalar(1 / s[c])
+		rotation.Set(0, c, a.X)
+		rotation.Set(1, c, a.Y)
+		rotation.Set(2, c, a.Z)
+	}
+	return translation, rotation, scale
+}
+
+// LookAt returns a view matrix for a camera at eye looking at target, with
+// the camera looking down -Z like Camera.View.
+func LookAt(eye, target, up Vec3) Mat4 {
+	forward := target.Subtract(eye).Normalize()
+	right := forward.CrossProduct(up).Normalize()
+	trueUp := right.CrossProduct(forward)
+	m := Mat4Identity()
+	for c, v := range [3]float32{right.X, right.Y, right.Z} {
+		m.Set(0, c, v)
+	}
+	for c, v := range [3]float32{trueUp.X, trueUp.Y, trueUp.Z} {
+		m.Set(1, c, v)
+	}
+	for c, v := range [3]float32{-forward.X, -forward.Y, -forward.Z} {
+		m.Set(2, c, v)
+	}
+	m.Set(0, 3, -right.DotProduct(eye))
+	m.Set(1, 3, -trueUp.DotProduct(eye))
+	m.Set(2, 3, forward.DotProduct(eye))
+	return m
+}
+
 func PerspectiveMatrix(fov, aspect, near, far float32) Mat4 {
 	// Convert field of view to radians
 	fovRad := fov
@@ -102,11 +274,12 @@ func PerspectiveMatrix(fov, aspect, near, far float32) Mat4 {
 	zfar := far
 	aspectInv := 1.0 / aspect
 
+	// columns, near maps to -1 and far to 1 like glFrustum.
 	return Mat4{
 		f * aspectInv, 0, 0, 0,
 		0, f, 0, 0,
-		0, 0, -(zfar + znear) / (znear - zfar), -1,
-		0, 0, (-2 * zfar * znear) / (znear - zfar), 0,
+		0, 0, (zfar + znear) / (znear - zfar), -1,
+		0, 0, (2 * zfar * znear) / (znear - zfar), 0,
 	}
 }
 
@@ -116,40 +289,20 @@ func OrthographicMatrix(left, right, bottom, top, near, far float32) Mat4 {
 		0, 2 / (top - bottom), 0, -(top + bottom) / (top - bottom),
 		0, 0, -2 / (far - near), -(far + near) / (far - near),
 		0, 0, 0, 1,
-	}
+	}.Transpose() // written by rows
 }
 
-// RotationMatrix generates a 4x4 rotation matrix for the specified angle and axis.
+// RotationMatrix0 is the same as RotationMatrix.
+//
+// Deprecated: it mixed up the axes, use RotationMatrix.
 func RotationMatrix0(angle float32, axis Vec3) Mat4 {
-	cos := float32(math.Cos(float64(angle)))
-	sin := float32(math.Sin(float64(angle)))
-	oneMinusCos := 1.0 - cos
-
-	xx := axis.X * axis.X
-	yy := axis.Y * axis.Y
-	zz := axis.Z * axis.Z
-	xy := axis.X * axis.Y
-	xz := axis.X * axis.Z
-	yz := axis.Y * axis.Z
-
-	return Mat4{
-		cos + xx*oneMinusCos,
-		axis.Y*sin + xy*oneMinusCos,
-		-axis.Y*sin + xz*oneMinusCos,
-		0,
-		-axis.X*sin + xy*oneMinusCos,
-		cos + yy*oneMinusCos,
-		axis.X*sin + yz*oneMinusCos,
-		0,
-		axis.Y*sin + xz*oneMinusCos,
-		-axis.X*sin + yz*oneMinusCos,
-		cos + zz*oneMinusCos,
-		0,
-		0, 0, 0, 1,
-	}.Transpose()
+	return RotationMatrix(angle, axis)
 }
 
+// RotationMatrix rotates counter clockwise by angle around axis, seen
+// looking from the tip of the axis towards the origin.
 func RotationMatrix(angle float32, axis Vec3) Mat4 {
+	axis = axis.Normalize()
 	rad := angle
 	cosA := float32(math.Cos(float64(rad)))
 	sinA := float32(math.Sin(float64(rad)))
@@ -160,9 +313,30 @@ func RotationMatrix(angle float32, axis Vec3) Mat4 {
 		axis.Y*axis.X*invCosA + axis.Z*sinA, cosA + axis.Y*axis.Y*invCosA, axis.Y*axis.Z*invCosA - axis.X*sinA, 0,
 		axis.Z*axis.X*invCosA - axis.Y*sinA, axis.Z*axis.Y*invCosA + axis.X*sinA, cosA + axis.Z*axis.Z*invCosA, 0,
 		0, 0, 0, 1,
+	}.Transpose() // written by rows
+}
+
+// Mat4Rotation returns the rotation of a unit quaternion.
+func Mat4Rotation(q quat.Quat) Mat4 {
+	return q.Matrix()
+}
+
+// Mat4TRS scales, then rotates and then translates.
+func Mat4TRS(translation Vec3, rotation quat.Quat, scale Vec3) Mat4 {
+	m := Mat4Rotation(rotation)
+	for c, s := range [3]float32{scale.X, scale.Y, scale.Z} {
+		for r := 0; r < 3; r++ {
+			m.Set(r, c, m.Get(r, c)*s)
+		}
 	}
+	m.Set(0, 3, translation.X)
+	m.Set(1, 3, translation.Y)
+	m.Set(2, 3, translation.Z)
+	return m
 }
 
+// RotationMatrix2 rotates world space into a camera space with the given
+// up and right vectors, the rows are right, up and backwards.
 func RotationMatrix2(up, right Vec3) Mat4 {
 
 	// Calculate the forward vector using the cross product of up and right
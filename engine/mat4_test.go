@@ -2,9 +2,13 @@ package engine
 
 import (
 	"math"
+	"math/rand"
 	"testing"
 
+	"github.com/supersdf-go/engine/quat"
+	"github.com/supersdf-go/engine/vec3"
 	. "github.com/supersdf-go/engine/vec3"
+	"github.com/supersdf-go/engine/vec4"
 )
 
 func TestMat4Identity(t *testing.T) {
@@ -130,3 +134,286 @@ func TestMat4Rotate(t *testing.T) {
 
 	}
 }
+
+func mat4Near(a, b Mat4, epsilon float32) bool {
+	for i := range a {
+		if float32(math.Abs(float64(a[i]-b[i]))) > epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// randomAffine returns translation * rotation * scale with random values.
+func randomAffine(r *rand.Rand) Mat4 {
+	axis := vec3.New(r.Float32()-0.5, r.Float32()-0.5, r.Float32()-0.5).Normalize()
+	t := Mat4Translation(r.Float32()*10-5, r.Float32()*10-5, r.Float32()*10-5)
+	s := Mat4Scale(r.Float32()*2+0.5, r.Float32()*2+0.5, r.Float32()*2+0.5)
+	return t.Multiply(RotationMatrix(r.Float32()*6, axis)).Multiply(s)
+}
+
+func TestMat4Determinant(t *testing.T) {
+	if d := Mat4Scale(2, 3, 4).Determinant(); d != 24 {
+		t.Errorf("Expected 24, got %v", d)
+	}
+	if d := Mat4Translation(1, 2, 3).Determinant(); d != 1 {
+		t.Errorf("Expected translations to keep volume, got %v", d)
+	}
+	singular := Mat4{
+		1, 2, 3, 4,
+		2, 4, 6, 8,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+	}
+	if d := singular.Determinant(); d != 0 {
+		t.Errorf("Expected 0 for linearly dependent columns, got %v", d)
+	}
+	if _, ok := singular.Inverse(); ok {
+		t.Errorf("Expected no inverse of a singular matrix")
+	}
+	m := Mat4{
+		2, 0, 0, 0,
+		1, 3, 0, 0,
+		4, 5, 6, 0,
+		7, 8, 9, 1,
+	}
+	if d := m.Determinant(); d != 36 {
+		t.Errorf("Expected the product of the diagonal of a triangular matrix, got %v", d)
+	}
+}
+
+func TestMat4Inverse(t *testing.T) {
+	inv, ok := Mat4Scale(2, 4, 8).Inverse()
+	if !ok || !mat4Near(inv, Mat4Scale(0.5, 0.25, 0.125), 1e-6) {
+		t.Errorf("Unexpected inverse of a scale %v", inv)
+	}
+	inv, _ = Mat4Translation(1, 2, 3).Inverse()
+	if !mat4Near(inv, Mat4Translation(-1, -2, -3), 1e-6) {
+		t.Errorf("Unexpected inverse of a translation %v", inv)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		m := randomAffine(r)
+		inv, ok := m.Inverse()
+		if !ok {
+			t.Fatalf("Expected an inverse of %v", m)
+		}
+		if !mat4Near(m.Multiply(inv), Mat4Identity(), 1e-4) || !mat4Near(inv.Multiply(m), Mat4Identity(), 1e-4) {
+			t.Fatalf("Expected identity round trips for %v", m)
+		}
+		if !mat4Near(m.InverseAffine(), inv, 1e-4) {
+			t.Fatalf("Expected InverseAffine to match Inverse, got %v and %v", m.InverseAffine(), inv)
+		}
+	}
+
+	p := PerspectiveMatrix(1.2, 1.5, 1, 100)
+	inv, ok = p.Inverse()
+	if !ok || !mat4Near(p.Multiply(inv), Mat4Identity(), 1e-4) {
+		t.Errorf("Expected the perspective matrix to invert")
+	}
+}
+
+func TestMat4MultiplyVec4(t *testing.T) {
+	m := Mat4Translation(1, 2, 3).Multiply(Mat4Scale(2, 2, 2))
+	if v := m.MultiplyVec4(vec4.New(1, 1, 1, 1)); v != vec4.New(3, 4, 5, 1) {
+		t.Errorf("Expected a point to be scaled and moved, got %v", v)
+	}
+	if v := m.MultiplyVec4(vec4.New(1, 1, 1, 0)); v != vec4.New(2, 2, 2, 0) {
+		t.Errorf("Expected a direction to ignore the translation, got %v", v)
+	}
+	clip := PerspectiveMatrix(1.2, 1, 1, 100).MultiplyVec4(vec4.New(0, 0, -10, 1))
+	if clip.W != 10 {
+		t.Errorf("Expected w to be the distance in front of the camera, got %v", clip)
+	}
+}
+
+func TestLookAt(t *testing.T) {
+	view := LookAt(vec3.New(1, 2, 3), vec3.New(1, 2, -7), vec3.New(0, 1, 0))
+	if p := transformPoint(view, vec3.New(1, 2, -7)); !p.ApproxEqual(vec3.New(0, 0, -10), 1e-4) {
+		t.Errorf("Expected the target straight ahead, got %v", p)
+	}
+	if p := transformPoint(view, vec3.New(1, 3, 3)); !p.ApproxEqual(vec3.New(0, 1, 0), 1e-4) {
+		t.Errorf("Expected up to stay up, got %v", p)
+	}
+
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 50; i++ {
+		c := NewCamera(vec3.New(r.Float32()*10-5, r.Float32()*10-5, r.Float32()*10-5))
+		c.Yaw = r.Float32()*6 - 3
+		c.SetPitch(r.Float32()*2 - 1)
+		view := LookAt(c.Position, c.Position.Add(c.Forward()), vec3.New(0, 1, 0))
+		if !mat4Near(view, c.View(), 1e-4) {
+			t.Fatalf("Expected LookAt to match the camera view, got %v and %v", view, c.View())
+		}
+	}
+}
+
+func TestMat4Decompose(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 100; i++ {
+		axis := vec3.New(r.Float32()-0.5, r.Float32()-0.5, r.Float32()-0.5).Normalize()
+		rotation := RotationMatrix(r.Float32()*6, axis)
+		translation := vec3.New(r.Float32(), r.Float32(), r.Float32())
+		scale := vec3.New(r.Float32()+0.5, r.Float32()+0.5, r.Float32()+0.5)
+		m := Mat4Translation(translation.X, translation.Y, translation.Z).Multiply(rotation).Multiply(Mat4Scale(scale.X, scale.Y, scale.Z))
+		tr, rot, sc := m.Decompose()
+		if !tr.ApproxEqual(translation, 1e-4) || !sc.ApproxEqual(scale, 1e-4) || !mat4Near(rot, rotation, 1e-4) {
+			t.Fatalf("Unexpected decomposition %v %v %v", tr, rot, sc)
+		}
+	}
+	_, rot, sc := Mat4Scale(-2, 3, 4).Decompose()
+	if !sc.ApproxEqual(vec3.New(-2, 3, 4), 1e-4) || !mat4Near(rot, Mat4Identity(), 1e-6) {
+		t.Errorf("Expected a mirror to decompose to a negative X scale, got %v %v", sc, rot)
+	}
+}
+
+func TestMat4NormalMatrix(t *testing.T) {
+	// a plane with normal (1, 1, 0) stays perpendicular to its normal when stretched.
+	m := RotationMatrix(0.3, vec3.New(0, 0, 1)).Multiply(Mat4Scale(4, 1, 1))
+	normal := transformPoint(m.NormalMatrix(), vec3.New(1, 1, 0))
+	tangent := transformPoint(m, vec3.New(1, -1, 0))
+	if d := normal.DotProduct(tangent); math.Abs(float64(d)) > 1e-5 {
+		t.Errorf("Expected the normal perpendicular to the surface, got dot %v", d)
+	}
+	rotation := RotationMatrix(0.3, vec3.New(0, 0, 1))
+	if !mat4Near(rotation.NormalMatrix(), rotation, 1e-5) {
+		t.Errorf("Expected the normal matrix of a rotation to be the rotation")
+	}
+}
+
+// randomMat4 returns a matrix with every element random, including projective ones.
+func randomMat4(r *rand.Rand) Mat4 {
+	var m Mat4
+	for i := range m {
+		m[i] = r.Float32()*4 - 2
+	}
+	return m
+}
+
+func TestMat4Layout(t *testing.T) {
+	m := Mat4Translation(1, 2, 3)
+	if m[12] != 1 || m[13] != 2 || m[14] != 3 || m.Get(0, 3) != 1 {
+		t.Errorf("Expected the translation in the last column at 12, 13 and 14, got %v", m)
+	}
+	if p := m.Apply(vec3.New(1, 1, 1)); p != vec3.New(2, 3, 4) {
+		t.Errorf("Expected Apply to translate, got %v", p)
+	}
+	var n Mat4
+	n.Set(1, 2, 5)
+	if n[9] != 5 || n.Transpose()[6] != 5 {
+		t.Errorf("Expected (r, c) at r+c*4, got %v", n)
+	}
+}
+
+func TestMat4Properties(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	for i := 0; i < 200; i++ {
+		a, b := randomAffine(r), randomAffine(r)
+		v := vec3.New(r.Float32()*10-5, r.Float32()*10-5, r.Float32()*10-5)
+		ab := a.Multiply(b)
+		bv := b.Apply(v)
+		if p, q := ab.Apply(v), a.Apply(bv); p.Subtract(q).Length() > 1e-3 {
+			t.Fatalf("Expected Apply(a*b, v) == Apply(a, Apply(b, v)), got %v and %v", p, q)
+		}
+		if p, q := a.Apply(v), transformPoint(a, v); !p.ApproxEqual(q, 1e-4) {
+			t.Fatalf("Expected Apply to multiply by the columns, got %v and %v", p, q)
+		}
+		if ps := a.ApplyN([]Vec3{v, v}); ps[1] != a.Apply(v) {
+			t.Fatalf("Expected ApplyN to match Apply")
+		}
+
+		c, d := randomMat4(r), randomMat4(r)
+		w := vec4.New(v.X, v.Y, v.Z, r.Float32())
+		x, y := c.Multiply(d).MultiplyVec4(w), c.MultiplyVec4(d.MultiplyVec4(w))
+		if x.Subtract(y).Length() > 1e-3 || math.Abs(float64(x.W-y.W)) > 1e-3 {
+			t.Fatalf("Expected MultiplyVec4(c*d, w) == MultiplyVec4(c, MultiplyVec4(d, w)), got %v and %v", x, y)
+		}
+		if !mat4Near(c.Multiply(d).Transpose(), d.Transpose().Multiply(c.Transpose()), 1e-4) {
+			t.Fatalf("Expected (c*d)^T == d^T * c^T")
+		}
+		if det := c.Multiply(d).Determinant(); math.Abs(float64(det-c.Determinant()*d.Determinant())) > 1e-2 {
+			t.Fatalf("Expected det(c*d) == det(c) * det(d), got %v", det)
+		}
+	}
+}
+
+func TestRotationMatrixProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for i := 0; i < 100; i++ {
+		axis := vec3.New(r.Float32()-0.5, r.Float32()-0.5, r.Float32()-0.5).Normalize()
+		angle := r.Float32()*8 - 4
+		m := RotationMatrix(angle, axis)
+		if d := m.Determinant(); math.Abs(float64(d-1)) > 1e-4 {
+			t.Fatalf("Expected a rotation to keep volume, got %v", d)
+		}
+		if !mat4Near(m.Multiply(m.Transpose()), Mat4Identity(), 1e-4) {
+			t.Fatalf("Expected an orthonormal rotation")
+		}
+		if p := m.Apply(axis); !p.ApproxEqual(axis, 1e-4) {
+			t.Fatalf("Expected the axis to stay fixed, got %v", p)
+		}
+		if !mat4Near(RotationMatrix(-angle, axis), m.Transpose(), 1e-5) {
+			t.Fatalf("Expected the opposite angle to be the inverse")
+		}
+		if !mat4Near(RotationMatrix0(angle, axis), m, 0) {
+			t.Fatalf("Expected RotationMatrix0 to match RotationMatrix")
+		}
+	}
+}
+
+func TestProjectionMatrices(t *testing.T) {
+	ndc := func(m Mat4, p Vec3) Vec3 {
+		v := m.MultiplyVec4(vec4.New(p.X, p.Y, p.Z, 1))
+		return vec3.New(v.X/v.W, v.Y/v.W, v.Z/v.W)
+	}
+	p := PerspectiveMatrix(math.Pi/2, 2, 1, 100)
+	if v := ndc(p, vec3.New(0, 0, -1)); !v.ApproxEqual(vec3.New(0, 0, -1), 1e-4) {
+		t.Errorf("Expected near to map to -1, got %v", v)
+	}
+	if v := ndc(p, vec3.New(0, 0, -100)); !v.ApproxEqual(vec3.New(0, 0, 1), 1e-4) {
+		t.Errorf("Expected far to map to 1, got %v", v)
+	}
+	if v := ndc(p, vec3.New(2, 1, -1)); !v.ApproxEqual(vec3.New(1, 1, -1), 1e-4) {
+		t.Errorf("Expected the top right corner of the near plane, got %v", v)
+	}
+	if ndc(p, vec3.New(0, 0, -10)).Z >= ndc(p, vec3.New(0, 0, -20)).Z {
+		t.Errorf("Expected nearer points to have smaller depth, for GL_LESS")
+	}
+
+	o := OrthographicMatrix(-2, 4, -1, 3, 1, 11)
+	if v := ndc(o, vec3.New(-2, -1, -1)); !v.ApproxEqual(vec3.New(-1, -1, -1), 1e-4) {
+		t.Errorf("Expected the near bottom left corner at -1, got %v", v)
+	}
+	if v := ndc(o, vec3.New(4, 3, -11)); !v.ApproxEqual(vec3.New(1, 1, 1), 1e-4) {
+		t.Errorf("Expected the far top right corner at 1, got %v", v)
+	}
+}
+
+func TestMat4Quat(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	for i := 0; i < 100; i++ {
+		axis := vec3.New(r.Float32()-0.5, r.Float32()-0.5, r.Float32()-0.5)
+		angle := r.Float32()*8 - 4
+		q := quat.FromAxisAngle(axis, angle)
+		// RotationMatrix normalizes the axis like the quaternion.
+		if !mat4Near(Mat4Rotation(q), RotationMatrix(angle, axis.MultiplyScalar(3)), 1e-5) {
+			t.Fatalf("Expected the quaternion matrix to match RotationMatrix")
+		}
+		translation := vec3.New(r.Float32(), r.Float32(), r.Float32())
+		scale := vec3.New(r.Float32()+0.5, r.Float32()+0.5, r.Float32()+0.5)
+		m := Mat4TRS(translation, q, scale)
+		tr, rot, sc := m.Decompose()
+		if !tr.ApproxEqual(translation, 1e-4) || !sc.ApproxEqual(scale, 1e-4) || math.Abs(float64(quat.FromMatrix(rot).Dot(q))) < 1-1e-5 {
+			t.Fatalf("Expected Decompose to invert Mat4TRS, got %v %v %v", tr, rot, sc)
+		}
+	}
+	c := NewCamera(Vec3{})
+	c.Yaw, c.Pitch = 0.7, -0.3
+	if p := c.Orientation().Rotate(vec3.New(0, 0, -1)); !p.ApproxEqual(c.Forward(), 1e-4) {
+		t.Errorf("Expected the orientation to turn -Z forward, got %v and %v", p, c.Forward())
+	}
+	if p := c.Orientation().Rotate(vec3.New(1, 0, 0)); !p.ApproxEqual(c.Right(), 1e-4) {
+		t.Errorf("Expected the orientation to turn X right, got %v and %v", p, c.Right())
+	}
+}
@@ -0,0 +1,319 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	remotevm "github.com/rolfrm/remotevm"
+	"github.com/supersdf-go/engine/quat"
+	"github.com/supersdf-go/engine/save"
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+// Node is an element of the scene graph. Its transform is relative to the
+// parent and the world transform is cached until the node or one of its
+// parents moves.
+type Node struct {
+	Name       string
+	Components []Component
+
+	parent    *Node
+	children  []*Node
+	transform Mat4
+	world     Mat4
+	dirty     bool
+}
+
+// Component is data attached to a node, such as a *Polygon, *SdfComponent,
+// *Camera or *Light. Components implementing Updater are updated with the node.
+type Component interface{}
+
+// Updater is a component that changes over time.
+type Updater interface {
+	Update(node *Node, dt float32)
+}
+
+// SdfComponent adds a distance field to the scene, drawn by Node.Draw and
+// collided with by PhysicsWorld. There are no transform nodes in the SDF
+// tree yet, so the Sdf is in world space.
+type SdfComponent struct {
+	Sdf sdf.Sdf
+}
+
+// Light is a point light at the node's position.
+type Light struct {
+	Color     vec3.Vec3
+	Intensity float32
+	// Range is the distance where the light has no effect, 0 is unlimited.
+	Range float32
+}
+
+func NewNode(name string, components ...Component) *Node {
+	return &Node{Name: name, Components: components, transform: Mat4Identity(), world: Mat4Identity()}
+}
+
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Children returns the children of the node, the slice must not be modified.
+func (n *Node) Children() []*Node {
+	return n.children
+}
+
+// AddChild attaches child to the node, detaching it from its previous parent.
+// It panics if the child is the node itself or one of its parents.
+func (n *Node) AddChild(child *Node) {
+	for p := n; p != nil; p = p.parent {
+		if p == child {
+			panic(fmt.Sprintf("scene graph cycle adding %q to %q", child.Name, n.Name))
+		}
+	}
+	child.Detach()
+	child.parent = n
+	n.children = append(n.children, child)
+	child.invalidate()
+}
+
+// Detach removes the node from its parent, keeping its local transform.
+func (n *Node) Detach() {
+	p := n.parent
+	if p == nil {
+		return
+	}
+	for i, c := range p.children {
+		if c == n {
+			p.children = append(p.children[:i], p.children[i+1:]...)
+			break
+		}
+	}
+	n.parent = nil
+	n.invalidate()
+}
+
+// Transform is the transform relative to the parent.
+func (n *Node) Transform() Mat4 {
+	return n.transform
+}
+
+func (n *Node) SetTransform(transform Mat4) {
+	n.transform = transform
+	n.invalidate()
+}
+
+// SetTRS sets the transform from a translation, rotation and scale.
+func (n *Node) SetTRS(translation vec3.Vec3, rotation quat.Quat, scale vec3.Vec3) {
+	n.SetTransform(Mat4TRS(translation, rotation, scale))
+}
+
+// invalidate marks the world transforms of the node and its children as
+// outdated. A node is only clean when its parents are, so the children of a
+// dirty node are already dirty.
+func (n *Node) invalidate() {
+	if n.dirty {
+		return
+	}
+	n.dirty = true
+	for _, c := range n.children {
+		c.invalidate()
+	}
+}
+
+// WorldTransform is the transform from the node to world space.
+func (n *Node) WorldTransform() Mat4 {
+	if n.dirty {
+		if n.parent == nil {
+			n.world = n.transform
+		} else {
+			n.world = n.parent.WorldTransform().Multiply(n.transform)
+		}
+		n.dirty = false
+	}
+	return n.world
+}
+
+// WorldPosition is the origin of the node in world space.
+func (n *Node) WorldPosition() vec3.Vec3 {
+	w := n.WorldTransform()
+	return vec3.New(w.Get(0, 3), w.Get(1, 3), w.Get(2, 3))
+}
+
+// Path is the names from the root to the node separated by '/', the root's
+// name is not included, so root.Find(n.Path()) returns n.
+func (n *Node) Path() string {
+	if n.parent == nil {
+		return ""
+	}
+	if n.parent.parent == nil {
+		return n.Name
+	}
+	return n.parent.Path() + "/" + n.Name
+}
+
+// Find returns the descendant at a path relative to the node, like "level/door/handle".
+// When siblings share a name the first one is used.
+func (n *Node) Find(path string) *Node {
+	node := n
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		var next *Node
+		for _, c := range node.children {
+			if c.Name == name {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// SaveProvider stores the transforms of the descendants of the node by their
+// path. Saved nodes that no longer exist are skipped, new nodes keep their
+// transform.
+func (n *Node) SaveProvider(name string) save.Provider {
+	return save.FuncProvider{
+		Command: remotevm.Command{
+			Name:      name,
+			Arguments: []remotevm.Type{remotevm.Type_U8_Array},
+			Func: func(data []byte) error {
+				saved := map[string]Mat4{}
+				if err := json.Unmarshal(data, &saved); err != nil {
+					return err
+				}
+				for path, transform := range saved {
+					if node := n.Find(path); node != nil && node != n {
+						node.SetTransform(transform)
+					}
+				}
+				return nil
+			},
+		},
+		SaveFn: func(w *save.Writer) error {
+			saved := map[string]Mat4{}
+			prefix := n.Path() + "/"
+			n.Walk(func(node *Node) bool {
+				if node != n {
+					saved[strings.TrimPrefix(node.Path(), prefix)] = node.transform
+				}
+				return true
+			})
+			data, err := json.Marshal(saved)
+			if err != nil {
+				return err
+			}
+			return w.Call(name, data)
+		},
+	}
+}
+
+// Walk calls visit for the node and its descendants, parents before children.
+// When visit returns false the children of that node are skipped.
+func (n *Node) Walk(visit func(node *Node) bool) {
+	if !visit(n) {
+		return
+	}
+	for _, c := range n.children {
+		c.Walk(visit)
+	}
+}
+
+// FindComponent returns the first component of type T on the node.
+func FindComponent[T Component](n *Node) (T, bool) {
+	for _, c := range n.Components {
+		if t, ok := c.(T); ok {
+			return t, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Update updates the Updater components of the node and its descendants.
+func (n *Node) Update(dt float32) {
+	n.Walk(func(node *Node) bool {
+		for _, c := range node.Components {
+			if u, ok := c.(Updater); ok {
+				u.Update(node, dt)
+			}
+		}
+		return true
+	})
+}
+
+// Draw draws the polygons of the node and its descendants with their world
+// transforms, skipping those outside the view frustum. Their SdfComponents
+// are added to the SDF scene of the screen.
+func (n *Node) Draw(screen *Screen) {
+	if err := screen.setNodeScene(n.Sdf()); err != nil {
+		fmt.Fprintf(os.Stderr, "node sdf failed: %v\n", err)
+	}
+	frustum := screen.Frustum()
+	n.Walk(func(node *Node) bool {
+		for _, c := range node.Components {
+			p, ok := c.(*Polygon)
+			if !ok {
+				continue
+			}
+			world := node.WorldTransform()
+			if !frustum.IntersectsAABB(p.Bounds().Transform(world)) {
+				screen.culling.Culled++
+				continue
+			}
+			screen.culling.Drawn++
+			screen.Draw(*p, world, p.Color)
+		}
+		return true
+	})
+}
+
+// setNodeScene adds the Sdf of the drawn nodes to the scene. The scene is
+// only replaced when it changed, on errors the nodes are left out until then.
+func (s *Screen) setNodeScene(scene sdf.Sdf) error {
+	if sameSdf(scene, s.triedNodeScene) {
+		return nil
+	}
+	s.triedNodeScene = scene
+	old := s.nodeScene
+	s.nodeScene = scene
+	if err := s.SetScene(s.scene); err != nil {
+		s.nodeScene = old
+		return err
+	}
+	return nil
+}
+
+func sameSdf(a, b sdf.Sdf) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return sdf.CompareSdfs(a, b)
+}
+
+// Sdf returns the union of the SdfComponents in the node and its descendants, or nil.
+func (n *Node) Sdf() sdf.Sdf {
+	var union sdf.Union
+	n.Walk(func(node *Node) bool {
+		for _, c := range node.Components {
+			if s, ok := c.(*SdfComponent); ok && s.Sdf != nil {
+				union = append(union, s.Sdf)
+			}
+		}
+		return true
+	})
+	switch len(union) {
+	case 0:
+		return nil
+	case 1:
+		return union[0]
+	}
+	return union
+}
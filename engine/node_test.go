@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/supersdf-go/engine/save"
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestNodeTransformPropagation(t *testing.T) {
+	root := NewNode("root")
+	arm := NewNode("arm")
+	hand := NewNode("hand")
+	root.AddChild(arm)
+	arm.AddChild(hand)
+
+	rotation := RotationMatrix(math.Pi/2, vec3.New(0, 0, 1))
+	root.SetTransform(Mat4Translation(1, 0, 0))
+	arm.SetTransform(rotation)
+	hand.SetTransform(Mat4Translation(2, 0, 0))
+	expected := vec3.New(1, 0, 0).Add(transformPoint(rotation, vec3.New(2, 0, 0)))
+	if p := hand.WorldPosition(); !p.ApproxEqual(expected, 1e-4) {
+		t.Errorf("Expected the hand at %v, got %v", expected, p)
+	}
+
+	// Moving a parent moves the cached children.
+	root.SetTransform(Mat4Translation(0, 0, 3))
+	expected = vec3.New(0, 0, 3).Add(transformPoint(rotation, vec3.New(2, 0, 0)))
+	if p := hand.WorldPosition(); !p.ApproxEqual(expected, 1e-4) {
+		t.Errorf("Expected the hand at %v, got %v", expected, p)
+	}
+	expected = vec3.New(0, 0, 3).Add(transformPoint(rotation, vec3.New(3, 0, 0)))
+	if p := transformPoint(hand.WorldTransform(), vec3.New(1, 0, 0)); !p.ApproxEqual(expected, 1e-4) {
+		t.Errorf("Expected the hand's x axis rotated to %v, got %v", expected, p)
+	}
+
+	// Reparenting keeps the local transform.
+	root.AddChild(hand)
+	if hand.Parent() != root || len(arm.Children()) != 0 {
+		t.Fatalf("Expected the hand moved to the root")
+	}
+	if p := hand.WorldPosition(); !p.ApproxEqual(vec3.New(2, 0, 3), 1e-4) {
+		t.Errorf("Expected the hand at (2, 0, 3), got %v", p)
+	}
+	hand.Detach()
+	if p := hand.WorldPosition(); !p.ApproxEqual(vec3.New(2, 0, 0), 1e-4) {
+		t.Errorf("Expected the detached hand at (2, 0, 0), got %v", p)
+	}
+}
+
+func TestNodeWorldTransformCache(t *testing.T) {
+	root := NewNode("root")
+	a := NewNode("a")
+	b := NewNode("b")
+	root.AddChild(a)
+	a.AddChild(b)
+	b.WorldTransform()
+	if root.dirty || a.dirty || b.dirty {
+		t.Fatalf("Expected clean nodes after computing the world transform")
+	}
+	a.SetTransform(Mat4Translation(1, 0, 0))
+	if root.dirty || !a.dirty || !b.dirty {
+		t.Errorf("Expected only the moved node and its children dirty")
+	}
+	a.WorldTransform()
+	root.SetTransform(Mat4Translation(0, 1, 0))
+	if !a.dirty || !b.dirty {
+		t.Errorf("Expected the children of a moved node dirty")
+	}
+	if p := b.WorldPosition(); !p.ApproxEqual(vec3.New(1, 1, 0), 1e-4) {
+		t.Errorf("Expected (1, 1, 0), got %v", p)
+	}
+}
+
+func TestNodeFind(t *testing.T) {
+	root := NewNode("root")
+	level := NewNode("level")
+	door := NewNode("door")
+	handle := NewNode("handle")
+	root.AddChild(level)
+	level.AddChild(door)
+	door.AddChild(handle)
+
+	if handle.Path() != "level/door/handle" {
+		t.Errorf("Unexpected path %q", handle.Path())
+	}
+	for _, n := range []*Node{level, door, handle} {
+		if root.Find(n.Path()) != n {
+			t.Errorf("Expected to find %v", n.Path())
+		}
+	}
+	if level.Find("door/handle") != handle || root.Find("/level/door/") != door || root.Find("") != root {
+		t.Errorf("Expected relative and slash terminated paths to resolve")
+	}
+	if root.Find("level/window") != nil {
+		t.Errorf("Expected nil for a missing node")
+	}
+}
+
+func TestNodeCycle(t *testing.T) {
+	root := NewNode("root")
+	child := NewNode("child")
+	root.AddChild(child)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic adding a parent as a child")
+		}
+	}()
+	child.AddChild(root)
+}
+
+type spinner struct {
+	angle float32
+}
+
+func (s *spinner) Update(node *Node, dt float32) {
+	s.angle += dt
+	node.SetTransform(RotationMatrix(s.angle, vec3.New(0, 1, 0)))
+}
+
+func TestNodeVisitors(t *testing.T) {
+	light := &Light{Color: vec3.New(1, 1, 1), Intensity: 1}
+	s := &spinner{}
+	root := NewNode("root", light)
+	child := NewNode("child", s, &SdfComponent{Sdf: sdf.Sphere{Radius: 1}})
+	other := NewNode("other", &SdfComponent{Sdf: sdf.Sphere{Center: vec3.New(3, 0, 0), Radius: 1}})
+	root.AddChild(child)
+	root.AddChild(other)
+
+	root.Update(0.5)
+	root.Update(0.5)
+	if s.angle != 1 {
+		t.Errorf("Expected the component updated twice, got angle %v", s.angle)
+	}
+	if l, ok := FindComponent[*Light](root); !ok || l != light {
+		t.Errorf("Expected to find the light")
+	}
+	if _, ok := FindComponent[*Light](child); ok {
+		t.Errorf("Expected no light on the child")
+	}
+
+	var visited []string
+	root.Walk(func(n *Node) bool {
+		visited = append(visited, n.Name)
+		return n != child
+	})
+	if len(visited) != 3 || visited[0] != "root" {
+		t.Errorf("Unexpected visit order %v", visited)
+	}
+
+	union, ok := root.Sdf().(sdf.Union)
+	if !ok || len(union) != 2 {
+		t.Fatalf("Expected a union of both spheres, got %v", root.Sdf())
+	}
+	if NewNode("empty").Sdf() != nil {
+		t.Errorf("Expected no sdf for a node without SdfComponents")
+	}
+}
+
+func TestNodeDraw(t *testing.T) {
+	screen := Screen{backend: newSoftwareBackend(8, 8)}
+	screen.layout(&layoutContext{}, 8, 8, 8, 8)
+	if err := screen.SetScene(sdf.Sphere{Center: vec3.New(0, 0, -10), Radius: 5}); err != nil {
+		t.Fatal(err)
+	}
+	cube := &Polygon{}
+	cube.Load3D(cubeVertices())
+	root := NewNode("root")
+	node := NewNode("cube", cube)
+	root.AddChild(node)
+	root.SetTransform(Mat4Translation(0, 0, -10))
+
+	camera := NewCamera(vec3.New(0, 0, 0))
+	camera.Apply(&screen, 1)
+	screen.Clear()
+	root.Draw(&screen)
+	if c := screen.Image().RGBAAt(4, 4); c != hitColor {
+		t.Errorf("Expected the cube drawn at its world position, got %v", c)
+	}
+
+	root.SetTransform(Mat4Translation(0, 0, 10))
+	screen.Clear()
+	root.Draw(&screen)
+	if c := screen.Image().RGBAAt(4, 4); c != clearColor {
+		t.Errorf("Expected nothing drawn behind the camera, got %v", c)
+	}
+}
+
+func TestNodeDrawSdf(t *testing.T) {
+	screen := Screen{backend: newSoftwareBackend(8, 8)}
+	screen.layout(&layoutContext{}, 8, 8, 8, 8)
+	if err := screen.SetScene(sdf.Sphere{Center: vec3.New(100, 0, 0), Radius: 1}); err != nil {
+		t.Fatal(err)
+	}
+	cube := &Polygon{}
+	cube.Load3D(cubeVertices())
+	ball := &SdfComponent{Sdf: sdf.Sphere{Center: vec3.New(0, 0, -10), Radius: 5}}
+	root := NewNode("root", ball)
+	node := NewNode("cube", cube)
+	node.SetTransform(Mat4Translation(0, 0, -10))
+	root.AddChild(node)
+
+	camera := NewCamera(vec3.New(0, 0, 0))
+	camera.Apply(&screen, 1)
+	screen.Clear()
+	root.Draw(&screen)
+	if c := screen.Image().RGBAAt(4, 4); c != hitColor {
+		t.Errorf("Expected the sdf component drawn, got %v", c)
+	}
+
+	root.Components = nil
+	screen.Clear()
+	root.Draw(&screen)
+	if c := screen.Image().RGBAAt(4, 4); c != missColor {
+		t.Errorf("Expected the removed sdf component not drawn, got %v", c)
+	}
+}
+
+func TestNodeSaveProvider(t *testing.T) {
+	build := func() (*Node, *Node) {
+		root := NewNode("root")
+		level := NewNode("level")
+		door := NewNode("door")
+		root.AddChild(level)
+		level.AddChild(door)
+		return level, door
+	}
+	level, door := build()
+	door.SetTransform(Mat4Translation(1, 2, 3))
+	saves := save.New(1)
+	saves.Register(level.SaveProvider("entities"))
+	buf := &bytes.Buffer{}
+	if err := saves.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedLevel, loadedDoor := build()
+	loadSaves := save.New(1)
+	loadSaves.Register(loadedLevel.SaveProvider("entities"))
+	if err := loadSaves.Read(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if loadedDoor.Transform() != Mat4Translation(1, 2, 3) {
+		t.Errorf("Expected the door transform to load, got %v", loadedDoor.Transform())
+	}
+}
@@ -0,0 +1,374 @@
+package engine
+
+import (
+	"encoding/json"
+
+	remotevm "github.com/rolfrm/remotevm"
+	"github.com/supersdf-go/engine/quat"
+	"github.com/supersdf-go/engine/save"
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+type BodyShape int
+
+const (
+	ShapeSphere BodyShape = iota
+	ShapeBox
+)
+
+// RigidBody is a sphere or a box moved by the physics world. Bodies collide
+// with the world Sdf, not with each other.
+type RigidBody struct {
+	Shape BodyShape
+	// Radius is the size of a sphere and HalfSize the size of a box.
+	Radius   float32
+	HalfSize vec3.Vec3
+	// Mass in kilograms, bodies without mass do not move.
+	Mass float32
+
+	Position    vec3.Vec3
+	Orientation quat.Quat
+	Velocity    vec3.Vec3
+	// AngularVelocity is the rotation axis scaled by radians per second.
+	AngularVelocity vec3.Vec3
+
+	// Restitution is the part of the speed kept in a bounce, from 0 to 1.
+	Restitution float32
+	// Friction is the Coulomb coefficient, the most tangential impulse per
+	// normal impulse.
+	Friction float32
+
+	// Sleeping bodies are skipped until they are woken.
+	Sleeping bool
+	restTime float32
+
+	// Node gets the position and orientation of the body after each step.
+	Node *Node
+}
+
+func NewSphereBody(position vec3.Vec3, radius, mass float32) *RigidBody {
+	return &RigidBody{Shape: ShapeSphere, Radius: radius, Mass: mass, Position: position, Orientation: quat.Identity(), Restitution: 0.5, Friction: 0.5}
+}
+
+func NewBoxBody(position, halfSize vec3.Vec3, mass float32) *RigidBody {
+	return &RigidBody{Shape: ShapeBox, HalfSize: halfSize, Mass: mass, Position: position, Orientation: quat.Identity(), Restitution: 0.3, Friction: 0.5}
+}
+
+// Wake makes a sleeping body move again.
+func (b *RigidBody) Wake() {
+	b.Sleeping = false
+	b.restTime = 0
+}
+
+// ApplyImpulse changes the momentum at a point in world space and wakes the body.
+func (b *RigidBody) ApplyImpulse(impulse, point vec3.Vec3) {
+	if b.Mass <= 0 {
+		return
+	}
+	b.Wake()
+	b.applyImpulse(impulse, point.Subtract(b.Position))
+}
+
+// applyImpulse applies an impulse at r from the center of mass.
+func (b *RigidBody) applyImpulse(impulse, r vec3.Vec3) {
+	b.Velocity = b.Velocity.Add(impulse.MultiplyScalar(1 / b.Mass))
+	b.AngularVelocity = b.AngularVelocity.Add(b.inverseInertia(r.CrossProduct(impulse)))
+}
+
+// inverseInertia multiplies v with the inverse inertia tensor in world space.
+func (b *RigidBody) inverseInertia(v vec3.Vec3) vec3.Vec3 {
+	var inertia vec3.Vec3
+	switch b.Shape {
+	case ShapeSphere:
+		i := 0.4 * b.Mass * b.Radius * b.Radius
+		inertia = vec3.New(i, i, i)
+	case ShapeBox:
+		s := b.HalfSize.MultiplyScalar(2)
+		s = s.Mul(s)
+		inertia = vec3.New(s.Y+s.Z, s.X+s.Z, s.X+s.Y).MultiplyScalar(b.Mass / 12)
+	}
+	local := b.Orientation.Conjugate().Rotate(v)
+	return b.Orientation.Rotate(local.Div(inertia))
+}
+
+// boxPoints are the corners, edge centers and face centers of a unit box.
+var boxPoints = func() []vec3.Vec3 {
+	var points []vec3.Vec3
+	for x := -1; x <= 1; x++ {
+		for y := -1; y <= 1; y++ {
+			for z := -1; z <= 1; z++ {
+				if x != 0 || y != 0 || z != 0 {
+					points = append(points, vec3.New(float32(x), float32(y), float32(z)))
+				}
+			}
+		}
+	}
+	return points
+}()
+
+// contactMargin is the gap at which a point counts as touching, so resting
+// bodies keep their contacts between steps.
+const contactMargin = 0.01
+
+// contacts appends the surface points of the body that touch world.
+func (b *RigidBody) contacts(world sdf.Sdf, contacts []sdf.Contact) []sdf.Contact {
+	switch b.Shape {
+	case ShapeSphere:
+		// the closest surface point is towards the world.
+		d := world.Distance(b.Position)
+		if d-b.Radius < contactMargin {
+			n := sdf.Normal(world, b.Position, 0)
+			contacts = append(contacts, sdf.Contact{Point: b.Position.Subtract(n.MultiplyScalar(b.Radius)), Normal: n, Depth: b.Radius - d})
+		}
+	case ShapeBox:
+		for _, corner := range boxPoints {
+			p := b.Position.Add(b.Orientation.Rotate(corner.Mul(b.HalfSize)))
+			if d := world.Distance(p); d < contactMargin {
+				contacts = append(contacts, sdf.Contact{Point: p, Normal: sdf.Normal(world, p, 0), Depth: -d})
+			}
+		}
+	}
+	return contacts
+}
+
+// PhysicsWorld steps rigid bodies with gravity and contacts against an Sdf.
+// It is a Component, added to a node it is stepped by Node.Update with the
+// fixed time step of the game loop.
+type PhysicsWorld struct {
+	// World is the static geometry. When nil, Update uses the Sdf of the node.
+	World   sdf.Sdf
+	Gravity vec3.Vec3
+	Bodies  []*RigidBody
+	// Iterations is the number of passes over the contacts of a body.
+	Iterations int
+	// Bodies slower than SleepSpeed in m/s and rad/s for SleepTime seconds
+	// fall asleep.
+	SleepSpeed float32
+	SleepTime  float32
+	// Slop is the penetration that is not corrected, so contacts persist.
+	Slop float32
+
+	contacts []sdf.Contact
+	solvers  []contactSolver
+}
+
+func NewPhysicsWorld(world sdf.Sdf) *PhysicsWorld {
+	return &PhysicsWorld{
+		World:      world,
+		Gravity:    vec3.New(0, -9.81, 0),
+		Iterations: 8,
+		SleepSpeed: 0.05,
+		SleepTime:  0.5,
+		Slop:       0.005,
+	}
+}
+
+func (w *PhysicsWorld) Add(body *RigidBody) {
+	w.Bodies = append(w.Bodies, body)
+}
+
+// bodyState is the part of a body that changes while stepping.
+type bodyState struct {
+	Position, Velocity, AngularVelocity vec3.Vec3
+	Orientation                         quat.Quat
+	Sleeping                            bool
+}
+
+// SaveProvider stores the motion of the bodies in order. Bodies added since
+// the save keep their state.
+func (w *PhysicsWorld) SaveProvider(name string) save.Provider {
+	return save.FuncProvider{
+		Command: remotevm.Command{
+			Name:      name,
+			Arguments: []remotevm.Type{remotevm.Type_U8_Array},
+			Func: func(data []byte) error {
+				var saved []bodyState
+				if err := json.Unmarshal(data, &saved); err != nil {
+					return err
+				}
+				for i, state := range saved {
+					if i >= len(w.Bodies) {
+						break
+					}
+					b := w.Bodies[i]
+					b.Position, b.Velocity, b.AngularVelocity = state.Position, state.Velocity, state.AngularVelocity
+					b.Orientation, b.Sleeping, b.restTime = state.Orientation, state.Sleeping, 0
+					b.syncNode()
+				}
+				return nil
+			},
+		},
+		SaveFn: func(sw *save.Writer) error {
+			saved := make([]bodyState, len(w.Bodies))
+			for i, b := range w.Bodies {
+				saved[i] = bodyState{b.Position, b.Velocity, b.AngularVelocity, b.Orientation, b.Sleeping}
+			}
+			data, err := json.Marshal(saved)
+			if err != nil {
+				return err
+			}
+			return sw.Call(name, data)
+		},
+	}
+}
+
+// Update steps the world, it makes PhysicsWorld an Updater. Without SdfComponents
+// in the node there is nothing to collide with.
+func (w *PhysicsWorld) Update(node *Node, dt float32) {
+	world := w.World
+	if world == nil {
+		world = node.Sdf()
+	}
+	if world == nil {
+		world = sdf.Infinity{}
+	}
+	w.step(world, dt)
+}
+
+// Step advances the bodies by dt seconds.
+func (w *PhysicsWorld) Step(dt float32) {
+	world := w.World
+	if world == nil {
+		world = sdf.Infinity{}
+	}
+	w.step(world, dt)
+}
+
+func (w *PhysicsWorld) step(world sdf.Sdf, dt float32) {
+	for _, b := range w.Bodies {
+		if b.Sleeping || b.Mass <= 0 {
+			continue
+		}
+		w.stepBody(world, b, dt)
+		b.syncNode()
+	}
+}
+
+// contactSolver accumulates the impulses of one contact over the iterations.
+type contactSolver struct {
+	sdf.Contact
+	r        vec3.Vec3
+	tangents [2]vec3.Vec3
+	// mass is the effective mass along the normal and the tangents,
+	// including the rotation an impulse causes.
+	mass    [3]float32
+	target  float32
+	normal  float32
+	tangent [2]float32
+}
+
+func (b *RigidBody) effectiveMass(r, d vec3.Vec3) float32 {
+	return 1 / (1/b.Mass + d.DotProduct(b.inverseInertia(r.CrossProduct(d)).CrossProduct(r)))
+}
+
+// tangentBasis returns two directions perpendicular to n and each other.
+func tangentBasis(n vec3.Vec3) [2]vec3.Vec3 {
+	axis := vec3.New(1, 0, 0)
+	if n.X*n.X > 0.5 {
+		axis = vec3.New(0, 1, 0)
+	}
+	t := n.CrossProduct(axis).Normalize()
+	return [2]vec3.Vec3{t, n.CrossProduct(t)}
+}
+
+// prepareContact sets up a contact. Approaching contacts bounce with the
+// restitution, unless slower than restingSpeed. Contacts with a gap allow
+// closing it within the step.
+func (b *RigidBody) prepareContact(c sdf.Contact, restingSpeed, dt float32) contactSolver {
+	s := contactSolver{Contact: c, r: c.Point.Subtract(b.Position), tangents: tangentBasis(c.Normal)}
+	s.mass[0] = b.effectiveMass(s.r, c.Normal)
+	for i, t := range s.tangents {
+		s.mass[i+1] = b.effectiveMass(s.r, t)
+	}
+	vn := b.Velocity.Add(b.AngularVelocity.CrossProduct(s.r)).DotProduct(c.Normal)
+	switch {
+	case vn < -restingSpeed:
+		s.target = -b.Restitution * vn
+	case c.Depth < 0:
+		s.target = c.Depth / dt
+	}
+	return s
+}
+
+// solve applies the impulses that stop the body moving into the surface and
+// sliding along it. The total normal impulse only pushes, the friction is at
+// most Friction times the normal impulse.
+func (b *RigidBody) solve(s *contactSolver) {
+	vn := b.Velocity.Add(b.AngularVelocity.CrossProduct(s.r)).DotProduct(s.Normal)
+	old := s.normal
+	s.normal = max(old+(s.target-vn)*s.mass[0], 0)
+	b.applyImpulse(s.Normal.MultiplyScalar(s.normal-old), s.r)
+
+	limit := b.Friction * s.normal
+	for i, t := range s.tangents {
+		vt := b.Velocity.Add(b.AngularVelocity.CrossProduct(s.r)).DotProduct(t)
+		old := s.tangent[i]
+		s.tangent[i] = max(-limit, min(limit, old-vt*s.mass[i+1]))
+		b.applyImpulse(t.MultiplyScalar(s.tangent[i]-old), s.r)
+	}
+}
+
+// stepBody integrates semi-implicitly: the velocities are updated with gravity
+// and the contact impulses first, then the position moves with the new velocity.
+func (w *PhysicsWorld) stepBody(world sdf.Sdf, b *RigidBody, dt float32) {
+	b.Velocity = b.Velocity.Add(w.Gravity.MultiplyScalar(dt))
+	w.contacts = b.contacts(world, w.contacts[:0])
+	// slower impacts than two steps of gravity do not bounce, so bodies come to rest.
+	restingSpeed := 2 * w.Gravity.Length() * dt
+	w.solvers = w.solvers[:0]
+	for _, c := range w.contacts {
+		w.solvers = append(w.solvers, b.prepareContact(c, restingSpeed, dt))
+	}
+	for i := 0; i < w.Iterations; i++ {
+		for j := range w.solvers {
+			b.solve(&w.solvers[j])
+		}
+	}
+
+	var deepest sdf.Contact
+	for _, c := range w.contacts {
+		if c.Depth > deepest.Depth {
+			deepest = c
+		}
+	}
+	if deepest.Depth > w.Slop {
+		b.Position = b.Position.Add(deepest.Normal.MultiplyScalar(deepest.Depth - w.Slop))
+	}
+
+	b.Position = b.Position.Add(b.Velocity.MultiplyScalar(dt))
+	// dq/dt = ω q / 2
+	omega := quat.New(b.AngularVelocity.X, b.AngularVelocity.Y, b.AngularVelocity.Z, 0)
+	dq := omega.Multiply(b.Orientation)
+	h := dt / 2
+	b.Orientation = quat.New(
+		b.Orientation.X+dq.X*h,
+		b.Orientation.Y+dq.Y*h,
+		b.Orientation.Z+dq.Z*h,
+		b.Orientation.W+dq.W*h,
+	).Normalize()
+
+	if len(w.contacts) > 0 && b.Velocity.Length() < w.SleepSpeed && b.AngularVelocity.Length() < w.SleepSpeed {
+		b.restTime += dt
+		if b.restTime >= w.SleepTime {
+			b.Sleeping = true
+			b.Velocity, b.AngularVelocity = vec3.Vec3{}, vec3.Vec3{}
+		}
+	} else {
+		b.restTime = 0
+	}
+}
+
+// syncNode moves the node of the body to its pose in world space.
+func (b *RigidBody) syncNode() {
+	if b.Node == nil {
+		return
+	}
+	m := Mat4TRS(b.Position, b.Orientation, vec3.New(1, 1, 1))
+	if parent := b.Node.Parent(); parent != nil {
+		if inverse, ok := parent.WorldTransform().Inverse(); ok {
+			m = inverse.Multiply(m)
+		}
+	}
+	b.Node.SetTransform(m)
+}
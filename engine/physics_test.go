@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/supersdf-go/engine/quat"
+	"github.com/supersdf-go/engine/save"
+	sdf "github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+const physicsDt = 1.0 / 60
+
+func stepPhysics(w *PhysicsWorld, seconds float32) {
+	for i := 0; i < int(seconds/physicsDt); i++ {
+		w.Step(physicsDt)
+	}
+}
+
+func TestSphereBounces(t *testing.T) {
+	w := NewPhysicsWorld(floor)
+	ball := NewSphereBody(vec3.New(0, 5, 0), 0.5, 1)
+	ball.Restitution = 0.5
+	w.Add(ball)
+
+	landed, top := false, float32(0)
+	for i := 0; i < 180; i++ {
+		w.Step(physicsDt)
+		if ball.Velocity.Y > 0 {
+			landed = true
+		}
+		if landed {
+			top = max(top, ball.Position.Y)
+		}
+	}
+	// falling 4.5 and keeping half the speed bounces a quarter as high.
+	if !landed || top < 0.5+4.5*0.2 || top > 0.5+4.5*0.3 {
+		t.Errorf("Expected a bounce to about 1.6, got %v", top)
+	}
+	stepPhysics(w, 5)
+	if !ball.Sleeping || math.Abs(float64(ball.Position.Y-0.5)) > 0.02 {
+		t.Errorf("Expected the ball to rest on the floor, got %v %v", ball.Position, ball.Sleeping)
+	}
+}
+
+func TestBoxSettles(t *testing.T) {
+	w := NewPhysicsWorld(floor)
+	box := NewBoxBody(vec3.New(0, 3, 0), vec3.New(0.5, 0.25, 0.5), 2)
+	box.Orientation = quat.FromAxisAngle(vec3.New(1, 0, 1), 0.6)
+	w.Add(box)
+	stepPhysics(w, 8)
+	if !box.Sleeping {
+		t.Fatalf("Expected the box to fall asleep, got %v %v", box.Velocity, box.AngularVelocity)
+	}
+	up := box.Orientation.Rotate(vec3.New(0, 1, 0))
+	if math.Abs(float64(up.Y)) < 0.99 || math.Abs(float64(box.Position.Y-0.25)) > 0.02 {
+		t.Errorf("Expected the box to lie flat on its large face, got up %v at %v", up, box.Position)
+	}
+}
+
+func TestFriction(t *testing.T) {
+	w := NewPhysicsWorld(floor)
+	rough := NewBoxBody(vec3.New(0, 0.5, 0), vec3.New(0.5, 0.5, 0.5), 1)
+	rough.Velocity = vec3.New(3, 0, 0)
+	smooth := NewBoxBody(vec3.New(0, 0.5, 5), vec3.New(0.5, 0.5, 0.5), 1)
+	smooth.Velocity = vec3.New(3, 0, 0)
+	smooth.Friction = 0
+	w.Add(rough)
+	w.Add(smooth)
+	stepPhysics(w, 1)
+	// μ g slows the rough box by about 4.9 m/s², it stops within a second.
+	if rough.Velocity.Length() > 0.1 || rough.Position.X > 1.5 {
+		t.Errorf("Expected friction to stop the box, got %v at %v", rough.Velocity, rough.Position)
+	}
+	if math.Abs(float64(smooth.Velocity.X-3)) > 0.05 || math.Abs(float64(smooth.Position.X-3)) > 0.1 {
+		t.Errorf("Expected a box without friction to slide on, got %v at %v", smooth.Velocity, smooth.Position)
+	}
+}
+
+func TestPhysicsSleepAndWake(t *testing.T) {
+	w := NewPhysicsWorld(floor)
+	ball := NewSphereBody(vec3.New(0, 0.5, 0), 0.5, 1)
+	w.Add(ball)
+	stepPhysics(w, 1)
+	if !ball.Sleeping {
+		t.Fatalf("Expected a resting ball to sleep")
+	}
+	position := ball.Position
+	stepPhysics(w, 1)
+	if ball.Position != position {
+		t.Errorf("Expected a sleeping ball to stay")
+	}
+	ball.ApplyImpulse(vec3.New(0, 5, 0), ball.Position)
+	if ball.Sleeping || ball.Velocity.Y != 5 {
+		t.Errorf("Expected the impulse to wake the ball, got %v", ball.Velocity)
+	}
+	// off center impulses spin the body.
+	ball.ApplyImpulse(vec3.New(1, 0, 0), ball.Position.Add(vec3.New(0, 0.5, 0)))
+	if ball.AngularVelocity.Z >= 0 {
+		t.Errorf("Expected a push on the top to roll forward, got %v", ball.AngularVelocity)
+	}
+	w.Step(physicsDt)
+	if ball.Position.Y <= position.Y {
+		t.Errorf("Expected the ball to jump")
+	}
+}
+
+func TestPhysicsWorldComponent(t *testing.T) {
+	root := NewNode("root", &SdfComponent{Sdf: floor})
+	parent := NewNode("props")
+	parent.SetTransform(Mat4Translation(0, 1, 0))
+	prop := NewNode("prop")
+	parent.AddChild(prop)
+	root.AddChild(parent)
+
+	w := NewPhysicsWorld(nil)
+	ball := NewSphereBody(vec3.New(2, 3, 0), 0.5, 1)
+	ball.Node = prop
+	w.Add(ball)
+	root.Components = append(root.Components, w)
+	for i := 0; i < 240; i++ {
+		root.Update(physicsDt)
+	}
+	if math.Abs(float64(ball.Position.Y-0.5)) > 0.02 {
+		t.Errorf("Expected the ball to land on the node Sdf, got %v", ball.Position)
+	}
+	if !prop.WorldPosition().ApproxEqual(ball.Position, 1e-4) {
+		t.Errorf("Expected the node at the body, got %v and %v", prop.WorldPosition(), ball.Position)
+	}
+}
+
+func TestPhysicsWorldWithoutSdf(t *testing.T) {
+	root := NewNode("root")
+	w := NewPhysicsWorld(nil)
+	ball := NewSphereBody(vec3.New(0, 3, 0), 0.5, 1)
+	w.Add(ball)
+	root.Components = append(root.Components, w)
+	root.Update(1)
+	if math.Abs(float64(ball.Position.Y-(3-9.81))) > 1e-3 {
+		t.Errorf("Expected the ball to fall freely, got %v", ball.Position)
+	}
+}
+
+func TestPhysicsDeterministic(t *testing.T) {
+	run := func() *RigidBody {
+		w := NewPhysicsWorld(sdf.Union{floor, sdf.Sphere{Center: vec3.New(0.3, 0, 0), Radius: 1}})
+		box := NewBoxBody(vec3.New(0, 4, 0), vec3.New(0.3, 0.3, 0.3), 1)
+		w.Add(box)
+		stepPhysics(w, 3)
+		return box
+	}
+	a, b := run(), run()
+	if a.Position != b.Position || a.Orientation != b.Orientation {
+		t.Errorf("Expected the same result, got %v and %v", a.Position, b.Position)
+	}
+}
+
+func TestPhysicsSaveProvider(t *testing.T) {
+	w := NewPhysicsWorld(floor)
+	body := NewSphereBody(vec3.New(0, 3, 0), 0.5, 1)
+	body.Velocity = vec3.New(1, 0, 0)
+	w.Add(body)
+	stepPhysics(w, 0.5)
+	saves := save.New(1)
+	saves.Register(w.SaveProvider("bodies"))
+	buf := &bytes.Buffer{}
+	if err := saves.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewPhysicsWorld(floor)
+	node := NewNode("ball")
+	loaded.Add(NewSphereBody(vec3.New(0, 3, 0), 0.5, 1))
+	loaded.Bodies[0].Node = node
+	loadSaves := save.New(1)
+	loadSaves.Register(loaded.SaveProvider("bodies"))
+	if err := loadSaves.Read(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if b := loaded.Bodies[0]; b.Position != body.Position || b.Velocity != body.Velocity || b.Orientation != body.Orientation {
+		t.Errorf("Expected the body state to load, got %+v", b)
+	}
+	if node.WorldPosition() != body.Position {
+		t.Errorf("Expected the node moved to the body, got %v", node.WorldPosition())
+	}
+}
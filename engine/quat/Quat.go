@@ -0,0 +1,175 @@
+package quat
+
+import (
+	"math"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+// Quat is a rotation quaternion X*i + Y*j + Z*k + W. Rotations are unit
+// quaternions, q and -q are the same rotation.
+type Quat struct {
+	X float32
+	Y float32
+	Z float32
+	W float32
+}
+
+func New(x, y, z, w float32) Quat {
+	return Quat{X: x, Y: y, Z: z, W: w}
+}
+
+// Identity is the rotation that does nothing.
+func Identity() Quat {
+	return Quat{W: 1}
+}
+
+// FromAxisAngle rotates counter clockwise by angle radians around axis, seen
+// from the tip of the axis. The axis does not need to be normalized.
+func FromAxisAngle(axis vec3.Vec3, angle float32) Quat {
+	axis = axis.Normalize()
+	s, c := math.Sincos(float64(angle) / 2)
+	return Quat{axis.X * float32(s), axis.Y * float32(s), axis.Z * float32(s), float32(c)}
+}
+
+// FromEuler rotates by roll around Z, then pitch around X and then yaw around
+// Y. With the camera looking down -Z, positive yaw turns left and positive
+// pitch looks up, like Camera.
+func FromEuler(yaw, pitch, roll float32) Quat {
+	y := FromAxisAngle(vec3.New(0, 1, 0), yaw)
+	p := FromAxisAngle(vec3.New(1, 0, 0), pitch)
+	r := FromAxisAngle(vec3.New(0, 0, 1), roll)
+	return y.Multiply(p).Multiply(r)
+}
+
+// Multiply combines two rotations, q.Multiply(b) rotates by b first and then by q.
+func (q Quat) Multiply(b Quat) Quat {
+	return Quat{
+		q.W*b.X + q.X*b.W + q.Y*b.Z - q.Z*b.Y,
+		q.W*b.Y - q.X*b.Z + q.Y*b.W + q.Z*b.X,
+		q.W*b.Z + q.X*b.Y - q.Y*b.X + q.Z*b.W,
+		q.W*b.W - q.X*b.X - q.Y*b.Y - q.Z*b.Z,
+	}
+}
+
+// Rotate rotates a vector by a unit quaternion.
+func (q Quat) Rotate(v vec3.Vec3) vec3.Vec3 {
+	// v + 2w(u x v) + 2u x (u x v), with u the vector part.
+	u := vec3.New(q.X, q.Y, q.Z)
+	t := u.CrossProduct(v).MultiplyScalar(2)
+	return v.Add(t.MultiplyScalar(q.W)).Add(u.CrossProduct(t))
+}
+
+// Conjugate is the inverse rotation of a unit quaternion.
+func (q Quat) Conjugate() Quat {
+	return Quat{-q.X, -q.Y, -q.Z, q.W}
+}
+
+// Inverse works for quaternions of any length, a zero quaternion gives zero.
+func (q Quat) Inverse() Quat {
+	d := q.Dot(q)
+	if d == 0 {
+		return Quat{}
+	}
+	c := q.Conjugate()
+	return Quat{c.X / d, c.Y / d, c.Z / d, c.W / d}
+}
+
+func (q Quat) Dot(b Quat) float32 {
+	return q.X*b.X + q.Y*b.Y + q.Z*b.Z + q.W*b.W
+}
+
+func (q Quat) Length() float32 {
+	return float32(math.Sqrt(float64(q.Dot(q))))
+}
+
+// Normalize returns the unit quaternion, or the identity for a zero quaternion.
+func (q Quat) Normalize() Quat {
+	l := q.Length()
+	if l == 0 {
+		return Identity()
+	}
+	return Quat{q.X / l, q.Y / l, q.Z / l, q.W / l}
+}
+
+// AxisAngle returns the axis and the angle in [0, 2π] of a unit quaternion.
+// The identity returns the X axis.
+func (q Quat) AxisAngle() (vec3.Vec3, float32) {
+	w := max(-1, min(1, q.W))
+	angle := 2 * float32(math.Acos(float64(w)))
+	s := float32(math.Sqrt(float64(1 - w*w)))
+	if s < 1e-6 {
+		return vec3.New(1, 0, 0), angle
+	}
+	return vec3.New(q.X/s, q.Y/s, q.Z/s), angle
+}
+
+// Nlerp interpolates linearly and normalizes. It is faster than Slerp but
+// does not rotate at a constant speed.
+func Nlerp(a, b Quat, t float32) Quat {
+	if a.Dot(b) < 0 {
+		b = Quat{-b.X, -b.Y, -b.Z, -b.W}
+	}
+	return Quat{
+		a.X + (b.X-a.X)*t,
+		a.Y + (b.Y-a.Y)*t,
+		a.Z + (b.Z-a.Z)*t,
+		a.W + (b.W-a.W)*t,
+	}.Normalize()
+}
+
+// Slerp interpolates along the shortest arc at a constant speed.
+func Slerp(a, b Quat, t float32) Quat {
+	d := a.Dot(b)
+	if d < 0 {
+		b = Quat{-b.X, -b.Y, -b.Z, -b.W}
+		d = -d
+	}
+	// nearly the same rotation, sin(theta) is too small to divide by.
+	if d > 0.9995 {
+		return Nlerp(a, b, t)
+	}
+	theta := math.Acos(float64(d))
+	sinTheta := math.Sin(theta)
+	wa := float32(math.Sin((1-float64(t))*theta) / sinTheta)
+	wb := float32(math.Sin(float64(t)*theta) / sinTheta)
+	return Quat{
+		a.X*wa + b.X*wb,
+		a.Y*wa + b.Y*wb,
+		a.Z*wa + b.Z*wb,
+		a.W*wa + b.W*wb,
+	}
+}
+
+// Matrix returns the rotation as a column-major 4x4 matrix, the layout of engine.Mat4.
+func (q Quat) Matrix() [16]float32 {
+	x, y, z, w := q.X, q.Y, q.Z, q.W
+	return [16]float32{
+		1 - 2*(y*y+z*z), 2 * (x*y + z*w), 2 * (x*z - y*w), 0,
+		2 * (x*y - z*w), 1 - 2*(x*x+z*z), 2 * (y*z + x*w), 0,
+		2 * (x*z + y*w), 2 * (y*z - x*w), 1 - 2*(x*x+y*y), 0,
+		0, 0, 0, 1,
+	}
+}
+
+// FromMatrix returns the rotation of a column-major matrix without scale.
+func FromMatrix(m [16]float32) Quat {
+	// element (r, c) is at r+c*4.
+	m00, m11, m22 := m[0], m[5], m[10]
+	var q Quat
+	switch trace := m00 + m11 + m22; {
+	case trace > 0:
+		s := float32(math.Sqrt(float64(trace+1))) * 2
+		q = Quat{(m[6] - m[9]) / s, (m[8] - m[2]) / s, (m[1] - m[4]) / s, s / 4}
+	case m00 > m11 && m00 > m22:
+		s := float32(math.Sqrt(float64(1+m00-m11-m22))) * 2
+		q = Quat{s / 4, (m[4] + m[1]) / s, (m[8] + m[2]) / s, (m[6] - m[9]) / s}
+	case m11 > m22:
+		s := float32(math.Sqrt(float64(1+m11-m00-m22))) * 2
+		q = Quat{(m[4] + m[1]) / s, s / 4, (m[9] + m[6]) / s, (m[8] - m[2]) / s}
+	default:
+		s := float32(math.Sqrt(float64(1+m22-m00-m11))) * 2
+		q = Quat{(m[8] + m[2]) / s, (m[9] + m[6]) / s, s / 4, (m[1] - m[4]) / s}
+	}
+	return q.Normalize()
+}
@@ -0,0 +1,135 @@
+package quat
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+// sameRotation compares rotations, q and -q rotate the same.
+func sameRotation(a, b Quat) bool {
+	return math.Abs(float64(a.Dot(b))) > 1-1e-5
+}
+
+func randomQuat(r *rand.Rand) Quat {
+	return FromAxisAngle(vec3.New(r.Float32()-0.5, r.Float32()-0.5, r.Float32()-0.5), r.Float32()*8-4)
+}
+
+// applyMatrix multiplies a column-major matrix with a vector.
+func applyMatrix(m [16]float32, v vec3.Vec3) vec3.Vec3 {
+	return vec3.New(
+		m[0]*v.X+m[4]*v.Y+m[8]*v.Z,
+		m[1]*v.X+m[5]*v.Y+m[9]*v.Z,
+		m[2]*v.X+m[6]*v.Y+m[10]*v.Z,
+	)
+}
+
+func TestAxisAngle(t *testing.T) {
+	q := FromAxisAngle(vec3.New(0, 0, 2), math.Pi/2)
+	if v := q.Rotate(vec3.New(1, 0, 0)); !v.ApproxEqual(vec3.New(0, 1, 0), 1e-4) {
+		t.Errorf("Expected a counter clockwise turn around Z, got %v", v)
+	}
+	if math.Abs(float64(q.Length()-1)) > 1e-6 {
+		t.Errorf("Expected the axis to be normalized, got length %v", q.Length())
+	}
+	axis, angle := q.AxisAngle()
+	if !axis.ApproxEqual(vec3.New(0, 0, 1), 1e-4) || math.Abs(float64(angle-math.Pi/2)) > 1e-5 {
+		t.Errorf("Unexpected axis angle %v %v", axis, angle)
+	}
+	if v := Identity().Rotate(vec3.New(1, 2, 3)); v != vec3.New(1, 2, 3) {
+		t.Errorf("Expected the identity to keep vectors, got %v", v)
+	}
+}
+
+func TestEuler(t *testing.T) {
+	forward := vec3.New(0, 0, -1)
+	if v := FromEuler(math.Pi/2, 0, 0).Rotate(forward); !v.ApproxEqual(vec3.New(-1, 0, 0), 1e-4) {
+		t.Errorf("Expected positive yaw to turn left, got %v", v)
+	}
+	if v := FromEuler(0, math.Pi/4, 0).Rotate(forward); v.Y <= 0 {
+		t.Errorf("Expected positive pitch to look up, got %v", v)
+	}
+	if v := FromEuler(0, 0, math.Pi/2).Rotate(vec3.New(1, 0, 0)); !v.ApproxEqual(vec3.New(0, 1, 0), 1e-4) {
+		t.Errorf("Expected roll around Z, got %v", v)
+	}
+	// pitch is applied before yaw, so the camera does not roll.
+	if v := FromEuler(1, 0.5, 0).Rotate(vec3.New(1, 0, 0)); math.Abs(float64(v.Y)) > 1e-6 {
+		t.Errorf("Expected the right vector to stay horizontal, got %v", v)
+	}
+}
+
+func TestMultiplyAndInverse(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		a, b := randomQuat(r), randomQuat(r)
+		v := vec3.New(r.Float32()*4-2, r.Float32()*4-2, r.Float32()*4-2)
+		if p, q := a.Multiply(b).Rotate(v), a.Rotate(b.Rotate(v)); !p.ApproxEqual(q, 1e-4) {
+			t.Fatalf("Expected a*b to rotate by b then a, got %v and %v", p, q)
+		}
+		if p := a.Conjugate().Rotate(a.Rotate(v)); !p.ApproxEqual(v, 1e-4) {
+			t.Fatalf("Expected the conjugate to undo the rotation, got %v", p)
+		}
+		scaled := Quat{a.X * 3, a.Y * 3, a.Z * 3, a.W * 3}
+		if !sameRotation(scaled.Multiply(scaled.Inverse()), Identity()) {
+			t.Fatalf("Expected q * q^-1 to be the identity")
+		}
+		if !sameRotation(scaled.Normalize(), a) {
+			t.Fatalf("Expected Normalize to restore the unit quaternion")
+		}
+		if p := a.Rotate(v); math.Abs(float64(p.Length()-v.Length())) > 1e-4 {
+			t.Fatalf("Expected rotations to keep lengths")
+		}
+	}
+	if (Quat{}).Normalize() != Identity() {
+		t.Errorf("Expected a zero quaternion to normalize to the identity")
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		q := randomQuat(r)
+		m := q.Matrix()
+		v := vec3.New(r.Float32()*4-2, r.Float32()*4-2, r.Float32()*4-2)
+		if p, e := applyMatrix(m, v), q.Rotate(v); !p.ApproxEqual(e, 1e-4) {
+			t.Fatalf("Expected the matrix to rotate like the quaternion, got %v and %v", p, e)
+		}
+		if back := FromMatrix(m); !sameRotation(back, q) {
+			t.Fatalf("Expected the matrix to convert back to %v, got %v", q, back)
+		}
+	}
+	// every branch of FromMatrix, including half turns where the trace is negative.
+	for _, axis := range []vec3.Vec3{vec3.New(1, 0, 0), vec3.New(0, 1, 0), vec3.New(0, 0, 1)} {
+		q := FromAxisAngle(axis, math.Pi)
+		if back := FromMatrix(q.Matrix()); !sameRotation(back, q) {
+			t.Errorf("Expected a half turn around %v, got %v", axis, back)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	a := Identity()
+	b := FromAxisAngle(vec3.New(0, 1, 0), 2)
+	for _, tc := range []float32{0, 0.25, 0.5, 1} {
+		s := Slerp(a, b, tc)
+		if expected := FromAxisAngle(vec3.New(0, 1, 0), 2*tc); !sameRotation(s, expected) {
+			t.Errorf("Expected slerp at %v to turn %v, got %v", tc, 2*tc, s)
+		}
+		if n := Nlerp(a, b, tc); math.Abs(float64(n.Length()-1)) > 1e-5 {
+			t.Errorf("Expected nlerp to normalize, got %v", n.Length())
+		}
+	}
+	if !sameRotation(Nlerp(a, b, 0.5), Slerp(a, b, 0.5)) {
+		t.Errorf("Expected nlerp and slerp to meet halfway")
+	}
+	// -b is the same rotation, interpolation takes the short way.
+	negB := Quat{-b.X, -b.Y, -b.Z, -b.W}
+	if !sameRotation(Slerp(a, negB, 0.5), FromAxisAngle(vec3.New(0, 1, 0), 1)) {
+		t.Errorf("Expected slerp along the shortest arc")
+	}
+	if !sameRotation(Slerp(b, b, 0.3), b) {
+		t.Errorf("Expected slerp between equal rotations to stay")
+	}
+}
@@ -0,0 +1,73 @@
+package save
+
+import (
+	remotevm "github.com/rolfrm/remotevm"
+	sdf "github.com/supersdf-go/engine/sdf"
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+// Vec3Provider stores a vector, for example the camera location.
+type Vec3Provider struct {
+	Name  string
+	Value *vec3.Vec3
+}
+
+func (p Vec3Provider) Commands() []remotevm.Command {
+	return []remotevm.Command{{
+		Name:      p.Name,
+		Arguments: []remotevm.Type{remotevm.Type_F64, remotevm.Type_F64, remotevm.Type_F64},
+		Func: func(x, y, z float64) {
+			*p.Value = vec3.New(float32(x), float32(y), float32(z))
+		},
+	}}
+}
+
+func (p Vec3Provider) Save(w *Writer) error {
+	return w.Call(p.Name, p.Value.X, p.Value.Y, p.Value.Z)
+}
+
+// SceneProvider stores an Sdf tree in the binary sdf format.
+type SceneProvider struct {
+	Name  string
+	Scene *sdf.Sdf
+}
+
+func (p SceneProvider) Commands() []remotevm.Command {
+	return []remotevm.Command{{
+		Name:      p.Name,
+		Arguments: []remotevm.Type{remotevm.Type_U8_Array},
+		Func: func(data []byte) error {
+			scene, err := sdf.UnmarshalBinary(data)
+			if err != nil {
+				return err
+			}
+			*p.Scene = scene
+			return nil
+		},
+	}}
+}
+
+func (p SceneProvider) Save(w *Writer) error {
+	if *p.Scene == nil {
+		return nil
+	}
+	data, err := sdf.MarshalBinary(*p.Scene)
+	if err != nil {
+		return err
+	}
+	return w.Call(p.Name, data)
+}
+
+// FuncProvider adapts a command and a save function to a Provider.
+type FuncProvider struct {
+	Command remotevm.Command
+	SaveFn  func(w *Writer) error
+}
+
+func (p FuncProvider) Commands() []remotevm.Command {
+	return []remotevm.Command{p.Command}
+}
+
+func (p FuncProvider) Save(w *Writer) error {
+	return p.SaveFn(w)
+}
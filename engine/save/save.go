@@ -0,0 +1,282 @@
+// Versioned game-state saves.
+//
+// A save file starts with a magic number and the format version, followed by
+// a remotevm command stream. Each registered Provider contributes commands
+// that restore its state, and writes calls to those commands when saving.
+// Command ids are assigned in registration order, so new providers should be
+// registered last and any other change to the command table needs a new
+// version together with a Migration that can read the old files.
+
+package save
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	remotevm "github.com/rolfrm/remotevm"
+)
+
+var magic = []byte("SDFSAVE\x00")
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	anyType   = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// maxCommands is the number of command ids that fit in a single byte sleb
+// encoding, 0 to 63, less the one Read adds to check the end of the stream.
+const maxCommands = 63
+
+// Provider is a piece of state that is stored in save files.
+type Provider interface {
+	// Commands returns the commands that restore the state when a save is loaded.
+	Commands() []remotevm.Command
+	// Save writes calls to the provider's commands that restore the current state.
+	Save(w *Writer) error
+}
+
+// Migration reads save files written by an older version. Its commands
+// replace the command table when loading files of that version. Version 0
+// is used for files without a header.
+type Migration struct {
+	Version  int
+	Commands []remotevm.Command
+}
+
+// System collects the providers and migrations of a game.
+type System struct {
+	Version    int
+	providers  []Provider
+	migrations map[int]Migration
+}
+
+func New(version int) *System {
+	return &System{Version: version, migrations: map[int]Migration{}}
+}
+
+// Register adds a provider. Its commands are appended to the command table.
+func (s *System) Register(p Provider) {
+	s.providers = append(s.providers, p)
+}
+
+// AddMigration registers the command table for reading files of an older version.
+func (s *System) AddMigration(m Migration) {
+	s.migrations[m.Version] = m
+}
+
+func (s *System) commands() []remotevm.Command {
+	var commands []remotevm.Command
+	for _, p := range s.providers {
+		commands = append(commands, p.Commands()...)
+	}
+	return commands
+}
+
+// Writer writes calls into a save stream.
+type Writer struct {
+	stream  remotevm.CodeStream
+	indexes map[string]int
+}
+
+// Call writes a call to the named command. Arguments are passed to the
+// command function in the same order. float32 and int values are widened to
+// the float64 and int64 types used by remotevm.
+func (w *Writer) Call(name string, args ...interface{}) error {
+	index, ok := w.indexes[name]
+	if !ok {
+		return fmt.Errorf("save: no command named %q", name)
+	}
+	// remotevm pops the arguments, so they are pushed in reverse.
+	for i := len(args) - 1; i >= 0; i-- {
+		switch v := args[i].(type) {
+		case float32:
+			w.stream.Write(remotevm.Op_Ld, float64(v))
+		case int:
+			w.stream.Write(remotevm.Op_Ld, int64(v))
+		case float64, int64, string, []byte:
+			w.stream.Write(remotevm.Op_Ld, v)
+		default:
+			return fmt.Errorf("save: unsupported argument type %T for %q", v, name)
+		}
+	}
+	w.stream.Write(remotevm.Op_Call, byte(index))
+	return nil
+}
+
+// Write encodes the state of all providers.
+func (s *System) Write(out io.Writer) error {
+	commands := s.commands()
+	if len(commands) > maxCommands {
+		return fmt.Errorf("save: too many commands (%v, max %v)", len(commands), maxCommands)
+	}
+	header := make([]byte, len(magic), len(magic)+binary.MaxVarintLen64)
+	copy(header, magic)
+	header = binary.AppendUvarint(header, uint64(s.Version))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+
+	w := &Writer{stream: remotevm.CodeStream{Stream: out}, indexes: map[string]int{}}
+	for i, c := range commands {
+		w.indexes[c.Name] = i
+	}
+	for _, p := range s.providers {
+		if err := p.Save(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save atomically writes the state to path by writing a temporary file and renaming it.
+func (s *System) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := s.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Read restores the state from a save stream. Files of older versions are
+// read with the command table of their migration.
+func (s *System) Read(data []byte) error {
+	version := 0
+	if bytes.HasPrefix(data, magic) {
+		v, n := binary.Uvarint(data[len(magic):])
+		if n <= 0 {
+			return errors.New("save: invalid header")
+		}
+		version = int(v)
+		data = data[len(magic)+n:]
+	}
+
+	var commands []remotevm.Command
+	switch m, ok := s.migrations[version]; {
+	case version == s.Version:
+		commands = s.commands()
+	case ok:
+		commands = m.Commands
+	case version > s.Version:
+		return fmt.Errorf("save: version %v is newer than %v", version, s.Version)
+	default:
+		return fmt.Errorf("save: no migration from version %v", version)
+	}
+	if len(commands) > maxCommands {
+		return fmt.Errorf("save: too many commands (%v, max %v)", len(commands), maxCommands)
+	}
+
+	var cmdErr error
+	done := false
+	wrapped := make([]remotevm.Command, len(commands), len(commands)+1)
+	for i, c := range commands {
+		wrapped[i] = c
+		wrapped[i].Func = checkedCommand(c, &cmdErr)
+	}
+	// EvalStream stops silently on some errors, so the stream is wrapped in a
+	// marker value and a final call checking that only the marker is left on
+	// the stack, which also catches streams cut in the middle of a call.
+	const marker = 42
+	wrapped = append(wrapped, remotevm.Command{
+		Name:      "end",
+		Arguments: []remotevm.Type{remotevm.Type_I64},
+		Func:      func(v interface{}) { done = v == int64(marker) },
+	})
+	data = append(append([]byte{byte(remotevm.Op_Ld_i64), marker}, data...),
+		byte(remotevm.Op_Call), byte(len(wrapped)-1))
+
+	// remotevm reads arrays and strings with a single Read, buffering the
+	// whole stream up front keeps large values from being split.
+	reader := bufio.NewReaderSize(bytes.NewReader(data), len(data)+4096)
+	result := &bytes.Buffer{}
+	remotevm.EvalStream(wrapped, reader, result)
+	if cmdErr != nil {
+		return cmdErr
+	}
+	if result.Len() > 0 {
+		return fmt.Errorf("save: corrupt stream: %v", evalError(result.Bytes()))
+	}
+	if !done {
+		return errors.New("save: corrupt stream")
+	}
+	return nil
+}
+
+// checkedCommand wraps a command function so that argument type mismatches
+// and returned errors are recorded instead of aborting the stream.
+func checkedCommand(c remotevm.Command, firstErr *error) interface{} {
+	fn := reflect.ValueOf(c.Func)
+	t := fn.Type()
+	if t.IsVariadic() {
+		return c.Func
+	}
+	fail := func(err error) {
+		if *firstErr == nil {
+			*firstErr = fmt.Errorf("save: %v: %w", c.Name, err)
+		}
+	}
+	in := make([]reflect.Type, t.NumIn())
+	for i := range in {
+		in[i] = anyType
+	}
+	wrapper := reflect.MakeFunc(reflect.FuncOf(in, nil, false), func(args []reflect.Value) []reflect.Value {
+		for i, arg := range args {
+			args[i] = arg.Elem()
+			if !args[i].IsValid() || !args[i].Type().AssignableTo(t.In(i)) {
+				fail(fmt.Errorf("argument %v: expected %v, got %T", i, t.In(i), arg.Interface()))
+				return nil
+			}
+		}
+		out := fn.Call(args)
+		if len(out) == 1 && t.Out(0) == errorType {
+			if err, _ := out[0].Interface().(error); err != nil {
+				fail(err)
+			}
+		}
+		return nil
+	})
+	return wrapper.Interface()
+}
+
+// evalError extracts the message of an error written by remotevm.EvalStream.
+func evalError(out []byte) string {
+	if len(out) < 3 || remotevm.Type(out[0]) != remotevm.Type_Error || remotevm.Type(out[1]) != remotevm.Type_String {
+		return fmt.Sprintf("%q", out)
+	}
+	i := 2
+	for i < len(out) && out[i]&0x80 != 0 {
+		i++
+	}
+	return string(out[i+1:])
+}
+
+// Load restores the state from the file at path. A missing file is not an
+// error, the state is left untouched on the first run.
+func (s *System) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.Read(data)
+}
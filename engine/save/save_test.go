@@ -0,0 +1,156 @@
+package save
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	remotevm "github.com/rolfrm/remotevm"
+	"github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+type testState struct {
+	location vec3.Vec3
+	scene    sdf.Sdf
+}
+
+func newTestSystem(state *testState) *System {
+	s := New(2)
+	s.Register(Vec3Provider{Name: "camera-location", Value: &state.location})
+	s.Register(SceneProvider{Name: "scene", Scene: &state.scene})
+	return s
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.bin")
+	large := sdf.BakeBrickMap(sdf.Sphere{Center: vec3.New(0, 0, 0), Radius: 1}, vec3.New(-2, -2, -2), vec3.New(2, 2, 2), 0.1, 0.3)
+	state := testState{
+		location: vec3.New(1, 2, 3),
+		scene:    sdf.Union{sdf.Sphere{Center: vec3.New(0, 1, 0), Radius: 2}, large},
+	}
+	if err := newTestSystem(&state).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := testState{}
+	if err := newTestSystem(&loaded).Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.location != state.location {
+		t.Errorf("Expected location %v, got %v", state.location, loaded.location)
+	}
+	if loaded.scene == nil || !sdf.CompareSdfs(loaded.scene, state.scene) {
+		t.Errorf("Scene was not restored")
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the save file to remain, got %v entries", len(entries))
+	}
+}
+
+func TestLoadFirstRun(t *testing.T) {
+	state := testState{location: vec3.New(4, 5, 6)}
+	if err := newTestSystem(&state).Load(filepath.Join(t.TempDir(), "missing.bin")); err != nil {
+		t.Errorf("Expected missing save file to be ignored, got %v", err)
+	}
+	if state.location != vec3.New(4, 5, 6) {
+		t.Errorf("Expected state to be untouched, got %v", state.location)
+	}
+}
+
+func TestLoadMigration(t *testing.T) {
+	// files written before the save system have no header and a single load-location command,
+	// which receives the coordinates in reverse.
+	legacy := &bytes.Buffer{}
+	codeStr := remotevm.CodeStream{Stream: legacy}
+	codeStr.Write(remotevm.Op_Ld, 1.0, remotevm.Op_Ld, 2.0, remotevm.Op_Ld, 3.0, remotevm.Op_Call, byte(0))
+
+	state := testState{}
+	s := newTestSystem(&state)
+	if err := s.Read(legacy.Bytes()); err == nil {
+		t.Error("Expected an error without a migration")
+	}
+	s.AddMigration(Migration{Version: 0, Commands: []remotevm.Command{{
+		Name:      "load-location",
+		Arguments: []remotevm.Type{remotevm.Type_F64, remotevm.Type_F64, remotevm.Type_F64},
+		Func: func(z, y, x float64) {
+			state.location = vec3.New(float32(x), float32(y), float32(z))
+		},
+	}}})
+	if err := s.Read(legacy.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if state.location != vec3.New(1, 2, 3) {
+		t.Errorf("Unexpected migrated location %v", state.location)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	state := testState{location: vec3.New(1, 2, 3), scene: sdf.Sphere{Radius: 1}}
+	buf := &bytes.Buffer{}
+	if err := newTestSystem(&state).Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if err := New(1).Read(data); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Expected a version error, got %v", err)
+	}
+	// a stream cut between two calls is still a valid save.
+	first := &bytes.Buffer{}
+	firstOnly := New(2)
+	firstOnly.Register(Vec3Provider{Name: "camera-location", Value: &state.location})
+	firstOnly.Write(first)
+	for i := len(magic) + 2; i < len(data); i++ {
+		if i == first.Len() {
+			continue
+		}
+		if err := newTestSystem(&testState{}).Read(data[:i]); err == nil {
+			t.Fatalf("Expected an error for a stream truncated to %v bytes", i)
+		}
+	}
+
+	swapped := New(2)
+	swapped.Register(SceneProvider{Name: "scene", Scene: &state.scene})
+	swapped.Register(Vec3Provider{Name: "camera-location", Value: &state.location})
+	if err := swapped.Read(data); err == nil {
+		t.Error("Expected an error for mismatched command types")
+	}
+}
+
+func TestMaxCommands(t *testing.T) {
+	values := make([]vec3.Vec3, maxCommands+1)
+	s := New(1)
+	for i := 0; i < maxCommands; i++ {
+		values[i] = vec3.New(float32(i), 0, 0)
+		s.Register(Vec3Provider{Name: fmt.Sprintf("value-%v", i), Value: &values[i]})
+	}
+	buf := &bytes.Buffer{}
+	if err := s.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	values = make([]vec3.Vec3, maxCommands+1)
+	loaded := New(1)
+	for i := 0; i < maxCommands; i++ {
+		loaded.Register(Vec3Provider{Name: fmt.Sprintf("value-%v", i), Value: &values[i]})
+	}
+	if err := loaded.Read(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if values[maxCommands-1].X != maxCommands-1 {
+		t.Errorf("Expected the last command to load, got %v", values[maxCommands-1])
+	}
+
+	loaded.Register(Vec3Provider{Name: "one-too-many", Value: &values[maxCommands]})
+	if err := loaded.Write(&bytes.Buffer{}); err == nil {
+		t.Error("Expected an error writing too many commands")
+	}
+	if err := loaded.Read(buf.Bytes()); err == nil {
+		t.Error("Expected an error reading with too many commands")
+	}
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+	sdf "github.com/supersdf-go/engine/sdf"
+)
+
+// SceneSource is implemented by contexts that load their SDF scene from a file.
+// RunApp watches the file and swaps the shader when it changes.
+type SceneSource interface {
+	SceneFile() string
+}
+
+// LoadSceneFile reads a scene from disk. Files ending in .json and .bin are
+// read with the JSON and binary formats, everything else with the text format.
+func LoadSceneFile(path string) (sdf.Sdf, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scene sdf.Sdf
+	switch filepath.Ext(path) {
+	case ".json":
+		scene, err = sdf.UnmarshalJSON(data)
+	case ".bin":
+		scene, err = sdf.UnmarshalBinary(data)
+	default:
+		scene, err = sdf.Parse(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return scene, nil
+}
+
+// SceneReloader polls a scene file and reloads it when its modification time or size changes.
+type SceneReloader struct {
+	Path         string
+	PollInterval time.Duration
+	// Scene is the last successfully loaded scene.
+	Scene sdf.Sdf
+	// Err is the error of the last failed reload, nil once a reload succeeds.
+	Err error
+	// Title is the window title, replaced by reload errors until a reload succeeds.
+	Title string
+
+	lastCheck time.Time
+	modTime   time.Time
+	size      int64
+	reported  string
+}
+
+func NewSceneReloader(path string) *SceneReloader {
+	return &SceneReloader{Path: path, PollInterval: 250 * time.Millisecond}
+}
+
+// Poll reloads the scene if the file changed since the last poll. It returns
+// true when a new scene was loaded. On errors the previous scene is kept.
+func (r *SceneReloader) Poll() (bool, error) {
+	now := time.Now()
+	if now.Sub(r.lastCheck) < r.PollInterval {
+		return false, nil
+	}
+	r.lastCheck = now
+
+	info, err := os.Stat(r.Path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false, nil
+	}
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+
+	scene, err := LoadSceneFile(r.Path)
+	if err != nil {
+		r.Err = err
+		return false, err
+	}
+	r.Scene = scene
+	r.Err = nil
+	return true, nil
+}
+
+// SetScene replaces the SDF scene, the GL backend swaps in a newly compiled
+// shader program. On errors the old scene and program are kept.
+func (s *Screen) SetScene(scene sdf.Sdf) error {
+	composed := s.composeScene(scene)
+	brickMap, err := findBrickMap(composed)
+	if err != nil {
+		return err
+	}
+	if err := s.backend.SetScene(composed); err != nil {
+		return err
+	}
+	s.scene = scene
+	s.brickMap, s.brickCamera = brickMap, Mat4{}
+	return nil
+}
+
+// reloadScene polls the scene file and replaces the SDF shader program when
+// a new scene compiles.
+func (s *Screen) reloadScene(r *SceneReloader, window *glfw.Window) {
+	changed, err := r.Poll()
+	if err != nil {
+		reportSceneError(r, window, err)
+		return
+	}
+	if !changed {
+		return
+	}
+	if err := s.SetScene(r.Scene); err != nil {
+		reportSceneError(r, window, err)
+		return
+	}
+	if r.reported != "" {
+		r.reported = ""
+		window.SetTitle(r.Title)
+	}
+}
+
+// reportSceneError logs the error and shows it in the window title, once per distinct error.
+func reportSceneError(r *SceneReloader, window *glfw.Window, err error) {
+	r.Err = err
+	if err.Error() == r.reported {
+		return
+	}
+	r.reported = err.Error()
+	fmt.Fprintf(os.Stderr, "scene reload failed: %v\n", err)
+	window.SetTitle(fmt.Sprintf("Scene error: %v", err))
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/supersdf-go/engine/sdf"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestSceneReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scene.sdf")
+	r := NewSceneReloader(path)
+	r.PollInterval = 0
+
+	if _, err := r.Poll(); err == nil {
+		t.Error("Expected an error for a missing scene file")
+	}
+
+	write := func(text string, mod time.Time) {
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t0 := time.Now()
+	write("(sphere 0 0 0 1)", t0)
+	if changed, err := r.Poll(); !changed || err != nil {
+		t.Fatalf("Expected the scene to load: %v", err)
+	}
+	if !sdf.CompareSdfs(r.Scene, sdf.Sphere{Center: vec3.New(0, 0, 0), Radius: 1}) {
+		t.Errorf("Unexpected scene: %v", r.Scene)
+	}
+	if changed, _ := r.Poll(); changed {
+		t.Error("Did not expect a reload of an unchanged file")
+	}
+
+	write("(sphere 0 0 0", t0.Add(time.Second))
+	if changed, err := r.Poll(); changed || err == nil {
+		t.Error("Expected a parse error")
+	} else if !strings.HasPrefix(err.Error(), path+": 1:") {
+		t.Errorf("Expected the error to start with the path and position, got %v", err)
+	}
+	if r.Err == nil || !sdf.CompareSdfs(r.Scene, sdf.Sphere{Center: vec3.New(0, 0, 0), Radius: 1}) {
+		t.Error("Expected the old scene to be kept")
+	}
+
+	write("(sphere 1 0 0 2)", t0.Add(2*time.Second))
+	if changed, err := r.Poll(); !changed || err != nil {
+		t.Fatalf("Expected the scene to reload: %v", err)
+	}
+	if r.Err != nil || !sdf.CompareSdfs(r.Scene, sdf.Sphere{Center: vec3.New(1, 0, 0), Radius: 2}) {
+		t.Errorf("Unexpected scene: %v", r.Scene)
+	}
+}
@@ -0,0 +1,142 @@
+package sdf
+
+import (
+	"math"
+
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+// Bounds returns an axis aligned box containing the surface of s. ok is false
+// when the surface is unbounded, like an infinite Repeat, or empty, and for
+// node types it does not know.
+func Bounds(s Sdf) (min, max vec3.Vec3, ok bool) {
+	switch obj := s.(type) {
+	case Sphere:
+		r := vec3.New(obj.Radius, obj.Radius, obj.Radius)
+		return obj.Center.Subtract(r), obj.Center.Add(r), true
+	case Cube:
+		return obj.Center.Subtract(obj.HalfSize), obj.Center.Add(obj.HalfSize), true
+	case Color:
+		return Bounds(obj.Sub)
+	case *BrickMap:
+		size := vec3.New(float32(obj.Dims[0]), float32(obj.Dims[1]), float32(obj.Dims[2])).MultiplyScalar(obj.VoxelSize * BrickSize)
+		return obj.Min, obj.Min.Add(size), true
+	case Union:
+		for _, sub := range obj {
+			if isInfinity(sub) {
+				continue
+			}
+			subMin, subMax, subOk := Bounds(sub)
+			if !subOk {
+				return vec3.Vec3{}, vec3.Vec3{}, false
+			}
+			if !ok {
+				min, max, ok = subMin, subMax, true
+				continue
+			}
+			min, max = min.Min(subMin), max.Max(subMax)
+		}
+		return min, max, ok
+	case Repeat:
+		if min, max, ok = Bounds(obj.Sub); !ok {
+			return min, max, false
+		}
+		ok = repeatBounds(&min.X, &max.X, obj.Period.X, obj.Limit.X) &&
+			repeatBounds(&min.Y, &max.Y, obj.Period.Y, obj.Limit.Y) &&
+			repeatBounds(&min.Z, &max.Z, obj.Period.Z, obj.Limit.Z)
+		return min, max, ok
+	case Mirror:
+		if min, max, ok = Bounds(obj.Sub); !ok {
+			return min, max, false
+		}
+		mirrorBounds(&min.X, &max.X, obj.Center.X, obj.X)
+		mirrorBounds(&min.Y, &max.Y, obj.Center.Y, obj.Y)
+		mirrorBounds(&min.Z, &max.Z, obj.Center.Z, obj.Z)
+		return min, max, true
+	case PolarRepeat:
+		if min, max, ok = Bounds(obj.Sub); !ok {
+			return min, max, false
+		}
+		if obj.Count <= 1 {
+			return min, max, true
+		}
+		// the copies are within the circle through the furthest corner.
+		var r float32
+		for _, x := range [2]float32{min.X, max.X} {
+			for _, z := range [2]float32{min.Z, max.Z} {
+				r = float32(math.Max(float64(r), math.Hypot(float64(x-obj.Center.X), float64(z-obj.Center.Z))))
+			}
+		}
+		return vec3.New(obj.Center.X-r, min.Y, obj.Center.Z-r), vec3.New(obj.Center.X+r, max.Y, obj.Center.Z+r), true
+	case Twist:
+		// points keep their distance to the center.
+		if _, _, ok = Bounds(obj.Sub); !ok {
+			return min, max, false
+		}
+		r := boundsRadius(obj.Sub, func(c vec3.Vec3) float32 { return c.Distance(obj.Center) })
+		return obj.Center.Subtract(vec3.New(r, r, r)), obj.Center.Add(vec3.New(r, r, r)), true
+	case Bend:
+		// points keep their distance to the center in the XY plane.
+		if min, max, ok = Bounds(obj.Sub); !ok {
+			return min, max, false
+		}
+		r := boundsRadius(obj.Sub, func(c vec3.Vec3) float32 { return c.XY().Distance(obj.Center.XY()) })
+		return vec3.New(obj.Center.X-r, obj.Center.Y-r, min.Z), vec3.New(obj.Center.X+r, obj.Center.Y+r, max.Z), true
+	case Taper:
+		if min, max, ok = Bounds(obj.Sub); !ok {
+			return min, max, false
+		}
+		// the scale is linear in y, so the extremes are at the ends.
+		s0, s1 := obj.scale(min.Y), obj.scale(max.Y)
+		taperBounds(&min.X, &max.X, obj.Center.X, s0, s1)
+		taperBounds(&min.Z, &max.Z, obj.Center.Z, s0, s1)
+		return min, max, true
+	case Round:
+		return growBounds(obj.Sub, obj.Radius)
+	case Onion:
+		return growBounds(obj.Sub, obj.Thickness)
+	case Displace:
+		return growBounds(obj.Sub, obj.Amplitude)
+	}
+	return vec3.Vec3{}, vec3.Vec3{}, false
+}
+
+// growBounds returns the bounds of s grown by d on every side.
+func growBounds(s Sdf, d float32) (min, max vec3.Vec3, ok bool) {
+	if min, max, ok = Bounds(s); !ok {
+		return min, max, false
+	}
+	g := vec3.New(d, d, d)
+	return min.Subtract(g), max.Add(g), true
+}
+
+// repeatBounds grows the range of one axis by the copies on each side. It
+// fails for an axis repeated forever.
+func repeatBounds(lo, hi *float32, period, limit float32) bool {
+	if period == 0 {
+		return true
+	}
+	if limit <= 0 {
+		return false
+	}
+	offset := float32(math.Abs(float64(period)) * math.Floor(float64(limit)))
+	*lo -= offset
+	*hi += offset
+	return true
+}
+
+// mirrorBounds makes the range of a mirrored axis symmetric around center.
+func mirrorBounds(lo, hi *float32, center float32, mirror bool) {
+	if !mirror {
+		return
+	}
+	r := float32(math.Max(math.Abs(float64(*lo-center)), math.Abs(float64(*hi-center))))
+	*lo, *hi = center-r, center+r
+}
+
+// taperBounds scales the range of one axis around center by s0 to s1.
+func taperBounds(lo, hi *float32, center, s0, s1 float32) {
+	l, h := *lo-center, *hi-center
+	*lo = center + min(l*s0, l*s1)
+	*hi = center + max(h*s0, h*s1)
+}
@@ -0,0 +1,258 @@
+package sdf
+
+import (
+	"fmt"
+	"hash"
+	"math"
+
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+// BrickSize is the number of samples along each axis of a brick.
+const BrickSize = 8
+
+const brickVolume = BrickSize * BrickSize * BrickSize
+
+// Indirection values for bricks that are not stored.
+const (
+	BrickEmptyOutside int32 = -1
+	BrickEmptyInside  int32 = -2
+)
+
+// BrickMap is a sparse baked distance field. Only the bricks within Band of
+// the surface are stored, everything else is represented by the sign of the
+// distance in the indirection table.
+type BrickMap struct {
+	Min       vec3.Vec3
+	VoxelSize float32
+	Band      float32
+	// Dims is the number of bricks along each axis.
+	Dims        [3]int
+	Indirection []int32
+	Bricks      []float32
+}
+
+// BakeBrickMap samples s on a grid covering [min, max] with the given voxel size.
+// Bricks whose center is further than band plus the brick radius from the
+// surface are skipped without sampling the individual voxels.
+func BakeBrickMap(s Sdf, min, max vec3.Vec3, voxelSize, band float32) *BrickMap {
+	size := max.Subtract(min)
+	brickWorld := voxelSize * BrickSize
+	b := &BrickMap{
+		Min:       min,
+		VoxelSize: voxelSize,
+		Band:      band,
+		Dims: [3]int{
+			brickCount(size.X, brickWorld),
+			brickCount(size.Y, brickWorld),
+			brickCount(size.Z, brickWorld),
+		},
+	}
+	b.Indirection = make([]int32, b.Dims[0]*b.Dims[1]*b.Dims[2])
+
+	half := brickWorld * 0.5
+	brickRadius := vec3.New(half, half, half).Length()
+	for z := 0; z < b.Dims[2]; z++ {
+		for y := 0; y < b.Dims[1]; y++ {
+			for x := 0; x < b.Dims[0]; x++ {
+				idx := b.brickIndex(x, y, z)
+				origin := min.Add(vec3.New(float32(x), float32(y), float32(z)).MultiplyScalar(brickWorld))
+				center := origin.Add(vec3.New(half, half, half))
+				d := s.Distance(center)
+				if d > band+brickRadius {
+					b.Indirection[idx] = BrickEmptyOutside
+					continue
+				}
+				if d < -band-brickRadius {
+					b.Indirection[idx] = BrickEmptyInside
+					continue
+				}
+				b.Indirection[idx] = b.bakeBrick(s, origin, d)
+			}
+		}
+	}
+	return b
+}
+
+func brickCount(size, brickWorld float32) int {
+	n := int(math.Ceil(float64(size / brickWorld)))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func (b *BrickMap) brickIndex(x, y, z int) int {
+	return x + y*b.Dims[0] + z*b.Dims[0]*b.Dims[1]
+}
+
+// bakeBrick samples a single brick and stores it if any sample is inside the band.
+func (b *BrickMap) bakeBrick(s Sdf, origin vec3.Vec3, centerDistance float32) int32 {
+	samples := make([]float32, brickVolume)
+	nearSurface := false
+	for z := 0; z < BrickSize; z++ {
+		for y := 0; y < BrickSize; y++ {
+			for x := 0; x < BrickSize; x++ {
+				p := origin.Add(vec3.New(float32(x), float32(y), float32(z)).MultiplyScalar(b.VoxelSize))
+				d := s.Distance(p)
+				if d < b.Band && d > -b.Band {
+					nearSurface = true
+				}
+				samples[x+y*BrickSize+z*BrickSize*BrickSize] = d
+			}
+		}
+	}
+	if !nearSurface {
+		if centerDistance < 0 {
+			return BrickEmptyInside
+		}
+		return BrickEmptyOutside
+	}
+	b.Bricks = append(b.Bricks, samples...)
+	return int32(b.BrickCount() - 1)
+}
+
+// BrickCount returns the number of stored bricks.
+func (b *BrickMap) BrickCount() int {
+	return len(b.Bricks) / brickVolume
+}
+
+// voxel returns the baked sample at the global voxel coordinate.
+func (b *BrickMap) voxel(x, y, z int) float32 {
+	if x < 0 || y < 0 || z < 0 {
+		return b.Band
+	}
+	bx, by, bz := x/BrickSize, y/BrickSize, z/BrickSize
+	if bx >= b.Dims[0] || by >= b.Dims[1] || bz >= b.Dims[2] {
+		return b.Band
+	}
+	switch idx := b.Indirection[b.brickIndex(bx, by, bz)]; idx {
+	case BrickEmptyOutside:
+		return b.Band
+	case BrickEmptyInside:
+		return -b.Band
+	default:
+		lx, ly, lz := x-bx*BrickSize, y-by*BrickSize, z-bz*BrickSize
+		return b.Bricks[int(idx)*brickVolume+lx+ly*BrickSize+lz*BrickSize*BrickSize]
+	}
+}
+
+// Sample trilinearly interpolates the baked distance at p.
+// Outside the baked region the band distance is returned.
+func (b *BrickMap) Sample(p vec3.Vec3) float32 {
+	g := p.Subtract(b.Min).MultiplyScalar(1.0 / b.VoxelSize)
+	fx, fy, fz := math.Floor(float64(g.X)), math.Floor(float64(g.Y)), math.Floor(float64(g.Z))
+	x, y, z := int(fx), int(fy), int(fz)
+	tx, ty, tz := g.X-float32(fx), g.Y-float32(fy), g.Z-float32(fz)
+
+	c00 := lerp(b.voxel(x, y, z), b.voxel(x+1, y, z), tx)
+	c10 := lerp(b.voxel(x, y+1, z), b.voxel(x+1, y+1, z), tx)
+	c01 := lerp(b.voxel(x, y, z+1), b.voxel(x+1, y, z+1), tx)
+	c11 := lerp(b.voxel(x, y+1, z+1), b.voxel(x+1, y+1, z+1), tx)
+	return lerp(lerp(c00, c10, ty), lerp(c01, c11, ty), tz)
+}
+
+func lerp(a, b, t float32) float32 {
+	return a + (b-a)*t
+}
+
+func (b *BrickMap) Distance(p vec3.Vec3) float32 {
+	return b.Sample(p)
+}
+
+func (b *BrickMap) Hash(h hash.Hash) {
+	h.Write(sphereSalt)
+	HashVec3(b.Min, h)
+	HashFloat32(b.VoxelSize, h)
+	HashFloat32(b.Band, h)
+	for _, n := range b.Dims {
+		HashFloat32(float32(n), h)
+	}
+	for _, idx := range b.Indirection {
+		HashFloat32(float32(idx), h)
+	}
+	for _, d := range b.Bricks {
+		HashFloat32(d, h)
+	}
+}
+
+// validate checks that the tables match Dims, so decoded maps can be sampled.
+func (b *BrickMap) validate() error {
+	if !(b.VoxelSize > 0) {
+		return fmt.Errorf("voxel size %v is not positive", b.VoxelSize)
+	}
+	n := 1
+	for _, d := range b.Dims {
+		if d < 1 || d > len(b.Indirection) {
+			return fmt.Errorf("dims %v do not match %v indirection entries", b.Dims, len(b.Indirection))
+		}
+		n *= d
+	}
+	if n != len(b.Indirection) {
+		return fmt.Errorf("dims %v do not match %v indirection entries", b.Dims, len(b.Indirection))
+	}
+	if len(b.Bricks)%brickVolume != 0 {
+		return fmt.Errorf("%v brick samples are not whole bricks", len(b.Bricks))
+	}
+	for _, idx := range b.Indirection {
+		if idx != BrickEmptyOutside && idx != BrickEmptyInside && (idx < 0 || int(idx) >= b.BrickCount()) {
+			return fmt.Errorf("brick index %v out of range", idx)
+		}
+	}
+	return nil
+}
+
+// BrickMapGPU is the texture layout of a brick map. The atlas is a 3D float
+// texture with the stored bricks packed along x, then y, then z. The
+// indirection is a 3D integer texture holding the brick index or one of the
+// BrickEmpty values.
+type BrickMapGPU struct {
+	IndirectionDims [3]int
+	Indirection     []int32
+	// AtlasBricks is the number of bricks along each atlas axis.
+	AtlasBricks [3]int
+	Atlas       []float32
+}
+
+// AtlasSize returns the atlas texture size in texels.
+func (g *BrickMapGPU) AtlasSize() [3]int {
+	return [3]int{g.AtlasBricks[0] * BrickSize, g.AtlasBricks[1] * BrickSize, g.AtlasBricks[2] * BrickSize}
+}
+
+// AtlasBrickOrigin returns the texel coordinate of a brick in the atlas.
+func (g *BrickMapGPU) AtlasBrickOrigin(index int) [3]int {
+	ax := index % g.AtlasBricks[0]
+	ay := (index / g.AtlasBricks[0]) % g.AtlasBricks[1]
+	az := index / (g.AtlasBricks[0] * g.AtlasBricks[1])
+	return [3]int{ax * BrickSize, ay * BrickSize, az * BrickSize}
+}
+
+// GPULayout packs the stored bricks into a roughly cubic atlas.
+func (b *BrickMap) GPULayout() BrickMapGPU {
+	count := b.BrickCount()
+	side := int(math.Ceil(math.Cbrt(float64(count))))
+	if side < 1 {
+		side = 1
+	}
+	layout := BrickMapGPU{
+		IndirectionDims: b.Dims,
+		Indirection:     b.Indirection,
+		AtlasBricks:     [3]int{side, side, (count + side*side - 1) / (side * side)},
+	}
+	if layout.AtlasBricks[2] < 1 {
+		layout.AtlasBricks[2] = 1
+	}
+	size := layout.AtlasSize()
+	layout.Atlas = make([]float32, size[0]*size[1]*size[2])
+	for i := 0; i < count; i++ {
+		o := layout.AtlasBrickOrigin(i)
+		for z := 0; z < BrickSize; z++ {
+			for y := 0; y < BrickSize; y++ {
+				row := (o[0]) + (o[1]+y)*size[0] + (o[2]+z)*size[0]*size[1]
+				src := i*brickVolume + y*BrickSize + z*BrickSize*BrickSize
+				copy(layout.Atlas[row:row+BrickSize], b.Bricks[src:src+BrickSize])
+			}
+		}
+	}
+	return layout
+}
@@ -0,0 +1,80 @@
+package sdf
+
+import (
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestBrickMapSample(t *testing.T) {
+	sphere := Sphere{Center: vec3.New(0, 0, 0), Radius: 3.0}
+	b := BakeBrickMap(sphere, vec3.New(-4, -4, -4), vec3.New(4, 4, 4), 0.125, 0.5)
+
+	if b.Dims != [3]int{8, 8, 8} {
+		t.Errorf("Unexpected brick dimensions: %v", b.Dims)
+	}
+	total := b.Dims[0] * b.Dims[1] * b.Dims[2]
+	if b.BrickCount() == 0 || b.BrickCount() >= total {
+		t.Errorf("Expected a sparse brick map, got %v of %v bricks", b.BrickCount(), total)
+	}
+
+	testcases := []vec3.Vec3{
+		vec3.New(3, 0, 0),
+		vec3.New(0, -3.1, 0),
+		vec3.New(2, 2, 0.3),
+		vec3.New(0.1, 0.2, 2.9),
+	}
+	for i, p := range testcases {
+		expected := sphere.Distance(p)
+		d := b.Sample(p)
+		if abs(d-expected) > 0.02 {
+			t.Errorf("case %v: expected %v, got %v", i, expected, d)
+		}
+	}
+
+	if d := b.Sample(vec3.New(0, 0, 0)); d != -b.Band {
+		t.Errorf("Expected inside band at the center, got %v", d)
+	}
+	if d := b.Sample(vec3.New(3.9, 3.9, 3.9)); d != b.Band {
+		t.Errorf("Expected outside band in the corner, got %v", d)
+	}
+	if d := b.Sample(vec3.New(10, 0, 0)); d != b.Band {
+		t.Errorf("Expected band outside the baked region, got %v", d)
+	}
+}
+
+func TestBrickMapGPULayout(t *testing.T) {
+	sphere := Sphere{Center: vec3.New(0, 0, 0), Radius: 1.0}
+	b := BakeBrickMap(sphere, vec3.New(-2, -2, -2), vec3.New(2, 2, 2), 0.1, 0.3)
+	layout := b.GPULayout()
+	size := layout.AtlasSize()
+
+	if layout.AtlasBricks[0]*layout.AtlasBricks[1]*layout.AtlasBricks[2] < b.BrickCount() {
+		t.Fatalf("Atlas too small for %v bricks: %v", b.BrickCount(), layout.AtlasBricks)
+	}
+
+	for i, idx := range layout.Indirection {
+		if idx < 0 {
+			continue
+		}
+		bx := i % b.Dims[0]
+		by := (i / b.Dims[0]) % b.Dims[1]
+		bz := i / (b.Dims[0] * b.Dims[1])
+		o := layout.AtlasBrickOrigin(int(idx))
+		for _, l := range [][3]int{{0, 0, 0}, {7, 0, 0}, {3, 5, 1}, {7, 7, 7}} {
+			expected := b.voxel(bx*BrickSize+l[0], by*BrickSize+l[1], bz*BrickSize+l[2])
+			got := layout.Atlas[(o[0]+l[0])+(o[1]+l[1])*size[0]+(o[2]+l[2])*size[0]*size[1]]
+			if got != expected {
+				t.Fatalf("brick %v voxel %v: expected %v, got %v", idx, l, expected, got)
+			}
+		}
+	}
+}
+
+func TestBrickMapHash(t *testing.T) {
+	a := &BrickMap{VoxelSize: 1, Dims: [3]int{2, 1, 1}, Indirection: []int32{BrickEmptyOutside, BrickEmptyOutside}}
+	b := &BrickMap{VoxelSize: 1, Dims: [3]int{1, 2, 1}, Indirection: []int32{BrickEmptyOutside, BrickEmptyOutside}}
+	if CompareSdfs(a, b) {
+		t.Error("Expected brick maps with different dimensions to differ")
+	}
+}
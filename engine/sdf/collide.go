@@ -0,0 +1,121 @@
+package sdf
+
+import (
+	"math"
+
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+// Contact is where a shape touches the surface of an Sdf.
+type Contact struct {
+	// Point is on the surface and Normal points out of it.
+	Point, Normal vec3.Vec3
+	// Depth is how far the shape reaches into the surface, negative when
+	// there is a gap.
+	Depth float32
+}
+
+const (
+	// collideEpsilon is the distance that counts as touching in sweeps.
+	collideEpsilon = 1e-4
+	maxSweepSteps  = 64
+)
+
+// contactAt returns the contact of a sphere at p with distance d to s.
+func contactAt(s Sdf, p vec3.Vec3, d, radius float32) Contact {
+	n := Normal(s, p, 0)
+	return Contact{Point: p.Subtract(n.MultiplyScalar(d)), Normal: n, Depth: radius - d}
+}
+
+// SphereOverlap reports whether a sphere reaches into s and returns the
+// deepest contact. Pushing the sphere by Normal * Depth separates it.
+func SphereOverlap(s Sdf, center vec3.Vec3, radius float32) (Contact, bool) {
+	d := s.Distance(center)
+	if d >= radius {
+		return Contact{}, false
+	}
+	return contactAt(s, center, d, radius), true
+}
+
+// capsuleSpheres returns the number of spheres covering the capsule from a to
+// b. They are at most radius/2 apart, so the chain differs from the capsule
+// by less than 4% of the radius.
+func capsuleSpheres(a, b vec3.Vec3, radius float32) int {
+	if radius <= 0 {
+		return 2
+	}
+	return 2 + int(a.Distance(b)/(radius/2))
+}
+
+// capsuleDistance returns the distance of the closest sphere of a capsule
+// to s, and the center of that sphere.
+func capsuleDistance(s Sdf, a, b vec3.Vec3, radius float32) (float32, vec3.Vec3) {
+	n := capsuleSpheres(a, b, radius)
+	best, center := infinity, a
+	for i := 0; i < n; i++ {
+		p := a.Lerp(b, float32(i)/float32(n-1))
+		if d := s.Distance(p); d < best {
+			best, center = d, p
+		}
+	}
+	return best - radius, center
+}
+
+// CapsuleOverlap reports whether the capsule around the segment from a to b
+// reaches into s and returns the deepest contact.
+func CapsuleOverlap(s Sdf, a, b vec3.Vec3, radius float32) (Contact, bool) {
+	d, center := capsuleDistance(s, a, b, radius)
+	if d >= 0 {
+		return Contact{}, false
+	}
+	return contactAt(s, center, d+radius, radius), true
+}
+
+// SweepCapsule moves the capsule around the segment from a to b by motion and
+// returns the fraction t in [0, 1] of the motion where it first touches s,
+// with the contact there. A capsule that already touches s hits at t = 0. It
+// uses conservative advancement, so it never tunnels through thin surfaces.
+// Grazing sweeps that run out of steps before reaching the end hit at the t
+// reached, with the contact of the closest surface.
+func SweepCapsule(s Sdf, a, b vec3.Vec3, radius float32, motion vec3.Vec3) (float32, Contact, bool) {
+	length := motion.Length()
+	lipschitz := Lipschitz(s)
+	var t float32
+	for i := 0; ; i++ {
+		offset := motion.MultiplyScalar(t)
+		d, center := capsuleDistance(s, a.Add(offset), b.Add(offset), radius)
+		d /= lipschitz
+		if d < collideEpsilon || i == maxSweepSteps {
+			return t, contactAt(s, center, d+radius, radius), true
+		}
+		if length == 0 || d >= infinity {
+			break
+		}
+		// no point of the capsule can reach the surface in less than d.
+		t += d / length
+		if t > 1 {
+			break
+		}
+	}
+	return 1, Contact{}, false
+}
+
+// ClosestPoint projects p onto the surface of s by stepping along the
+// gradient. It fails when s has no surface or no usable gradient.
+func ClosestPoint(s Sdf, p vec3.Vec3) (vec3.Vec3, bool) {
+	for i := 0; i < 16; i++ {
+		d := s.Distance(p)
+		if d >= infinity {
+			return vec3.Vec3{}, false
+		}
+		if float32(math.Abs(float64(d))) < collideEpsilon {
+			return p, true
+		}
+		n := Normal(s, p, 0)
+		if n.Length() == 0 {
+			return vec3.Vec3{}, false
+		}
+		p = p.Subtract(n.MultiplyScalar(d))
+	}
+	return p, float32(math.Abs(float64(s.Distance(p)))) < collideEpsilon*10
+}
@@ -0,0 +1,85 @@
+package sdf
+
+import (
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestSphereOverlap(t *testing.T) {
+	ground := Sphere{Center: vec3.New(0, -10, 0), Radius: 10}
+	contact, ok := SphereOverlap(ground, vec3.New(0, 0.5, 0), 1)
+	if !ok || abs(contact.Depth-0.5) > 1e-4 || !contact.Normal.ApproxEqual(vec3.New(0, 1, 0), 1e-3) || !contact.Point.ApproxEqual(vec3.New(0, 0, 0), 1e-3) {
+		t.Errorf("Expected a contact 0.5 deep at the top, got %+v", contact)
+	}
+	if _, ok := SphereOverlap(ground, vec3.New(0, 2, 0), 1); ok {
+		t.Errorf("Expected no overlap above the ground")
+	}
+	if _, ok := SphereOverlap(Infinity{}, vec3.New(0, 0, 0), 1); ok {
+		t.Errorf("Expected no overlap with an empty world")
+	}
+}
+
+func TestCapsuleOverlap(t *testing.T) {
+	wall := Cube{Center: vec3.New(2, 0, 0), HalfSize: vec3.New(1, 5, 5)}
+	// only the top of the tilted capsule reaches the wall.
+	contact, ok := CapsuleOverlap(wall, vec3.New(0, 0, 0), vec3.New(0.8, 2, 0), 0.4)
+	if !ok || abs(contact.Depth-0.2) > 1e-4 || !contact.Normal.ApproxEqual(vec3.New(-1, 0, 0), 1e-3) || abs(contact.Point.X-1) > 1e-4 {
+		t.Errorf("Expected a contact 0.2 deep on the wall, got %+v", contact)
+	}
+	if _, ok := CapsuleOverlap(wall, vec3.New(0, 0, 0), vec3.New(0, 2, 0), 0.4); ok {
+		t.Errorf("Expected an upright capsule to stay clear of the wall")
+	}
+}
+
+func TestSweepCapsule(t *testing.T) {
+	wall := Cube{Center: vec3.New(5, 0, 0), HalfSize: vec3.New(1, 5, 5)}
+	a, b := vec3.New(0, 0, 0), vec3.New(0, 2, 0)
+
+	tHit, contact, hit := SweepCapsule(wall, a, b, 0.5, vec3.New(10, 0, 0))
+	if !hit || abs(tHit-0.35) > 1e-3 || !contact.Normal.ApproxEqual(vec3.New(-1, 0, 0), 1e-3) || abs(contact.Point.X-4) > 1e-3 {
+		t.Errorf("Expected to touch the wall after 3.5, got %v %+v", tHit, contact)
+	}
+	if _, _, hit := SweepCapsule(wall, a, b, 0.5, vec3.New(3, 0, 0)); hit {
+		t.Errorf("Expected a short sweep to stop before the wall")
+	}
+	if _, _, hit := SweepCapsule(wall, a, b, 0.5, vec3.New(0, 0, 10)); hit {
+		t.Errorf("Expected a sweep along the wall to miss")
+	}
+	// a thin wall is not skipped by a long sweep.
+	thin := Cube{Center: vec3.New(50, 0, 0), HalfSize: vec3.New(0.01, 5, 5)}
+	if tHit, _, hit := SweepCapsule(thin, a, b, 0.5, vec3.New(100, 0, 0)); !hit || abs(tHit-0.4949) > 1e-3 {
+		t.Errorf("Expected to stop at the thin wall, got %v %v", hit, tHit)
+	}
+	if tHit, contact, hit := SweepCapsule(wall, vec3.New(3.8, 0, 0), vec3.New(3.8, 2, 0), 0.5, vec3.New(1, 0, 0)); !hit || tHit != 0 || abs(contact.Depth-0.3) > 1e-3 {
+		t.Errorf("Expected an overlapping capsule to hit at 0, got %v %+v", tHit, contact)
+	}
+	// grazing a floor takes tiny steps, running out of them must not pass the wall.
+	room := Union{Cube{Center: vec3.New(0, -1, 0), HalfSize: vec3.New(10, 1, 10)}, Cube{Center: vec3.New(2.5, 2, 0), HalfSize: vec3.New(0.5, 2, 5)}}
+	start := vec3.New(0, 0.51, 0)
+	tHit, contact, hit = SweepCapsule(room, start, start.Add(vec3.New(0, 1, 0)), 0.5, vec3.New(5, 0, 0))
+	if !hit || start.X+5*tHit > 1.5+1e-3 {
+		t.Errorf("Expected the grazing sweep to stop before the wall, got %v %v", hit, tHit)
+	}
+	if contact.Depth > 0 {
+		t.Errorf("Expected no overlap where the grazing sweep stops, got %+v", contact)
+	}
+}
+
+func TestClosestPoint(t *testing.T) {
+	sphere := Sphere{Center: vec3.New(1, 2, 3), Radius: 2}
+	for _, p := range []vec3.Vec3{vec3.New(10, 2, 3), vec3.New(1, 2.5, 3), vec3.New(-4, -4, 0)} {
+		q, ok := ClosestPoint(sphere, p)
+		expected := sphere.Center.Add(p.Subtract(sphere.Center).Normalize().MultiplyScalar(2))
+		if !ok || !q.ApproxEqual(expected, 1e-3) {
+			t.Errorf("Expected %v to project to %v, got %v %v", p, expected, q, ok)
+		}
+	}
+	cube := Cube{Center: vec3.New(0, 0, 0), HalfSize: vec3.New(1, 1, 1)}
+	if q, ok := ClosestPoint(cube, vec3.New(3, 4, 0.5)); !ok || !q.ApproxEqual(vec3.New(1, 1, 0.5), 1e-3) {
+		t.Errorf("Expected the cube edge, got %v %v", q, ok)
+	}
+	if _, ok := ClosestPoint(Infinity{}, vec3.New(0, 0, 0)); ok {
+		t.Errorf("Expected no closest point without a surface")
+	}
+}
@@ -0,0 +1,275 @@
+// Deformations bend and offset their child. They do not keep distances, so
+// they report a Lipschitz factor to stay conservative.
+
+package sdf
+
+import (
+	"encoding/binary"
+	"hash"
+	"math"
+	"reflect"
+
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+var (
+	twistSalt    = []byte{9, 10, 11, 12}
+	bendSalt     = []byte{9, 10, 11, 13}
+	taperSalt    = []byte{9, 10, 11, 14}
+	roundSalt    = []byte{9, 10, 11, 15}
+	onionSalt    = []byte{9, 10, 11, 16}
+	displaceSalt = []byte{9, 10, 11, 17}
+)
+
+// Deformation is implemented by nodes whose distance changes faster than one
+// unit per unit. Lipschitz is the largest factor near the node, the distance
+// divided by it never overshoots the surface.
+type Deformation interface {
+	Sdf
+	Lipschitz() float32
+}
+
+// Lipschitz returns the Lipschitz factor of a tree, at least 1.
+func Lipschitz(s Sdf) float32 {
+	l := float32(1)
+	switch obj := s.(type) {
+	case Union:
+		for _, sub := range obj {
+			l = max(l, Lipschitz(sub))
+		}
+		return l
+	case wrapper:
+		l = Lipschitz(obj.sub())
+	}
+	if d, ok := s.(Deformation); ok {
+		l *= max(1, d.Lipschitz())
+	}
+	return l
+}
+
+// unboundedRadius is the extent assumed for children without bounds when
+// estimating a Lipschitz factor.
+const unboundedRadius = 10
+
+// boundsRadius returns the largest distance from the bounds of s, measured
+// by dist. Without bounds it is unboundedRadius.
+func boundsRadius(s Sdf, dist func(corner vec3.Vec3) float32) float32 {
+	min, max, ok := Bounds(s)
+	if !ok {
+		return unboundedRadius
+	}
+	var r float32
+	for i := 0; i < 8; i++ {
+		corner := min
+		if i&1 != 0 {
+			corner.X = max.X
+		}
+		if i&2 != 0 {
+			corner.Y = max.Y
+		}
+		if i&4 != 0 {
+			corner.Z = max.Z
+		}
+		r = float32(math.Max(float64(r), float64(dist(corner))))
+	}
+	return r
+}
+
+// Twist rotates Sub around Axis through Center by Rate radians per unit along
+// the axis.
+type Twist struct {
+	Center vec3.Vec3
+	Axis   vec3.Vec3
+	Rate   float32
+	Sub    Sdf
+}
+
+func (s Twist) sub() Sdf { return s.Sub }
+
+func (s Twist) domain(p vec3.Vec3) vec3.Vec3 {
+	axis := s.Axis.Normalize()
+	q := p.Subtract(s.Center)
+	along := q.DotProduct(axis)
+	sin, cos := math.Sincos(float64(-s.Rate * along))
+	// Rodrigues' rotation of q around the axis.
+	r := q.MultiplyScalar(float32(cos)).
+		Add(axis.CrossProduct(q).MultiplyScalar(float32(sin))).
+		Add(axis.MultiplyScalar(along * (1 - float32(cos))))
+	return s.Center.Add(r)
+}
+
+func (s Twist) Distance(p vec3.Vec3) float32 {
+	return s.Sub.Distance(s.domain(p))
+}
+
+// Lipschitz grows with the distance of Sub from the axis.
+func (s Twist) Lipschitz() float32 {
+	axis := s.Axis.Normalize()
+	r := boundsRadius(s.Sub, func(c vec3.Vec3) float32 {
+		q := c.Subtract(s.Center)
+		return q.Subtract(axis.MultiplyScalar(q.DotProduct(axis))).Length()
+	})
+	return 1 + float32(math.Abs(float64(s.Rate)))*r
+}
+
+func (s Twist) Hash(h hash.Hash) {
+	h.Write(twistSalt)
+	HashVec3(s.Center, h)
+	HashVec3(s.Axis, h)
+	HashFloat32(s.Rate, h)
+	s.Sub.Hash(h)
+}
+
+// Bend curves Sub in the XY plane around Center, by Rate radians per unit
+// along X.
+type Bend struct {
+	Center vec3.Vec3
+	Rate   float32
+	Sub    Sdf
+}
+
+func (s Bend) sub() Sdf { return s.Sub }
+
+func (s Bend) domain(p vec3.Vec3) vec3.Vec3 {
+	x, y := p.X-s.Center.X, p.Y-s.Center.Y
+	sin, cos := math.Sincos(float64(s.Rate * x))
+	c, sn := float32(cos), float32(sin)
+	return vec3.New(s.Center.X+c*x-sn*y, s.Center.Y+sn*x+c*y, p.Z)
+}
+
+func (s Bend) Distance(p vec3.Vec3) float32 {
+	return s.Sub.Distance(s.domain(p))
+}
+
+func (s Bend) Lipschitz() float32 {
+	r := boundsRadius(s.Sub, func(c vec3.Vec3) float32 {
+		return c.XY().Distance(s.Center.XY())
+	})
+	return 1 + float32(math.Abs(float64(s.Rate)))*r
+}
+
+func (s Bend) Hash(h hash.Hash) {
+	h.Write(bendSalt)
+	HashVec3(s.Center, h)
+	HashFloat32(s.Rate, h)
+	s.Sub.Hash(h)
+}
+
+// MinTaper is the smallest scale of a Taper, it keeps nodes from shrinking to a point.
+const MinTaper = 0.1
+
+// Taper scales Sub in X and Z by 1 + Rate * (y - Center.Y), so a positive
+// rate widens it upwards.
+type Taper struct {
+	Center vec3.Vec3
+	Rate   float32
+	Sub    Sdf
+}
+
+func (s Taper) scale(y float32) float32 {
+	return max(MinTaper, 1+s.Rate*(y-s.Center.Y))
+}
+
+func (s Taper) sub() Sdf { return s.Sub }
+
+func (s Taper) domain(p vec3.Vec3) vec3.Vec3 {
+	k := 1 / s.scale(p.Y)
+	return vec3.New(s.Center.X+(p.X-s.Center.X)*k, p.Y, s.Center.Z+(p.Z-s.Center.Z)*k)
+}
+
+func (s Taper) Distance(p vec3.Vec3) float32 {
+	return s.Sub.Distance(s.domain(p))
+}
+
+// Lipschitz grows where Sub is shrunk, and with its width as the scale
+// changes along y.
+func (s Taper) Lipschitz() float32 {
+	min, max, ok := Bounds(s.Sub)
+	if !ok {
+		min.Y, max.Y = s.Center.Y-unboundedRadius, s.Center.Y+unboundedRadius
+	}
+	s0, s1 := s.scale(min.Y), s.scale(max.Y)
+	smallest, largest := float32(math.Min(float64(s0), float64(s1))), float32(math.Max(float64(s0), float64(s1)))
+	r := boundsRadius(s.Sub, func(c vec3.Vec3) float32 {
+		return c.XZ().Distance(s.Center.XZ())
+	})
+	// the domain scales by 1/scale across and moves by r*Rate/scale² along y.
+	return 1/smallest + float32(math.Abs(float64(s.Rate)))*r*largest/(smallest*smallest)
+}
+
+func (s Taper) Hash(h hash.Hash) {
+	h.Write(taperSalt)
+	HashVec3(s.Center, h)
+	HashFloat32(s.Rate, h)
+	s.Sub.Hash(h)
+}
+
+// Round grows Sub by Radius, rounding its edges.
+type Round struct {
+	Radius float32
+	Sub    Sdf
+}
+
+func (s Round) sub() Sdf                     { return s.Sub }
+func (s Round) domain(p vec3.Vec3) vec3.Vec3 { return p }
+
+func (s Round) Distance(p vec3.Vec3) float32 {
+	return s.Sub.Distance(p) - s.Radius
+}
+
+func (s Round) Hash(h hash.Hash) {
+	h.Write(roundSalt)
+	HashFloat32(s.Radius, h)
+	s.Sub.Hash(h)
+}
+
+// Onion turns Sub into a shell Thickness thick on each side of its surface.
+type Onion struct {
+	Thickness float32
+	Sub       Sdf
+}
+
+func (s Onion) sub() Sdf                     { return s.Sub }
+func (s Onion) domain(p vec3.Vec3) vec3.Vec3 { return p }
+
+func (s Onion) Distance(p vec3.Vec3) float32 {
+	return float32(math.Abs(float64(s.Sub.Distance(p)))) - s.Thickness
+}
+
+func (s Onion) Hash(h hash.Hash) {
+	h.Write(onionSalt)
+	HashFloat32(s.Thickness, h)
+	s.Sub.Hash(h)
+}
+
+// Displace adds Func to the distance of Sub. Amplitude is the largest
+// absolute value of Func and Slope the largest length of its gradient.
+// Displace has no GLSL and is not serialized.
+type Displace struct {
+	Func      func(p vec3.Vec3) float32
+	Amplitude float32
+	Slope     float32
+	Sub       Sdf
+}
+
+func (s Displace) sub() Sdf                     { return s.Sub }
+func (s Displace) domain(p vec3.Vec3) vec3.Vec3 { return p }
+
+func (s Displace) Distance(p vec3.Vec3) float32 {
+	return s.Sub.Distance(p) + s.Func(p)
+}
+
+func (s Displace) Lipschitz() float32 {
+	return 1 + s.Slope
+}
+
+func (s Displace) Hash(h hash.Hash) {
+	h.Write(displaceSalt)
+	// functions can only be told apart by their address.
+	var pointer [8]byte
+	binary.LittleEndian.PutUint64(pointer[:], uint64(reflect.ValueOf(s.Func).Pointer()))
+	h.Write(pointer[:])
+	HashFloat32(s.Amplitude, h)
+	HashFloat32(s.Slope, h)
+	s.Sub.Hash(h)
+}
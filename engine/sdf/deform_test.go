@@ -0,0 +1,210 @@
+package sdf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+var post = Cube{Center: vec3.New(0, 1, 0), HalfSize: vec3.New(0.5, 1, 0.25)}
+
+func wave(p vec3.Vec3) float32 {
+	return 0.1 * float32(math.Sin(float64(p.X*5)))
+}
+
+func deformations() map[string]Deformation {
+	return map[string]Deformation{
+		"twist":    Twist{Axis: vec3.New(0, 1, 0), Rate: 1.5, Sub: post},
+		"tilted":   Twist{Center: vec3.New(0, 1, 0), Axis: vec3.New(1, 1, 0), Rate: 1, Sub: post},
+		"bend":     Bend{Center: vec3.New(0, 0.5, 0), Rate: 0.8, Sub: Cube{HalfSize: vec3.New(2, 0.2, 0.5)}},
+		"taper":    Taper{Rate: -0.4, Sub: post},
+		"displace": Displace{Func: wave, Amplitude: 0.1, Slope: 0.5, Sub: post},
+	}
+}
+
+// randomNear returns a point within the bounds of s.
+func randomNear(r *rand.Rand, s Sdf) vec3.Vec3 {
+	min, max, _ := Bounds(s)
+	return vec3.New(min.X+r.Float32()*(max.X-min.X), min.Y+r.Float32()*(max.Y-min.Y), min.Z+r.Float32()*(max.Z-min.Z))
+}
+
+func TestLipschitz(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for name, s := range deformations() {
+		l := s.Lipschitz()
+		if l <= 1 {
+			t.Errorf("%v: expected a factor above 1, got %v", name, l)
+		}
+		for i := 0; i < 2000; i++ {
+			a := randomNear(r, s)
+			b := a.Add(vec3.New(r.Float32()-0.5, r.Float32()-0.5, r.Float32()-0.5).MultiplyScalar(0.1))
+			if slope := abs(s.Distance(a)-s.Distance(b)) / a.Distance(b); slope > l*1.001 {
+				t.Fatalf("%v: slope %v between %v and %v above %v", name, slope, a, b, l)
+			}
+		}
+	}
+	if l := Lipschitz(Union{Sphere{Radius: 1}, Color{Sub: Displace{Func: wave, Slope: 0.5, Sub: Round{Radius: 0.1, Sub: post}}}}); l != 1.5 {
+		t.Errorf("Expected the largest factor in the tree, got %v", l)
+	}
+	nested := Displace{Func: wave, Slope: 1, Sub: Displace{Func: wave, Slope: 0.5, Sub: post}}
+	if l := Lipschitz(nested); l != 3 {
+		t.Errorf("Expected nested factors to multiply, got %v", l)
+	}
+	if l := Lipschitz(Union{post, Repeat{Period: vec3.New(2, 0, 0), Sub: Onion{Thickness: 0.1, Sub: post}}}); l != 1 {
+		t.Errorf("Expected exact nodes to keep a factor of 1, got %v", l)
+	}
+}
+
+func TestDeformationBounds(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	nodes := map[string]Sdf{
+		"round": Round{Radius: 0.3, Sub: post},
+		"onion": Onion{Thickness: 0.2, Sub: post},
+	}
+	for name, s := range deformations() {
+		nodes[name] = s
+	}
+	for name, s := range nodes {
+		min, max, ok := Bounds(s)
+		if !ok {
+			t.Fatalf("%v: expected bounds", name)
+		}
+		// no surface outside the bounds.
+		for i := 0; i < 2000; i++ {
+			p := vec3.New(r.Float32()*16-8, r.Float32()*16-8, r.Float32()*16-8)
+			if p.Clamp(min, max) != p && s.Distance(p) <= 0 {
+				t.Fatalf("%v: %v is inside but outside the bounds %v %v", name, p, min, max)
+			}
+		}
+	}
+	if min, max, _ := Bounds(Round{Radius: 0.5, Sub: post}); !min.ApproxEqual(vec3.New(-1, -0.5, -0.75), 1e-3) || !max.ApproxEqual(vec3.New(1, 2.5, 0.75), 1e-3) {
+		t.Errorf("Expected round to grow the bounds, got %v %v", min, max)
+	}
+	if min, max, _ := Bounds(Taper{Rate: 1, Sub: post}); !min.ApproxEqual(vec3.New(-1.5, 0, -0.75), 1e-3) || !max.ApproxEqual(vec3.New(1.5, 2, 0.75), 1e-3) {
+		t.Errorf("Expected taper to widen the top, got %v %v", min, max)
+	}
+}
+
+func TestDeformations(t *testing.T) {
+	// a quarter turn at the top of a post 2 high.
+	twist := Twist{Axis: vec3.New(0, 2, 0), Rate: math.Pi / 4, Sub: post}
+	if d := twist.Distance(vec3.New(0, 2, 0.5)); abs(d) > 1e-4 {
+		t.Errorf("Expected the long side turned to Z at the top, got %v", d)
+	}
+	if d := twist.Distance(vec3.New(0.5, 0, 0)); abs(d) > 1e-4 {
+		t.Errorf("Expected no turn at the bottom, got %v", d)
+	}
+	// bending by 1 radian per unit turns the point a unit along X by a radian.
+	bend := Bend{Rate: 1, Sub: Sphere{Center: vec3.New(float32(math.Cos(1)), float32(math.Sin(1)), 0), Radius: 0.1}}
+	if d := bend.Distance(vec3.New(1, 0, 0)); abs(d+0.1) > 1e-4 {
+		t.Errorf("Expected the bent sphere center, got %v", d)
+	}
+	taper := Taper{Rate: 0.5, Sub: post}
+	if d := taper.Distance(vec3.New(1, 2, 0)); abs(d) > 1e-4 {
+		t.Errorf("Expected the top twice as wide, got %v", d)
+	}
+	if d := (Round{Radius: 0.25, Sub: post}).Distance(vec3.New(0, 1, 0.5)); abs(d) > 1e-5 {
+		t.Errorf("Expected the rounded surface 0.25 out, got %v", d)
+	}
+	onion := Onion{Thickness: 0.1, Sub: Sphere{Radius: 2}}
+	if d := onion.Distance(vec3.New(0, 0, 0)); abs(d-1.9) > 1e-5 {
+		t.Errorf("Expected a hollow inside, got %v", d)
+	}
+	if d := onion.Distance(vec3.New(2, 0, 0)); abs(d+0.1) > 1e-5 {
+		t.Errorf("Expected inside the shell, got %v", d)
+	}
+	displace := Displace{Func: wave, Amplitude: 0.1, Sub: Sphere{Radius: 1}}
+	if d := displace.Distance(vec3.New(0.3, 0, 0)); abs(d-(-0.7+wave(vec3.New(0.3, 0, 0)))) > 1e-5 {
+		t.Errorf("Expected the function added, got %v", d)
+	}
+}
+
+func TestRaycastDeformed(t *testing.T) {
+	// strongly twisted bars overestimate the distance by a lot.
+	bar := Twist{Axis: vec3.New(1, 0, 0), Rate: 3, Sub: Cube{HalfSize: vec3.New(3, 0.1, 1)}}
+	r := rand.New(rand.NewSource(5))
+	for i := 0; i < 50; i++ {
+		ray := Ray{Origin: vec3.New(r.Float32()*4-2, 3, 0.5), Dir: vec3.New(0, -1, r.Float32()*0.4-0.2).Normalize()}
+		// march in tiny steps for the first crossing.
+		var expected float32 = -1
+		for t := float32(0); t < 6; t += 1e-3 {
+			if bar.Distance(ray.At(t)) < 0 {
+				expected = t
+				break
+			}
+		}
+		settings := DefaultRaycastSettings()
+		settings.MaxSteps = 1000
+		hit, dist, point, _, _ := Raycast(bar, ray, 6, settings)
+		if expected < 0 {
+			continue
+		}
+		// grazing rays stop up to the scaled epsilon early, but never inside.
+		if d := bar.Distance(point); !hit || dist > expected+1e-3 || d < -1e-3 || d > 1e-2 {
+			t.Fatalf("Expected a hit at %v, got %v %v", expected, hit, dist)
+		}
+	}
+}
+
+func TestOptimizeIntersectDeformed(t *testing.T) {
+	twist := Twist{Axis: vec3.New(0, 1, 0), Rate: 2, Sub: Cube{Center: vec3.New(3, 0, 0), HalfSize: vec3.New(0.5, 1, 0.5)}}
+	// the twisted cube reaches round the axis, not only near its center.
+	if result := OptimizeIntersect(twist, Sphere{Center: vec3.New(-3, 0, 0), Radius: 0.5}); !CompareSdfs(result, twist) {
+		t.Errorf("Expected the twisted node to be kept, got %v", result)
+	}
+	if result := OptimizeIntersect(twist, Sphere{Center: vec3.New(0, 12, 0), Radius: 0.5}); !isInfinity(result) {
+		t.Errorf("Expected a region above to drop the node, got %v", result)
+	}
+	// a deformed region is tested conservatively.
+	region := Displace{Func: func(p vec3.Vec3) float32 { return 3 }, Amplitude: 3, Slope: 3, Sub: Sphere{Radius: 4}}
+	if !SphereIntersects(region, &Sphere{Center: vec3.New(2, 0, 0), Radius: 1}) {
+		t.Errorf("Expected the distance scaled down by the Lipschitz factor")
+	}
+}
+
+func TestDeformationSerialize(t *testing.T) {
+	scene := Union{
+		Twist{Center: vec3.New(0, 1, 0), Axis: vec3.New(0, 1, 0), Rate: 1, Sub: post},
+		Bend{Rate: 0.5, Sub: Round{Radius: 0.1, Sub: post}},
+		Taper{Center: vec3.New(1, 0, 0), Rate: 0.2, Sub: Onion{Thickness: 0.05, Sub: Sphere{Radius: 1}}},
+	}
+	text, err := Format(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		t.Fatalf("%v in\n%v", err, text)
+	}
+	if !CompareSdfs(scene, parsed) {
+		t.Errorf("Expected the scene to round trip, got %v", parsed)
+	}
+	if _, err := MarshalJSON(Displace{Func: wave, Sub: post}); err == nil {
+		t.Errorf("Expected displacement functions not to serialize")
+	}
+	if CompareSdfs(Displace{Func: wave, Sub: post}, Displace{Func: math32Sin, Sub: post}) {
+		t.Errorf("Expected different functions to hash differently")
+	}
+	// nodes with the same fields are told apart by their type.
+	if CompareSdfs(Bend{Rate: 0.5, Sub: post}, Taper{Rate: 0.5, Sub: post}) {
+		t.Errorf("Expected bend and taper to hash differently")
+	}
+	if CompareSdfs(Round{Radius: 0.1, Sub: post}, Onion{Thickness: 0.1, Sub: post}) {
+		t.Errorf("Expected round and onion to hash differently")
+	}
+}
+
+func math32Sin(p vec3.Vec3) float32 {
+	return float32(math.Sin(float64(p.X)))
+}
+
+func TestPickDeformed(t *testing.T) {
+	leaf := Sphere{Center: vec3.New(1, 0, 0), Radius: 0.5}
+	scene := Union{Sphere{Center: vec3.New(0, 10, 0), Radius: 1}, Round{Radius: 0.1, Sub: Bend{Rate: 0.3, Sub: leaf}}}
+	hit, ok := Pick(scene, Ray{Origin: vec3.New(1, 0, 5), Dir: vec3.New(0, 0, -1)}, 100, DefaultRaycastSettings())
+	if !ok || hit.Leaf != leaf || len(hit.Path) != 1 || hit.Path[0] != 1 {
+		t.Errorf("Expected to pick the bent sphere, got %+v", hit)
+	}
+}
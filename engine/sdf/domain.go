@@ -0,0 +1,148 @@
+// Domain operators change the point a node is evaluated at, so one child
+// gives many copies at the cost of a single evaluation.
+
+package sdf
+
+import (
+	"hash"
+	"math"
+
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+var (
+	repeatSalt      = []byte{5, 6, 7, 8}
+	mirrorSalt      = []byte{5, 6, 7, 9}
+	polarRepeatSalt = []byte{5, 6, 7, 10}
+)
+
+// wrapper is a node that evaluates a single child at a moved point.
+type wrapper interface {
+	Sdf
+	sub() Sdf
+	domain(p vec3.Vec3) vec3.Vec3
+}
+
+func (s Color) sub() Sdf                     { return s.Sub }
+func (s Color) domain(p vec3.Vec3) vec3.Vec3 { return p }
+
+// Repeat copies Sub on a grid with Period between the copies. An axis with a
+// zero period is not repeated. Limit is the number of copies on each side of
+// the original per axis, zero repeats forever and fractions are dropped. The
+// distance is exact when Sub stays within half a period of the origin.
+type Repeat struct {
+	Period vec3.Vec3
+	Limit  vec3.Vec3
+	Sub    Sdf
+}
+
+// repeatAxis moves x into the cell around the origin.
+func repeatAxis(x, period, limit float32) float32 {
+	if period == 0 {
+		return x
+	}
+	cell := float32(math.Round(float64(x / period)))
+	if limit > 0 {
+		limit = float32(math.Floor(float64(limit)))
+		cell = max(-limit, min(limit, cell))
+	}
+	return x - period*cell
+}
+
+func (s Repeat) sub() Sdf { return s.Sub }
+
+func (s Repeat) domain(p vec3.Vec3) vec3.Vec3 {
+	return vec3.New(
+		repeatAxis(p.X, s.Period.X, s.Limit.X),
+		repeatAxis(p.Y, s.Period.Y, s.Limit.Y),
+		repeatAxis(p.Z, s.Period.Z, s.Limit.Z),
+	)
+}
+
+func (s Repeat) Distance(p vec3.Vec3) float32 {
+	return s.Sub.Distance(s.domain(p))
+}
+
+func (s Repeat) Hash(h hash.Hash) {
+	h.Write(repeatSalt)
+	HashVec3(s.Period, h)
+	HashVec3(s.Limit, h)
+	s.Sub.Hash(h)
+}
+
+// Mirror reflects the half of Sub on the positive side of Center to the
+// negative side, on each axis that is set.
+type Mirror struct {
+	Center  vec3.Vec3
+	X, Y, Z bool
+	Sub     Sdf
+}
+
+func mirrorAxis(x, center float32, mirror bool) float32 {
+	if !mirror {
+		return x
+	}
+	return float32(math.Abs(float64(x-center))) + center
+}
+
+func (s Mirror) sub() Sdf { return s.Sub }
+
+func (s Mirror) domain(p vec3.Vec3) vec3.Vec3 {
+	return vec3.New(
+		mirrorAxis(p.X, s.Center.X, s.X),
+		mirrorAxis(p.Y, s.Center.Y, s.Y),
+		mirrorAxis(p.Z, s.Center.Z, s.Z),
+	)
+}
+
+func (s Mirror) Distance(p vec3.Vec3) float32 {
+	return s.Sub.Distance(s.domain(p))
+}
+
+func (s Mirror) Hash(h hash.Hash) {
+	h.Write(mirrorSalt)
+	HashVec3(s.Center, h)
+	for _, axis := range [3]bool{s.X, s.Y, s.Z} {
+		if axis {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+	}
+	s.Sub.Hash(h)
+}
+
+// PolarRepeat makes Count copies of Sub around the vertical axis through
+// Center. The original is the copy in the +X direction, and should stay
+// within its sector of the circle.
+type PolarRepeat struct {
+	Center vec3.Vec3
+	Count  int
+	Sub    Sdf
+}
+
+func (s PolarRepeat) sub() Sdf { return s.Sub }
+
+func (s PolarRepeat) domain(p vec3.Vec3) vec3.Vec3 {
+	if s.Count <= 1 {
+		return p
+	}
+	x, z := float64(p.X-s.Center.X), float64(p.Z-s.Center.Z)
+	sector := 2 * math.Pi / float64(s.Count)
+	a := math.Atan2(z, x)
+	a -= sector * math.Floor(a/sector+0.5)
+	r := math.Hypot(x, z)
+	sin, cos := math.Sincos(a)
+	return vec3.New(s.Center.X+float32(r*cos), p.Y, s.Center.Z+float32(r*sin))
+}
+
+func (s PolarRepeat) Distance(p vec3.Vec3) float32 {
+	return s.Sub.Distance(s.domain(p))
+}
+
+func (s PolarRepeat) Hash(h hash.Hash) {
+	h.Write(polarRepeatSalt)
+	HashVec3(s.Center, h)
+	HashFloat32(float32(s.Count), h)
+	s.Sub.Hash(h)
+}
@@ -0,0 +1,163 @@
+package sdf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+// compareCopies checks that s has the distance of the union of its copies.
+func compareCopies(t *testing.T, name string, s Sdf, copies Union, extent float32) {
+	t.Helper()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		p := vec3.New(r.Float32()*2-1, r.Float32()*2-1, r.Float32()*2-1).MultiplyScalar(extent)
+		if d, e := s.Distance(p), copies.Distance(p); abs(d-e) > 1e-4 {
+			t.Fatalf("%v: expected %v at %v, got %v", name, e, p, d)
+		}
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	ball := Sphere{Center: vec3.New(0, 0.5, 0), Radius: 0.5}
+	limited := Repeat{Period: vec3.New(2, 0, 3), Limit: vec3.New(2, 0, 1), Sub: ball}
+	var copies Union
+	for x := -2; x <= 2; x++ {
+		for z := -1; z <= 1; z++ {
+			copies = append(copies, Sphere{Center: vec3.New(float32(x)*2, 0.5, float32(z)*3), Radius: 0.5})
+		}
+	}
+	compareCopies(t, "limited", limited, copies, 12)
+	// fractions of a copy are dropped, the outer copies stay on the grid.
+	fractional := Repeat{Period: vec3.New(2, 0, 3), Limit: vec3.New(2.7, 0, 1.5), Sub: ball}
+	compareCopies(t, "fractional", fractional, copies, 12)
+	if min, max, _ := Bounds(fractional); min != vec3.New(-4.5, 0, -3.5) || max != vec3.New(4.5, 1, 3.5) {
+		t.Errorf("Expected the bounds of whole copies, got %v %v", min, max)
+	}
+
+	// an infinite repeat matches the copies near the origin.
+	infinite := Repeat{Period: vec3.New(2, 0, 3), Sub: ball}
+	copies = nil
+	for x := -20; x <= 20; x++ {
+		for z := -20; z <= 20; z++ {
+			copies = append(copies, Sphere{Center: vec3.New(float32(x)*2, 0.5, float32(z)*3), Radius: 0.5})
+		}
+	}
+	compareCopies(t, "infinite", infinite, copies, 20)
+}
+
+func TestMirror(t *testing.T) {
+	ball := Sphere{Center: vec3.New(2, 1, 0.5), Radius: 0.5}
+	mirror := Mirror{Center: vec3.New(1, 0, 0), X: true, Z: true, Sub: ball}
+	copies := Union{
+		ball,
+		Sphere{Center: vec3.New(0, 1, 0.5), Radius: 0.5},
+		Sphere{Center: vec3.New(2, 1, -0.5), Radius: 0.5},
+		Sphere{Center: vec3.New(0, 1, -0.5), Radius: 0.5},
+	}
+	compareCopies(t, "mirror", mirror, copies, 5)
+	if d := (Mirror{Sub: ball}).Distance(vec3.New(-2, 1, 0.5)); abs(d-ball.Distance(vec3.New(-2, 1, 0.5))) > 1e-6 {
+		t.Errorf("Expected no mirroring without axes, got %v", d)
+	}
+}
+
+func TestPolarRepeat(t *testing.T) {
+	center := vec3.New(1, 0, -1)
+	polar := PolarRepeat{Center: center, Count: 6, Sub: Sphere{Center: vec3.New(4, 0.5, -1), Radius: 0.5}}
+	var copies Union
+	for i := 0; i < 6; i++ {
+		s, c := math.Sincos(float64(i) * math.Pi / 3)
+		copies = append(copies, Sphere{Center: center.Add(vec3.New(float32(3*c), 0.5, float32(3*s))), Radius: 0.5})
+	}
+	compareCopies(t, "polar", polar, copies, 6)
+}
+
+func TestDomainBounds(t *testing.T) {
+	ball := Sphere{Center: vec3.New(0, 0, 0), Radius: 1}
+	testcases := []struct {
+		name     string
+		s        Sdf
+		min, max vec3.Vec3
+		ok       bool
+	}{
+		{"limited", Repeat{Period: vec3.New(3, 0, 0), Limit: vec3.New(2, 5, 0), Sub: ball}, vec3.New(-7, -1, -1), vec3.New(7, 1, 1), true},
+		{"infinite", Repeat{Period: vec3.New(3, 0, 3), Limit: vec3.New(2, 0, 0), Sub: ball}, vec3.Vec3{}, vec3.Vec3{}, false},
+		{"mirror", Mirror{Center: vec3.New(-1, 0, 0), X: true, Sub: Sphere{Center: vec3.New(2, 0, 0), Radius: 1}}, vec3.New(-5, -1, -1), vec3.New(3, 1, 1), true},
+		{"polar", PolarRepeat{Count: 4, Sub: Cube{Center: vec3.New(3, 0, 0), HalfSize: vec3.New(1, 2, 1)}}, vec3.New(-4.1231055, -2, -4.1231055), vec3.New(4.1231055, 2, 4.1231055), true},
+		{"union", Union{ball, Infinity{}, Cube{Center: vec3.New(5, 0, 0), HalfSize: vec3.New(1, 1, 1)}}, vec3.New(-1, -1, -1), vec3.New(6, 1, 1), true},
+		{"empty", Union{}, vec3.Vec3{}, vec3.Vec3{}, false},
+	}
+	for _, tc := range testcases {
+		min, max, ok := Bounds(tc.s)
+		if ok != tc.ok || ok && (!min.ApproxEqual(tc.min, 1e-3) || !max.ApproxEqual(tc.max, 1e-3)) {
+			t.Errorf("%v: expected %v %v %v, got %v %v %v", tc.name, tc.min, tc.max, tc.ok, min, max, ok)
+		}
+	}
+}
+
+func TestOptimizeIntersectDomain(t *testing.T) {
+	fence := Repeat{Period: vec3.New(2, 0, 0), Limit: vec3.New(5, 0, 0), Sub: Cube{HalfSize: vec3.New(0.1, 1, 0.1)}}
+	if result := OptimizeIntersect(fence, Sphere{Center: vec3.New(11, 0, 0), Radius: 1}); !CompareSdfs(result, fence) {
+		t.Errorf("Expected the last post to keep the fence, got %v", result)
+	}
+	if result := OptimizeIntersect(fence, Sphere{Center: vec3.New(14, 0, 0), Radius: 1}); !isInfinity(result) {
+		t.Errorf("Expected a region past the fence to drop it, got %v", result)
+	}
+	infinite := Repeat{Period: vec3.New(2, 0, 0), Sub: fence.Sub}
+	if result := OptimizeIntersect(infinite, Sphere{Center: vec3.New(1000, 0, 0), Radius: 1}); !CompareSdfs(result, infinite) {
+		t.Errorf("Expected an infinite repeat to be kept, got %v", result)
+	}
+}
+
+func TestDomainSerialize(t *testing.T) {
+	scene := Union{
+		Repeat{Period: vec3.New(2, 0, 0), Limit: vec3.New(3, 0, 0), Sub: Sphere{Radius: 0.5}},
+		Mirror{Center: vec3.New(1, 0, 0), X: true, Sub: Color{Color: vec3.New(1, 0, 0), Sub: Sphere{Center: vec3.New(2, 0, 0), Radius: 1}}},
+		PolarRepeat{Count: 8, Sub: Cube{Center: vec3.New(3, 0, 0), HalfSize: vec3.New(0.2, 1, 0.2)}},
+	}
+	text, err := Format(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		t.Fatalf("%v in\n%v", err, text)
+	}
+	data, err := MarshalJSON(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CompareSdfs(scene, decoded) {
+		t.Errorf("Expected the scene to round trip, got %v", decoded)
+	}
+	if CompareSdfs(scene[1], Mirror{Center: vec3.New(1, 0, 0), Y: true, Sub: scene[1].(Mirror).Sub}) {
+		t.Errorf("Expected the mirrored axes in the hash")
+	}
+	ball := Sphere{Radius: 1}
+	nodes := []Sdf{Repeat{Sub: ball}, Mirror{Sub: ball}, PolarRepeat{Sub: ball}}
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			if CompareSdfs(nodes[i], nodes[j]) {
+				t.Errorf("Expected %T and %T to hash differently", nodes[i], nodes[j])
+			}
+		}
+	}
+}
+
+func TestPickRepeat(t *testing.T) {
+	post := Cube{HalfSize: vec3.New(0.25, 1, 0.25)}
+	scene := Union{Sphere{Center: vec3.New(0, 10, 0), Radius: 1}, Repeat{Period: vec3.New(2, 0, 0), Sub: post}}
+	hit, ok := Pick(scene, Ray{Origin: vec3.New(6, 0, 5), Dir: vec3.New(0, 0, -1)}, 100, DefaultRaycastSettings())
+	if !ok || hit.Leaf != post || len(hit.Path) != 1 || hit.Path[0] != 1 || abs(hit.Point.Z-0.25) > 1e-3 {
+		t.Errorf("Expected to pick a repeated post, got %+v", hit)
+	}
+	if node, ok := NodeAt(scene, []int{1}); !ok || node != (Repeat{Period: vec3.New(2, 0, 0), Sub: post}) {
+		t.Errorf("Unexpected node %v", node)
+	}
+}
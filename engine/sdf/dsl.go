@@ -0,0 +1,438 @@
+// Text format for Sdf trees.
+//
+// A scene is a single S-expression where each node is written as its
+// registered type name followed by its exported fields in declaration order:
+//
+//	(union
+//	  (color 1 0 0
+//	    (sphere 0 0 0 1))
+//	  (cube 0 0 0 1 1 1))
+//
+// Vectors and arrays are written as their components, slices are written in
+// brackets and nodes that are themselves slices (like union) take their
+// elements directly. Comments start with ';' and run to the end of the line.
+
+package sdf
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError describes a problem in a text scene.
+type SyntaxError struct {
+	Line, Column int
+	Msg          string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v:%v: %v", e.Line, e.Column, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenOpen
+	tokenClose
+	tokenOpenBracket
+	tokenCloseBracket
+	tokenAtom
+	tokenString
+)
+
+type token struct {
+	kind         tokenKind
+	text         string
+	line, column int
+}
+
+type lexer struct {
+	src          []rune
+	pos          int
+	line, column int
+	peeked       *token
+}
+
+func (l *lexer) errorf(t token, format string, args ...any) error {
+	return &SyntaxError{Line: t.line, Column: t.column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) advance() rune {
+	r := l.src[l.pos]
+	l.pos++
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		t, err := l.scan()
+		if err != nil {
+			return t, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+func (l *lexer) next() (token, error) {
+	t, err := l.peek()
+	l.peeked = nil
+	return t, err
+}
+
+func (l *lexer) scan() (token, error) {
+	for l.pos < len(l.src) {
+		r := l.src[l.pos]
+		if unicode.IsSpace(r) {
+			l.advance()
+		} else if r == ';' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance()
+			}
+		} else {
+			break
+		}
+	}
+	t := token{line: l.line, column: l.column}
+	if l.pos >= len(l.src) {
+		t.kind = tokenEOF
+		return t, nil
+	}
+	switch r := l.advance(); r {
+	case '(':
+		t.kind = tokenOpen
+	case ')':
+		t.kind = tokenClose
+	case '[':
+		t.kind = tokenOpenBracket
+	case ']':
+		t.kind = tokenCloseBracket
+	case '"':
+		t.kind = tokenString
+		start := l.pos - 1
+		for {
+			if l.pos >= len(l.src) {
+				return t, l.errorf(t, "unterminated string")
+			}
+			c := l.advance()
+			if c == '\\' && l.pos < len(l.src) {
+				l.advance()
+			} else if c == '"' {
+				break
+			}
+		}
+		s, err := strconv.Unquote(string(l.src[start:l.pos]))
+		if err != nil {
+			return t, l.errorf(t, "invalid string: %v", err)
+		}
+		t.text = s
+	default:
+		t.kind = tokenAtom
+		start := l.pos - 1
+		for l.pos < len(l.src) && !unicode.IsSpace(l.src[l.pos]) && !strings.ContainsRune("()[]\";", l.src[l.pos]) {
+			l.advance()
+		}
+		t.text = string(l.src[start:l.pos])
+	}
+	return t, nil
+}
+
+// Parse reads a scene written in the text format.
+func Parse(src string) (Sdf, error) {
+	l := &lexer{src: []rune(src), line: 1, column: 1}
+	t, err := l.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind != tokenOpen {
+		return nil, l.errorf(t, "expected '(' at the start of the scene")
+	}
+	node, err := l.parseNode()
+	if err != nil {
+		return nil, err
+	}
+	if t, err = l.next(); err != nil {
+		return nil, err
+	}
+	if t.kind != tokenEOF {
+		return nil, l.errorf(t, "unexpected %q after the scene", t.text)
+	}
+	return node, nil
+}
+
+func (l *lexer) parseNode() (Sdf, error) {
+	open, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+	if open.kind != tokenOpen {
+		return nil, l.errorf(open, "expected '('")
+	}
+	head, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+	if head.kind != tokenAtom {
+		return nil, l.errorf(head, "expected node type")
+	}
+	node, err := newNode(head.text)
+	if err != nil {
+		return nil, l.errorf(head, "%v", err)
+	}
+	v := reflect.Indirect(node)
+	if v.Kind() == reflect.Slice {
+		err = l.parseElements(v, tokenClose)
+	} else {
+		err = l.parseValue(v)
+		if err == nil {
+			err = l.expect(tokenClose, "')'")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := node.Interface().(Sdf)
+	if err := validateNode(result); err != nil {
+		return nil, l.errorf(head, "%v", err)
+	}
+	return result, nil
+}
+
+func (l *lexer) expect(kind tokenKind, what string) error {
+	t, err := l.next()
+	if err != nil {
+		return err
+	}
+	if t.kind != kind {
+		return l.errorf(t, "expected %v, got %v", what, describeToken(t))
+	}
+	return nil
+}
+
+func describeToken(t token) string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenOpen:
+		return "'('"
+	case tokenClose:
+		return "')'"
+	case tokenOpenBracket:
+		return "'['"
+	case tokenCloseBracket:
+		return "']'"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// parseElements reads slice elements until the closing token.
+func (l *lexer) parseElements(v reflect.Value, end tokenKind) error {
+	v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	for {
+		t, err := l.peek()
+		if err != nil {
+			return err
+		}
+		if t.kind == end {
+			l.next()
+			return nil
+		}
+		if t.kind == tokenEOF {
+			return l.errorf(t, "unexpected end of input")
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := l.parseValue(elem); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, elem))
+	}
+}
+
+func (l *lexer) parseValue(v reflect.Value) error {
+	if v.Type() == sdfType {
+		t, err := l.peek()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokenAtom && t.text == "nil" {
+			return l.errorf(t, "expected node, got nil")
+		}
+		node, err := l.parseNode()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(node))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := l.parseValue(v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := l.parseValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if err := l.expect(tokenOpenBracket, "'['"); err != nil {
+			return err
+		}
+		return l.parseElements(v, tokenCloseBracket)
+	}
+
+	t, err := l.next()
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if t.kind != tokenString {
+			return l.errorf(t, "expected string, got %v", describeToken(t))
+		}
+		v.SetString(t.text)
+		return nil
+	case reflect.Bool:
+		if t.kind != tokenAtom || (t.text != "true" && t.text != "false") {
+			return l.errorf(t, "expected true or false, got %v", describeToken(t))
+		}
+		v.SetBool(t.text == "true")
+		return nil
+	}
+	if t.kind != tokenAtom {
+		return l.errorf(t, "expected number, got %v", describeToken(t))
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(t.text, v.Type().Bits())
+		if err != nil {
+			return l.errorf(t, "invalid number %q", t.text)
+		}
+		v.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(t.text, 10, v.Type().Bits())
+		if err != nil {
+			return l.errorf(t, "invalid integer %q", t.text)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(t.text, 10, v.Type().Bits())
+		if err != nil {
+			return l.errorf(t, "invalid integer %q", t.text)
+		}
+		v.SetUint(i)
+	default:
+		return l.errorf(t, "cannot parse value of type %v", v.Type())
+	}
+	return nil
+}
+
+// Format writes a scene in the text format.
+func Format(s Sdf) (string, error) {
+	sb := &strings.Builder{}
+	if err := formatNode(sb, s, 0); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func formatNode(sb *strings.Builder, s Sdf, indent int) error {
+	if s == nil {
+		sb.WriteString("nil")
+		return nil
+	}
+	name, err := nodeName(s)
+	if err != nil {
+		return err
+	}
+	sb.WriteString("(")
+	sb.WriteString(name)
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if err := formatValue(sb, v.Index(i), indent+1); err != nil {
+				return err
+			}
+		}
+	} else if err := formatValue(sb, v, indent+1); err != nil {
+		return err
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+func formatValue(sb *strings.Builder, v reflect.Value, indent int) error {
+	if v.Type() == sdfType {
+		sb.WriteString("\n")
+		sb.WriteString(strings.Repeat("  ", indent))
+		if v.IsNil() {
+			return formatNode(sb, nil, indent)
+		}
+		return formatNode(sb, v.Interface().(Sdf), indent)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := formatValue(sb, v.Field(i), indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := formatValue(sb, v.Index(i), indent); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		sb.WriteString(" [")
+		for i := 0; i < v.Len(); i++ {
+			if err := formatValue(sb, v.Index(i), indent+1); err != nil {
+				return err
+			}
+		}
+		sb.WriteString("]")
+		return nil
+	}
+
+	sb.WriteString(" ")
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		sb.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sb.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		sb.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Bool:
+		sb.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.String:
+		sb.WriteString(strconv.Quote(v.String()))
+	default:
+		return fmt.Errorf("sdf: cannot format value of type %v", v.Type())
+	}
+	return nil
+}
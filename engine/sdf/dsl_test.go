@@ -0,0 +1,148 @@
+package sdf
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestParse(t *testing.T) {
+	src := `; two colored spheres
+(union
+  (color 1 0 0 (sphere 0 0 0 1))
+  (color 0 0 1 (sphere 2 0 0 1.5))
+  (cube 0.5 0.5 0.5 0.1 0.2 0.3)
+  (infinity))`
+	expected := Union{
+		Color{Color: vec3.New(1, 0, 0), Sub: Sphere{Center: vec3.New(0, 0, 0), Radius: 1}},
+		Color{Color: vec3.New(0, 0, 1), Sub: Sphere{Center: vec3.New(2, 0, 0), Radius: 1.5}},
+		Cube{Center: vec3.New(0.5, 0.5, 0.5), HalfSize: vec3.New(0.1, 0.2, 0.3)},
+		Infinity{},
+	}
+	result, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CompareSdfs(expected, result) {
+		t.Errorf("Unexpected scene: %v", result)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	text, err := Format(Union{
+		Color{Color: vec3.New(1, 0, 0), Sub: Sphere{Center: vec3.New(0, 0, 0), Radius: 1}},
+		Cube{Center: vec3.New(0.5, 0, 0), HalfSize: vec3.New(1, 1, 1)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "(union\n  (color 1 0 0\n    (sphere 0 0 0 1))\n  (cube 0.5 0 0 1 1 1))"
+	if text != expected {
+		t.Errorf("Unexpected text:\n%v", text)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testcases := []struct {
+		src          string
+		line, column int
+	}{
+		{src: "", line: 1, column: 1},
+		{src: "(sphere 0 0 0)", line: 1, column: 14},
+		{src: "(union\n  (sphere 0 0 x 1))", line: 2, column: 15},
+		{src: "(union\n  (teapot 1))", line: 2, column: 4},
+		{src: "(color 1 0 0\n (sphere 0 0 0 1)", line: 2, column: 18},
+		{src: "(infinity) (infinity)", line: 1, column: 12},
+		{src: "(test-plane 0 1 0 0 \"floor)", line: 1, column: 21},
+		{src: "(color 1 0 0 nil)", line: 1, column: 14},
+		{src: "(union (sphere 0 0 0 1)\n  nil)", line: 2, column: 3},
+		{src: "(brickmap 0 0 0 0.5 1 2 2 2 [5] [])", line: 1, column: 2},
+		{src: "(union\n  (brickmap 0 0 0 0.5 1 1 1 1 [0] []))", line: 2, column: 4},
+	}
+	for i, c := range testcases {
+		_, err := Parse(c.src)
+		var syntax *SyntaxError
+		if !errors.As(err, &syntax) {
+			t.Errorf("case %v: expected syntax error, got %v", i, err)
+			continue
+		}
+		if syntax.Line != c.line || syntax.Column != c.column {
+			t.Errorf("case %v: expected error at %v:%v, got %v", i, c.line, c.column, syntax)
+		}
+	}
+}
+
+func randomScene(r *rand.Rand, depth int) Sdf {
+	rv := func() vec3.Vec3 {
+		return vec3.New(r.Float32()*10-5, r.Float32()*10-5, r.Float32()*10-5)
+	}
+	n := 4
+	if depth > 0 {
+		n = 6
+	}
+	switch r.Intn(n) {
+	case 0:
+		return Sphere{Center: rv(), Radius: r.Float32()}
+	case 1:
+		return Cube{Center: rv(), HalfSize: rv()}
+	case 2:
+		return Infinity{}
+	case 3:
+		return testPlane{Normal: rv(), Offset: r.Float32(), Label: "plane \"q\"\n"}
+	case 4:
+		return Color{Color: rv(), Sub: randomScene(r, depth-1)}
+	default:
+		u := Union{}
+		for i := r.Intn(4); i > 0; i-- {
+			u = append(u, randomScene(r, depth-1))
+		}
+		return u
+	}
+}
+
+func TestFormatParseRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		scene := randomScene(r, 4)
+		text, err := Format(scene)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := Parse(text)
+		if err != nil {
+			t.Fatalf("case %v: %v\n%v", i, err, text)
+		}
+		if !CompareSdfs(scene, parsed) {
+			t.Fatalf("case %v: round trip changed the scene:\n%v", i, text)
+		}
+	}
+}
+
+func FuzzParseFormat(f *testing.F) {
+	f.Add("(union (color 1 0 0 (sphere 0 0 0 1)) (cube 0 0 0 1 1 1))")
+	f.Add("(infinity)")
+	f.Add("(union)")
+	f.Add("(test-plane 0 1 0 -2 \"floor\")")
+	f.Add("(brickmap 0 0 0 0.5 1 1 1 1 [-1] [])")
+	f.Fuzz(func(t *testing.T, src string) {
+		scene, err := Parse(src)
+		if err != nil {
+			return
+		}
+		// parsed scenes are valid, evaluating them must not panic.
+		scene.Distance(vec3.New(0.5, 0.5, 0.5))
+		text, err := Format(scene)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := Parse(text)
+		if err != nil {
+			t.Fatalf("%v\n%v", err, text)
+		}
+		if !CompareSdfs(scene, parsed) {
+			t.Fatalf("round trip changed the scene:\n%v", text)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package sdf
+
+import (
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+// Hit is a raycast hit together with the primitive that was hit.
+type Hit struct {
+	T             float32
+	Point, Normal vec3.Vec3
+	Steps         int
+	// Path is the chain of Union indices from the root to Leaf.
+	Path []int
+	Leaf Sdf
+	// Color is the innermost Color around Leaf, or nil.
+	Color *Color
+}
+
+// Pick casts a ray like Raycast and finds the primitive at the hit.
+func Pick(s Sdf, ray Ray, maxDist float32, settings RaycastSettings) (Hit, bool) {
+	hit, t, point, normal, steps := Raycast(s, ray, maxDist, settings)
+	if !hit {
+		return Hit{T: t, Steps: steps}, false
+	}
+	path, leaf, color := Locate(s, point)
+	return Hit{T: t, Point: point, Normal: normal, Steps: steps, Path: path, Leaf: leaf, Color: color}, true
+}
+
+// Locate follows the closest child of each Union at p down to a primitive,
+// moving p into the domain of the domain operators and deformations on the
+// way. It returns the Union indices, the primitive and the innermost
+// Color around it.
+func Locate(s Sdf, p vec3.Vec3) (path []int, leaf Sdf, color *Color) {
+	for {
+		switch obj := s.(type) {
+		case Union:
+			if len(obj) == 0 {
+				return path, obj, color
+			}
+			closest := 0
+			best := obj[0].Distance(p)
+			for i, sub := range obj[1:] {
+				if d := sub.Distance(p); d < best {
+					closest, best = i+1, d
+				}
+			}
+			path = append(path, closest)
+			s = obj[closest]
+		case Color:
+			c := obj
+			color = &c
+			s = obj.Sub
+		case wrapper:
+			s, p = obj.sub(), obj.domain(p)
+		default:
+			return path, s, color
+		}
+	}
+}
+
+// NodeAt returns the node at a path of Union indices as returned by Locate,
+// looking through the Colors, domain operators and deformations around each
+// Union.
+func NodeAt(s Sdf, path []int) (Sdf, bool) {
+	for _, i := range path {
+		s = unwrap(s)
+		u, ok := s.(Union)
+		if !ok || i < 0 || i >= len(u) {
+			return nil, false
+		}
+		s = u[i]
+	}
+	return s, true
+}
+
+// unwrap returns the first node below s that is not a Color, a domain
+// operator or a deformation.
+func unwrap(s Sdf) Sdf {
+	for {
+		w, ok := s.(wrapper)
+		if !ok {
+			return s
+		}
+		s = w.sub()
+	}
+}
@@ -0,0 +1,48 @@
+package sdf
+
+import (
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestPick(t *testing.T) {
+	red := vec3.New(1, 0, 0)
+	blue := vec3.New(0, 0, 1)
+	target := Sphere{Center: vec3.New(3, 0, -10), Radius: 1}
+	scene := Union{
+		Sphere{Center: vec3.New(-3, 0, -10), Radius: 1},
+		Color{Color: red, Sub: Union{
+			Sphere{Center: vec3.New(0, 5, -10), Radius: 1},
+			Color{Color: blue, Sub: target},
+		}},
+	}
+
+	hit, ok := Pick(scene, Ray{Dir: vec3.New(3, 0, -9).Normalize()}, 100, DefaultRaycastSettings())
+	if !ok {
+		t.Fatalf("Expected a hit")
+	}
+	if len(hit.Path) != 2 || hit.Path[0] != 1 || hit.Path[1] != 1 {
+		t.Errorf("Expected the path [1 1], got %v", hit.Path)
+	}
+	if hit.Leaf != target {
+		t.Errorf("Expected the target sphere, got %v", hit.Leaf)
+	}
+	if hit.Color == nil || hit.Color.Color != blue {
+		t.Errorf("Expected the innermost color, got %v", hit.Color)
+	}
+	if node, ok := NodeAt(scene, hit.Path); !ok || node.(Color).Sub != target {
+		t.Errorf("Expected NodeAt to return the colored target, got %v", node)
+	}
+
+	hit, ok = Pick(scene, Ray{Dir: vec3.New(-3, 0, -9).Normalize()}, 100, DefaultRaycastSettings())
+	if !ok || len(hit.Path) != 1 || hit.Path[0] != 0 || hit.Color != nil {
+		t.Errorf("Expected the uncolored first sphere, got %v", hit)
+	}
+	if _, ok := Pick(scene, Ray{Dir: vec3.New(0, 0, 1)}, 100, DefaultRaycastSettings()); ok {
+		t.Errorf("Expected a miss")
+	}
+	if _, ok := NodeAt(scene, []int{0, 1}); ok {
+		t.Errorf("Expected no node below a sphere")
+	}
+}
@@ -0,0 +1,80 @@
+package sdf
+
+import (
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+// Ray is a half line from Origin along Dir. Dir is expected to be a unit
+// vector so distances along the ray are in world units.
+type Ray struct {
+	Origin vec3.Vec3
+	Dir    vec3.Vec3
+}
+
+// At returns the point at distance t along the ray.
+func (r Ray) At(t float32) vec3.Vec3 {
+	return r.Origin.Add(r.Dir.MultiplyScalar(t))
+}
+
+// RaycastSettings controls when sphere tracing stops.
+type RaycastSettings struct {
+	// MaxSteps limits the work for rays grazing a surface without hitting it.
+	MaxSteps int
+	// Epsilon is the distance that counts as a hit at the ray origin.
+	Epsilon float32
+	// EpsilonScale grows the hit distance with t, so far away hits need the
+	// same relative precision as near ones, like a pixel cone.
+	EpsilonScale float32
+	// StepScale multiplies each step, below 1 for fields that overestimate
+	// the distance.
+	StepScale float32
+	// NormalEpsilon is the offset of the samples used for the normal.
+	NormalEpsilon float32
+}
+
+func DefaultRaycastSettings() RaycastSettings {
+	return RaycastSettings{MaxSteps: 128, Epsilon: 1e-4, EpsilonScale: 1e-4, StepScale: 1, NormalEpsilon: 1e-3}
+}
+
+// Raycast sphere traces s along the ray up to maxDist. On a hit it returns the
+// distance t along the ray, the point and the surface normal. A ray starting
+// inside the surface hits at t = 0. steps is the number of distance
+// evaluations, also on a miss. Steps are divided by the Lipschitz factor of s.
+func Raycast(s Sdf, ray Ray, maxDist float32, settings RaycastSettings) (hit bool, t float32, point, normal vec3.Vec3, steps int) {
+	if settings.MaxSteps <= 0 {
+		settings.MaxSteps = DefaultRaycastSettings().MaxSteps
+	}
+	if settings.StepScale <= 0 {
+		settings.StepScale = 1
+	}
+	// deformed fields can overestimate the distance by up to this factor.
+	lipschitz := Lipschitz(s)
+	for steps < settings.MaxSteps && t <= maxDist {
+		p := ray.At(t)
+		d := s.Distance(p) / lipschitz
+		steps++
+		if d < settings.Epsilon+settings.EpsilonScale*t {
+			return true, t, p, Normal(s, p, settings.NormalEpsilon), steps
+		}
+		// nothing along the ray, Infinity and empty unions.
+		if d >= infinity {
+			break
+		}
+		t += d * settings.StepScale
+	}
+	return false, t, vec3.Vec3{}, vec3.Vec3{}, steps
+}
+
+// Normal estimates the gradient of s at p with four samples on a tetrahedron.
+func Normal(s Sdf, p vec3.Vec3, epsilon float32) vec3.Vec3 {
+	if epsilon <= 0 {
+		epsilon = DefaultRaycastSettings().NormalEpsilon
+	}
+	n := vec3.Vec3{}
+	for _, k := range [4]vec3.Vec3{
+		vec3.New(1, -1, -1), vec3.New(-1, -1, 1), vec3.New(-1, 1, -1), vec3.New(1, 1, 1),
+	} {
+		n = n.Add(k.MultiplyScalar(s.Distance(p.Add(k.MultiplyScalar(epsilon)))))
+	}
+	return n.Normalize()
+}
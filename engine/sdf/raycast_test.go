@@ -0,0 +1,62 @@
+package sdf
+
+import (
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestRaycast(t *testing.T) {
+	settings := DefaultRaycastSettings()
+	sphere := Sphere{Center: vec3.New(0, 0, -10), Radius: 2}
+	scene := Union{sphere, Sphere{Center: vec3.New(5, 0, 0), Radius: 1}}
+
+	hit, dist, point, normal, steps := Raycast(scene, Ray{Origin: vec3.New(0, 0, 0), Dir: vec3.New(0, 0, -1)}, 100, settings)
+	if !hit || abs(dist-8) > 1e-3 || !point.ApproxEqual(vec3.New(0, 0, -8), 1e-3) || !normal.ApproxEqual(vec3.New(0, 0, 1), 1e-3) {
+		t.Errorf("Expected a hit at 8, got %v %v %v %v", hit, dist, point, normal)
+	}
+	if steps < 1 || steps > settings.MaxSteps {
+		t.Errorf("Unexpected step count %v", steps)
+	}
+
+	hit, _, _, normal, _ = Raycast(scene, Ray{Origin: vec3.New(5, 5, 0), Dir: vec3.New(0, -1, 0)}, 100, settings)
+	if !hit || !normal.ApproxEqual(vec3.New(0, 1, 0), 1e-3) {
+		t.Errorf("Expected to hit the top of the second sphere, got %v %v", hit, normal)
+	}
+
+	if hit, _, _, _, _ := Raycast(scene, Ray{Origin: vec3.New(0, 0, 0), Dir: vec3.New(0, 1, 0)}, 100, settings); hit {
+		t.Errorf("Expected a miss looking up")
+	}
+	if hit, _, _, _, _ := Raycast(scene, Ray{Origin: vec3.New(0, 0, 0), Dir: vec3.New(0, 0, -1)}, 5, settings); hit {
+		t.Errorf("Expected a miss beyond maxDist")
+	}
+
+	hit, dist, point, _, _ = Raycast(sphere, Ray{Origin: vec3.New(0, 0, -10), Dir: vec3.New(1, 0, 0)}, 100, settings)
+	if !hit || dist != 0 || point != vec3.New(0, 0, -10) {
+		t.Errorf("Expected a ray starting inside to hit at 0, got %v %v", hit, dist)
+	}
+}
+
+func TestRaycastTermination(t *testing.T) {
+	settings := DefaultRaycastSettings()
+	// an empty scene ends after the first step instead of stepping by infinity.
+	hit, _, _, _, steps := Raycast(Union{}, Ray{Dir: vec3.New(0, 0, -1)}, 100, settings)
+	if hit || steps != 1 {
+		t.Errorf("Expected an immediate miss, got %v after %v steps", hit, steps)
+	}
+	// a ray grazing a sphere takes small steps and stops at MaxSteps.
+	settings.MaxSteps = 10
+	settings.EpsilonScale = 0
+	settings.Epsilon = 1e-7
+	grazing := Ray{Origin: vec3.New(-10, 1.00001, 0), Dir: vec3.New(1, 0, 0)}
+	hit, _, _, _, steps = Raycast(Sphere{Radius: 1}, grazing, 100, settings)
+	if hit || steps != 10 {
+		t.Errorf("Expected to give up after 10 steps, got %v after %v", hit, steps)
+	}
+	// a larger epsilon far away counts the graze as a hit.
+	settings = DefaultRaycastSettings()
+	settings.EpsilonScale = 0.01
+	if hit, _, _, _, _ := Raycast(Sphere{Radius: 1}, grazing, 100, settings); !hit {
+		t.Errorf("Expected the relative epsilon to hit the grazing ray")
+	}
+}
@@ -28,8 +28,10 @@ func Optimize(s *Sdf) Sdf {
 	return *s
 }
 
+// SphereIntersects reports whether sdf may reach into sphere. Deformed
+// distances are divided by their Lipschitz factor to stay conservative.
 func SphereIntersects(sdf Sdf, sphere *Sphere) bool {
-	d0 := sdf.Distance(sphere.Center)
+	d0 := sdf.Distance(sphere.Center) / Lipschitz(sdf)
 	return d0 <= sphere.Radius
 }
 
@@ -85,6 +87,18 @@ func OptimizeIntersect(sdf Sdf, intersect Sdf) Sdf {
 			}
 		}
 		return Infinity{}
+	case Repeat, Mirror, PolarRepeat, Twist, Bend, Taper, Round, Onion, Displace:
+		// the copies and deformed children are not optimized, only the node
+		// as a whole.
+		min, max, ok := Bounds(obj)
+		if !ok {
+			return obj
+		}
+		bounds := Sphere{Center: min.Lerp(max, 0.5), Radius: max.Distance(min) / 2}
+		if SphereIntersects(intersect, &bounds) {
+			return obj
+		}
+		return Infinity{}
 	}
 
 	return sdf
@@ -141,8 +155,9 @@ type Cube struct {
 func (c Cube) Distance(p vec3.Vec3) float32 {
 	d := p.Subtract(c.Center).Abs().Subtract(c.HalfSize)
 
-	// Calculate the distance to the surface of the cube
-	return float32(math.Sqrt(math.Max(0.0, float64(d.DotProduct(d)))))
+	// Outside the distance to the closest point, inside minus the distance
+	// to the closest face.
+	return d.Max(vec3.Vec3{}).Length() + min(max(d.X, d.Y, d.Z), 0)
 }
 
 func (c Cube) Hash(h hash.Hash) {
@@ -189,8 +204,9 @@ type Color struct {
 	Sub   Sdf
 }
 
+// Distance is the distance of the colored node, the color only affects shading.
 func (s Color) Distance(p vec3.Vec3) float32 {
-	return infinity
+	return s.Sub.Distance(p)
 }
 
 func (s Color) Hash(h hash.Hash) {
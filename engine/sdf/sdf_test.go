@@ -49,6 +49,14 @@ func TestUnion(t *testing.T) {
 	fmt.Printf("%v %v\n", d1, d2)
 }
 
+func TestColorDistance(t *testing.T) {
+	sdf := Color{Color: vec3.New(1, 0, 0), Sub: Sphere{Radius: 1.0}}
+	d := sdf.Distance(vec3.New(0, 2, 0))
+	if abs(d-1) > 0.01 {
+		t.Error("Expected 1, got:", d)
+	}
+}
+
 func TestIntersect(t *testing.T) {
 	sdf := Union{
 		Sphere{
@@ -118,3 +126,20 @@ func TestOptimizeIntersect(t *testing.T) {
 	}
 
 }
+
+func TestCubeDistance(t *testing.T) {
+	cube := Cube{Center: vec3.New(1, 0, 0), HalfSize: vec3.New(1, 2, 3)}
+	for _, tc := range []struct {
+		p        vec3.Vec3
+		expected float32
+	}{
+		{vec3.New(4, 0, 0), 2},
+		{vec3.New(5, 5, 0), 4.2426407},
+		{vec3.New(1, 0, 0), -1},
+		{vec3.New(1.5, 1.8, 0), -0.2},
+	} {
+		if d := cube.Distance(tc.p); abs(d-tc.expected) > 1e-5 {
+			t.Errorf("Expected %v at %v, got %v", tc.expected, tc.p, d)
+		}
+	}
+}
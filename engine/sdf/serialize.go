@@ -0,0 +1,638 @@
+package sdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+
+	vec3 "github.com/supersdf-go/engine/vec3"
+)
+
+// FormatVersion is the version written by MarshalJSON and MarshalBinary.
+const FormatVersion = 1
+
+var binaryMagic = []byte("SDF\x00")
+
+var (
+	nodeTypes = map[string]reflect.Type{}
+	nodeNames = map[reflect.Type]string{}
+	sdfType   = reflect.TypeOf((*Sdf)(nil)).Elem()
+	vec3Type  = reflect.TypeOf(vec3.Vec3{})
+)
+
+// UnknownTypeError is returned when decoding a node type that has not been registered.
+type UnknownTypeError struct {
+	Name string
+}
+
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("sdf: unknown node type %q", e.Name)
+}
+
+// UnregisteredTypeError is returned when encoding a node whose type has not been registered.
+type UnregisteredTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnregisteredTypeError) Error() string {
+	return fmt.Sprintf("sdf: node type %v is not registered", e.Type)
+}
+
+// VersionError is returned when decoding data written by an unsupported format version.
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("sdf: unsupported format version %v (supported: %v)", e.Version, FormatVersion)
+}
+
+// InvalidNodeError is returned when decoding a node that would fail when
+// evaluated, like a missing child or a brick map whose tables do not match
+// its size.
+type InvalidNodeError struct {
+	Type reflect.Type
+	Msg  string
+}
+
+func (e *InvalidNodeError) Error() string {
+	return fmt.Sprintf("sdf: invalid %v: %v", e.Type, e.Msg)
+}
+
+// RegisterType makes a node type available to the serializers under name.
+// Exported fields are serialized; fields of type Sdf or []Sdf are serialized recursively.
+func RegisterType(name string, node Sdf) {
+	t := reflect.TypeOf(node)
+	if _, ok := nodeTypes[name]; ok {
+		panic(fmt.Sprintf("sdf: node type %q registered twice", name))
+	}
+	nodeTypes[name] = t
+	nodeNames[t] = name
+}
+
+func init() {
+	RegisterType("sphere", Sphere{})
+	RegisterType("cube", Cube{})
+	RegisterType("union", Union{})
+	RegisterType("infinity", Infinity{})
+	RegisterType("color", Color{})
+	RegisterType("brickmap", &BrickMap{})
+	RegisterType("repeat", Repeat{})
+	RegisterType("mirror", Mirror{})
+	RegisterType("polar-repeat", PolarRepeat{})
+	RegisterType("twist", Twist{})
+	RegisterType("bend", Bend{})
+	RegisterType("taper", Taper{})
+	RegisterType("round", Round{})
+	RegisterType("onion", Onion{})
+}
+
+func nodeName(s Sdf) (string, error) {
+	name, ok := nodeNames[reflect.TypeOf(s)]
+	if !ok {
+		return "", &UnregisteredTypeError{Type: reflect.TypeOf(s)}
+	}
+	return name, nil
+}
+
+func newNode(name string) (reflect.Value, error) {
+	t, ok := nodeTypes[name]
+	if !ok {
+		return reflect.Value{}, &UnknownTypeError{Name: name}
+	}
+	if t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()), nil
+	}
+	return reflect.New(t).Elem(), nil
+}
+
+// validateNode checks a decoded node before it is used. Children of type Sdf
+// must be set, nodes with more rules implement validate.
+func validateNode(s Sdf) error {
+	if s == nil {
+		return &InvalidNodeError{Type: sdfType, Msg: "missing node"}
+	}
+	invalid := func(format string, args ...any) error {
+		return &InvalidNodeError{Type: reflect.TypeOf(s), Msg: fmt.Sprintf(format, args...)}
+	}
+	v := reflect.Indirect(reflect.ValueOf(s))
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem() == sdfType {
+			for i := 0; i < v.Len(); i++ {
+				if v.Index(i).IsNil() {
+					return invalid("child %v is missing", i)
+				}
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() && f.Type == sdfType && v.Field(i).IsNil() {
+				return invalid("%v is missing", fieldKey(f))
+			}
+		}
+	}
+	if n, ok := s.(interface{ validate() error }); ok {
+		if err := n.validate(); err != nil {
+			return invalid("%v", err)
+		}
+	}
+	return nil
+}
+
+// fieldKey is the JSON key of a struct field: the json tag if present, otherwise the lower camel case name.
+func fieldKey(f reflect.StructField) string {
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		return strings.Split(tag, ",")[0]
+	}
+	return strings.ToLower(f.Name[:1]) + f.Name[1:]
+}
+
+type jsonDocument struct {
+	Version int             `json:"version"`
+	Root    json.RawMessage `json:"root"`
+}
+
+// MarshalJSON encodes an Sdf tree as versioned JSON.
+func MarshalJSON(s Sdf) ([]byte, error) {
+	root, err := encodeJSONNode(s)
+	if err != nil {
+		return nil, err
+	}
+	rootBytes, err := json.Marshal(root)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonDocument{Version: FormatVersion, Root: rootBytes})
+}
+
+// UnmarshalJSON decodes an Sdf tree written by MarshalJSON.
+func UnmarshalJSON(data []byte) (Sdf, error) {
+	var doc jsonDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Version != FormatVersion {
+		return nil, &VersionError{Version: doc.Version}
+	}
+	var root any
+	if err := json.Unmarshal(doc.Root, &root); err != nil {
+		return nil, err
+	}
+	node, err := decodeJSONNode(root)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, validateNode(nil)
+	}
+	return node, nil
+}
+
+func encodeJSONNode(s Sdf) (any, error) {
+	if s == nil {
+		return nil, nil
+	}
+	name, err := nodeName(s)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.Indirect(reflect.ValueOf(s))
+	out := map[string]any{"type": name}
+	if v.Kind() != reflect.Struct {
+		value, err := encodeJSONValue(v)
+		if err != nil {
+			return nil, err
+		}
+		out["value"] = value
+		return out, nil
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		value, err := encodeJSONValue(v.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		out[fieldKey(f)] = value
+	}
+	return out, nil
+}
+
+func encodeJSONValue(v reflect.Value) (any, error) {
+	if v.Type() == sdfType {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return encodeJSONNode(v.Interface().(Sdf))
+	}
+	if v.Type() == vec3Type {
+		vec := v.Interface().(vec3.Vec3)
+		return []float32{vec.X, vec.Y, vec.Z}, nil
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]any, v.Len())
+		for i := range items {
+			item, err := encodeJSONValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Struct:
+		out := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			value, err := encodeJSONValue(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			out[fieldKey(f)] = value
+		}
+		return out, nil
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Bool, reflect.String:
+		return v.Interface(), nil
+	}
+	return nil, fmt.Errorf("sdf: cannot serialize value of type %v", v.Type())
+}
+
+func decodeJSONNode(data any) (Sdf, error) {
+	if data == nil {
+		return nil, nil
+	}
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("sdf: expected node object, got %T", data)
+	}
+	name, ok := obj["type"].(string)
+	if !ok {
+		return nil, errors.New("sdf: node is missing its type")
+	}
+	node, err := newNode(name)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.Indirect(node)
+	if v.Kind() != reflect.Struct {
+		if err := decodeJSONValue(obj["value"], v); err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+	} else if err := decodeJSONFields(obj, v); err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+	result := node.Interface().(Sdf)
+	if err := validateNode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func decodeJSONFields(obj map[string]any, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		value, ok := obj[fieldKey(f)]
+		if !ok {
+			continue
+		}
+		if err := decodeJSONValue(value, v.Field(i)); err != nil {
+			return fmt.Errorf("%v: %w", fieldKey(f), err)
+		}
+	}
+	return nil
+}
+
+func decodeJSONValue(data any, v reflect.Value) error {
+	if v.Type() == sdfType {
+		node, err := decodeJSONNode(data)
+		if err != nil {
+			return err
+		}
+		if node != nil {
+			v.Set(reflect.ValueOf(node))
+		}
+		return nil
+	}
+	if v.Type() == vec3Type {
+		items, ok := data.([]any)
+		if !ok || len(items) != 3 {
+			return fmt.Errorf("expected [x, y, z], got %v", data)
+		}
+		var f [3]float64
+		for i, item := range items {
+			if f[i], ok = item.(float64); !ok {
+				return fmt.Errorf("expected number, got %v", item)
+			}
+		}
+		v.Set(reflect.ValueOf(vec3.New(float32(f[0]), float32(f[1]), float32(f[2]))))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		items, ok := data.([]any)
+		if !ok {
+			if data == nil && v.Kind() == reflect.Slice {
+				return nil
+			}
+			return fmt.Errorf("expected array, got %T", data)
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(items), len(items)))
+		} else if len(items) != v.Len() {
+			return fmt.Errorf("expected %v items, got %v", v.Len(), len(items))
+		}
+		for i, item := range items {
+			if err := decodeJSONValue(item, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		obj, ok := data.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected object, got %T", data)
+		}
+		return decodeJSONFields(obj, v)
+	case reflect.Float32, reflect.Float64:
+		f, ok := data.(float64)
+		if !ok {
+			return fmt.Errorf("expected number, got %v", data)
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := data.(float64)
+		if !ok {
+			return fmt.Errorf("expected number, got %v", data)
+		}
+		v.SetInt(int64(f))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := data.(float64)
+		if !ok || f < 0 {
+			return fmt.Errorf("expected unsigned number, got %v", data)
+		}
+		v.SetUint(uint64(f))
+		return nil
+	case reflect.Bool:
+		b, ok := data.(bool)
+		if !ok {
+			return fmt.Errorf("expected bool, got %v", data)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := data.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %v", data)
+		}
+		v.SetString(s)
+		return nil
+	}
+	return fmt.Errorf("cannot deserialize value of type %v", v.Type())
+}
+
+// MarshalBinary encodes an Sdf tree in the compact binary format.
+// The stream starts with a magic number and the format version, followed by
+// the root node. Nodes are written as their type name followed by the
+// exported fields in declaration order.
+func MarshalBinary(s Sdf) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.Write(binaryMagic)
+	writeUvarint(buf, FormatVersion)
+	if err := writeBinaryNode(buf, s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes an Sdf tree written by MarshalBinary.
+func UnmarshalBinary(data []byte) (Sdf, error) {
+	if !bytes.HasPrefix(data, binaryMagic) {
+		return nil, errors.New("sdf: not a binary sdf stream")
+	}
+	r := bytes.NewReader(data[len(binaryMagic):])
+	version, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if version != FormatVersion {
+		return nil, &VersionError{Version: int(version)}
+	}
+	node, err := readBinaryNode(r)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, validateNode(nil)
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("sdf: %v bytes after the root node", r.Len())
+	}
+	return node, nil
+}
+
+func writeUvarint(w *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeVarint(w *bytes.Buffer, v int64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+func writeBinaryNode(w *bytes.Buffer, s Sdf) error {
+	if s == nil {
+		writeUvarint(w, 0)
+		return nil
+	}
+	name, err := nodeName(s)
+	if err != nil {
+		return err
+	}
+	writeUvarint(w, uint64(len(name)))
+	w.WriteString(name)
+	return writeBinaryValue(w, reflect.Indirect(reflect.ValueOf(s)))
+}
+
+func writeBinaryValue(w *bytes.Buffer, v reflect.Value) error {
+	if v.Type() == sdfType {
+		if v.IsNil() {
+			return writeBinaryNode(w, nil)
+		}
+		return writeBinaryNode(w, v.Interface().(Sdf))
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		writeUvarint(w, uint64(v.Len()))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := writeBinaryValue(w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := writeBinaryValue(w, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Float32:
+		binary.Write(w, binary.LittleEndian, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		binary.Write(w, binary.LittleEndian, math.Float64bits(v.Float()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeVarint(w, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		writeUvarint(w, v.Uint())
+	case reflect.Bool:
+		if v.Bool() {
+			w.WriteByte(1)
+		} else {
+			w.WriteByte(0)
+		}
+	case reflect.String:
+		writeUvarint(w, uint64(v.Len()))
+		w.WriteString(v.String())
+	default:
+		return fmt.Errorf("sdf: cannot serialize value of type %v", v.Type())
+	}
+	return nil
+}
+
+func readBinaryNode(r *bytes.Reader) (Sdf, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	nameBytes := make([]byte, n)
+	if _, err := io.ReadFull(r, nameBytes); err != nil {
+		return nil, err
+	}
+	node, err := newNode(string(nameBytes))
+	if err != nil {
+		return nil, err
+	}
+	if err := readBinaryValue(r, reflect.Indirect(node)); err != nil {
+		return nil, fmt.Errorf("%s: %w", nameBytes, err)
+	}
+	result := node.Interface().(Sdf)
+	if err := validateNode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func readBinaryValue(r *bytes.Reader, v reflect.Value) error {
+	if v.Type() == sdfType {
+		node, err := readBinaryNode(r)
+		if err != nil {
+			return err
+		}
+		if node != nil {
+			v.Set(reflect.ValueOf(node))
+		}
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		// every element takes at least one byte, this guards against corrupt lengths.
+		if n > uint64(r.Len()) {
+			return io.ErrUnexpectedEOF
+		}
+		v.Set(reflect.MakeSlice(v.Type(), int(n), int(n)))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := readBinaryValue(r, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := readBinaryValue(r, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Float32:
+		var bits uint32
+		if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+			return err
+		}
+		v.SetFloat(float64(math.Float32frombits(bits)))
+	case reflect.Float64:
+		var bits uint64
+		if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+			return err
+		}
+		v.SetFloat(math.Float64frombits(bits))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := binary.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Bool:
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		v.SetBool(b != 0)
+	case reflect.String:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if n > uint64(r.Len()) {
+			return io.ErrUnexpectedEOF
+		}
+		s := make([]byte, n)
+		if _, err := io.ReadFull(r, s); err != nil {
+			return err
+		}
+		v.SetString(string(s))
+	default:
+		return fmt.Errorf("cannot deserialize value of type %v", v.Type())
+	}
+	return nil
+}
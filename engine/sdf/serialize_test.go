@@ -0,0 +1,158 @@
+package sdf
+
+import (
+	"errors"
+	"hash"
+	"strings"
+	"testing"
+
+	"github.com/supersdf-go/engine/vec3"
+)
+
+type testPlane struct {
+	Normal vec3.Vec3
+	Offset float32
+	Label  string
+}
+
+func (p testPlane) Distance(v vec3.Vec3) float32 {
+	return p.Normal.DotProduct(v) - p.Offset
+}
+
+func (p testPlane) Hash(h hash.Hash) {
+	HashVec3(p.Normal, h)
+	HashFloat32(p.Offset, h)
+	h.Write([]byte(p.Label))
+}
+
+func init() {
+	RegisterType("test-plane", testPlane{})
+}
+
+func serializeTestScenes() []Sdf {
+	return []Sdf{
+		Sphere{Center: vec3.New(1, 2, 3), Radius: 0.5},
+		Infinity{},
+		Union{},
+		Union{
+			Color{Color: vec3.New(1, 0, 0), Sub: Sphere{Center: vec3.New(0, 0, 0), Radius: 1}},
+			Cube{Center: vec3.New(0.5, 0.5, 0.5), HalfSize: vec3.New(0.1, 0.2, 0.3)},
+			Union{Infinity{}, testPlane{Normal: vec3.New(0, 1, 0), Offset: -2, Label: "floor"}},
+		},
+		BakeBrickMap(Sphere{Center: vec3.New(0, 0, 0), Radius: 1},
+			vec3.New(-2, -2, -2), vec3.New(2, 2, 2), 0.25, 0.5),
+	}
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	for i, scene := range serializeTestScenes() {
+		js, err := MarshalJSON(scene)
+		if err != nil {
+			t.Fatalf("case %v: %v", i, err)
+		}
+		fromJson, err := UnmarshalJSON(js)
+		if err != nil {
+			t.Fatalf("case %v: %v", i, err)
+		}
+		if !CompareSdfs(scene, fromJson) {
+			t.Errorf("case %v: JSON round trip changed the scene: %s", i, js)
+		}
+
+		bin, err := MarshalBinary(scene)
+		if err != nil {
+			t.Fatalf("case %v: %v", i, err)
+		}
+		fromBin, err := UnmarshalBinary(bin)
+		if err != nil {
+			t.Fatalf("case %v: %v", i, err)
+		}
+		if !CompareSdfs(scene, fromBin) {
+			t.Errorf("case %v: binary round trip changed the scene", i)
+		}
+		if len(bin) > len(js) {
+			t.Errorf("case %v: binary (%v bytes) larger than JSON (%v bytes)", i, len(bin), len(js))
+		}
+	}
+}
+
+func TestSerializeJSONFormat(t *testing.T) {
+	js, err := MarshalJSON(Color{Color: vec3.New(1, 0, 0), Sub: Sphere{Center: vec3.New(0, 0, 0), Radius: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"version":1,"root":{"color":[1,0,0],"sub":{"center":[0,0,0],"radius":1,"type":"sphere"},"type":"color"}}`
+	if string(js) != expected {
+		t.Errorf("Unexpected JSON: %s", js)
+	}
+}
+
+type unregistered struct{ Sphere }
+
+func TestSerializeErrors(t *testing.T) {
+	var unknown *UnknownTypeError
+	_, err := UnmarshalJSON([]byte(`{"version":1,"root":{"type":"union","value":[{"type":"teapot"}]}}`))
+	if !errors.As(err, &unknown) || unknown.Name != "teapot" {
+		t.Errorf("Expected unknown type error, got %v", err)
+	}
+
+	bin, _ := MarshalBinary(Sphere{})
+	bin = []byte(strings.Replace(string(bin), "sphere", "sphera", 1))
+	_, err = UnmarshalBinary(bin)
+	if !errors.As(err, &unknown) || unknown.Name != "sphera" {
+		t.Errorf("Expected unknown type error, got %v", err)
+	}
+
+	var version *VersionError
+	_, err = UnmarshalJSON([]byte(`{"version":99,"root":{"type":"infinity"}}`))
+	if !errors.As(err, &version) {
+		t.Errorf("Expected version error, got %v", err)
+	}
+
+	var unreg *UnregisteredTypeError
+	_, err = MarshalJSON(Union{unregistered{}})
+	if !errors.As(err, &unreg) {
+		t.Errorf("Expected unregistered type error, got %v", err)
+	}
+	_, err = MarshalBinary(Union{unregistered{}})
+	if !errors.As(err, &unreg) {
+		t.Errorf("Expected unregistered type error, got %v", err)
+	}
+
+	var invalid *InvalidNodeError
+	for _, src := range []string{
+		`{"version":1,"root":null}`,
+		`{"version":1,"root":{"type":"color","color":[1,0,0]}}`,
+		`{"version":1,"root":{"type":"union","value":[{"type":"infinity"},null]}}`,
+		`{"version":1,"root":{"type":"twist","axis":[0,1,0],"rate":1}}`,
+		`{"version":1,"root":{"type":"brickmap","voxelSize":0.5,"band":1,"dims":[2,2,2],"indirection":[5],"bricks":[]}}`,
+		`{"version":1,"root":{"type":"brickmap","voxelSize":0.5,"band":1,"dims":[1,1,1],"indirection":[0],"bricks":[]}}`,
+	} {
+		if _, err := UnmarshalJSON([]byte(src)); !errors.As(err, &invalid) {
+			t.Errorf("Expected an invalid node error for %v, got %v", src, err)
+		}
+	}
+	for _, scene := range []Sdf{
+		Color{},
+		Union{Sphere{}, nil},
+		&BrickMap{VoxelSize: 1, Dims: [3]int{2, 2, 2}, Indirection: []int32{5}},
+	} {
+		bin, err := MarshalBinary(scene)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := UnmarshalBinary(bin); !errors.As(err, &invalid) {
+			t.Errorf("Expected an invalid node error for %#v, got %v", scene, err)
+		}
+	}
+	bin, _ = MarshalBinary(Sphere{})
+	if _, err := UnmarshalBinary(append(bin, 0)); err == nil {
+		t.Errorf("Expected an error for trailing bytes")
+	}
+
+	full, _ := MarshalBinary(serializeTestScenes()[3])
+	for i := len(binaryMagic); i < len(full); i++ {
+		if _, err := UnmarshalBinary(full[:i]); err == nil {
+			t.Fatalf("Expected error for truncated stream of %v bytes", i)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strings"
 
 	sdf "github.com/supersdf-go/engine/sdf"
@@ -22,6 +24,8 @@ var (
 			return length(p - c) -r;
 		}
 
+		// SDF_FUNCTIONS
+
 		void sdf(vec3 p, inout float outdist, inout vec4 outcolor){ 
 			vec4 color = vec4(1,1,1,1);
 			float d = 100000.0;
@@ -53,40 +57,223 @@ var (
 	` + "\x00"
 )
 
-func SDF2GLSL_inner(sdfObj sdf.Sdf, output *string) {
+// UnsupportedSdfError is returned for nodes that have no GLSL translation.
+type UnsupportedSdfError struct {
+	Node sdf.Sdf
+}
+
+func (e UnsupportedSdfError) Error() string {
+	return fmt.Sprintf("unsupported sdf node for GLSL: %T", e.Node)
+}
+
+func SDF2GLSL_inner(sdfObj sdf.Sdf, output *string) error {
 
 	switch obj := sdfObj.(type) {
 	case sdf.Sphere:
 
 		*output = fmt.Sprintf("%v\nd = sphere(p,vec3(%v, %v, %v), %v);", *output, obj.Center.X, obj.Center.Y, obj.Center.Z, obj.Radius)
+	case *sdf.BrickMap:
+		*output = fmt.Sprintf("%v\nd = brickmap(p);", *output)
 	case sdf.Color:
 		*output = fmt.Sprintf("%v\ncolor = vec4(%v, %v, %v, 1);", *output, obj.Color.X, obj.Color.Y, obj.Color.Z)
-		SDF2GLSL_inner(obj.Sub, output)
+		return SDF2GLSL_inner(obj.Sub, output)
 	case sdf.Union:
 		if len(obj) == 0 {
-			return
+			return nil
 		}
-		if len(obj) == 1 {
-			SDF2GLSL_inner(obj[0], output)
+		if err := SDF2GLSL_inner(obj[0], output); err != nil {
+			return err
 		}
-		SDF2GLSL_inner(obj[0], output)
 
 		for i := 1; i < len(obj); i++ {
 			inner := ""
-			SDF2GLSL_inner(obj[i], &inner)
+			if err := SDF2GLSL_inner(obj[i], &inner); err != nil {
+				return err
+			}
 			*output = fmt.Sprintf("%v{float d2 = d;vec4 color2 = color;  %v if(d > d2){d = d2; color = color2;}}", *output, inner)
 
 		}
+	case sdf.Repeat:
+		transform := ""
+		for _, axis := range []struct {
+			name          string
+			period, limit float32
+		}{{"x", obj.Period.X, obj.Limit.X}, {"y", obj.Period.Y, obj.Limit.Y}, {"z", obj.Period.Z, obj.Limit.Z}} {
+			if axis.period == 0 {
+				continue
+			}
+			if axis.limit > 0 {
+				limit := float32(math.Floor(float64(axis.limit)))
+				transform += fmt.Sprintf("p.%v -= %v * clamp(round(p.%v / %v), %v, %v);", axis.name, axis.period, axis.name, axis.period, -limit, limit)
+			} else {
+				transform += fmt.Sprintf("p.%v -= %v * round(p.%v / %v);", axis.name, axis.period, axis.name, axis.period)
+			}
+		}
+		return domainGLSL(transform, obj.Sub, "", output)
+	case sdf.Mirror:
+		transform := ""
+		for _, axis := range []struct {
+			name   string
+			center float32
+			mirror bool
+		}{{"x", obj.Center.X, obj.X}, {"y", obj.Center.Y, obj.Y}, {"z", obj.Center.Z, obj.Z}} {
+			if axis.mirror {
+				transform += fmt.Sprintf("p.%v = abs(p.%v - %v) + %v;", axis.name, axis.name, axis.center, axis.center)
+			}
+		}
+		return domainGLSL(transform, obj.Sub, "", output)
+	case sdf.PolarRepeat:
+		transform := ""
+		if obj.Count > 1 {
+			transform = fmt.Sprintf(`{vec2 c = vec2(%v, %v); vec2 q = p.xz - c; float sector = 6.28318530718 / %v.0;
+float a = atan(q.y, q.x); a -= sector * floor(a / sector + 0.5); p.xz = c + length(q) * vec2(cos(a), sin(a));}`,
+				obj.Center.X, obj.Center.Z, obj.Count)
+		}
+		return domainGLSL(transform, obj.Sub, "", output)
+	case sdf.Twist:
+		axis := obj.Axis.Normalize()
+		transform := fmt.Sprintf(`{vec3 c = vec3(%v, %v, %v); vec3 axis = vec3(%v, %v, %v); vec3 q = p - c; float along = dot(q, axis);
+float a = %v * along; p = c + q * cos(a) + cross(axis, q) * sin(a) + axis * along * (1.0 - cos(a));}`,
+			obj.Center.X, obj.Center.Y, obj.Center.Z, axis.X, axis.Y, axis.Z, -obj.Rate)
+		return domainGLSL(transform, obj.Sub, "", output)
+	case sdf.Bend:
+		transform := fmt.Sprintf(`{vec2 c = vec2(%v, %v); vec2 q = p.xy - c; float a = %v * q.x;
+p.xy = c + vec2(cos(a) * q.x - sin(a) * q.y, sin(a) * q.x + cos(a) * q.y);}`,
+			obj.Center.X, obj.Center.Y, obj.Rate)
+		return domainGLSL(transform, obj.Sub, "", output)
+	case sdf.Taper:
+		transform := fmt.Sprintf("{vec2 c = vec2(%v, %v); p.xz = c + (p.xz - c) / max(%v, 1.0 + %v * (p.y - %v));}",
+			obj.Center.X, obj.Center.Z, float32(sdf.MinTaper), obj.Rate, obj.Center.Y)
+		return domainGLSL(transform, obj.Sub, "", output)
+	case sdf.Round:
+		return domainGLSL("", obj.Sub, fmt.Sprintf("d -= %v;", obj.Radius), output)
+	case sdf.Onion:
+		return domainGLSL("", obj.Sub, fmt.Sprintf("d = abs(d) - %v;", obj.Thickness), output)
 	default:
-		panic(fmt.Sprintf("Unsupported type: %v", obj))
+		return UnsupportedSdfError{Node: obj}
+	}
+	return nil
+}
+
+// domainGLSL evaluates sub at the point moved by transform, restores p and
+// then changes the distance with post.
+func domainGLSL(transform string, sub sdf.Sdf, post string, output *string) error {
+	inner := ""
+	if err := SDF2GLSL_inner(sub, &inner); err != nil {
+		return err
+	}
+	*output = fmt.Sprintf("%v\n{vec3 pSaved = p; %v %v\np = pSaved; %v}", *output, transform, inner, post)
+	return nil
+}
+
+// BrickMapGLSL emits the uniforms and the brickmap(p) sampling function for a brick map.
+// The indirection and atlas textures are bound to the brickIndirection and brickAtlas samplers.
+func BrickMapGLSL(b *sdf.BrickMap) string {
+	layout := b.GPULayout()
+	return fmt.Sprintf(`
+		uniform isampler3D brickIndirection;
+		uniform sampler3D brickAtlas;
+		const vec3 brickMapMin = vec3(%v, %v, %v);
+		const float brickMapVoxel = %v;
+		const float brickMapBand = %v;
+		const ivec3 brickMapDims = ivec3(%v, %v, %v);
+		const ivec3 brickAtlasBricks = ivec3(%v, %v, %v);
+
+		float brickVoxel(ivec3 v){
+			if(any(lessThan(v, ivec3(0)))) return brickMapBand;
+			ivec3 b = v / %v;
+			if(any(greaterThanEqual(b, brickMapDims))) return brickMapBand;
+			int idx = texelFetch(brickIndirection, b, 0).r;
+			if(idx == %v) return brickMapBand;
+			if(idx == %v) return -brickMapBand;
+			ivec3 a = ivec3(idx %% brickAtlasBricks.x, (idx / brickAtlasBricks.x) %% brickAtlasBricks.y, idx / (brickAtlasBricks.x * brickAtlasBricks.y));
+			return texelFetch(brickAtlas, a * %v + (v - b * %v), 0).r;
+		}
+
+		float brickmap(vec3 p){
+			vec3 g = (p - brickMapMin) / brickMapVoxel;
+			vec3 fl = floor(g);
+			ivec3 i = ivec3(fl);
+			vec3 t = g - fl;
+			float c00 = mix(brickVoxel(i), brickVoxel(i + ivec3(1, 0, 0)), t.x);
+			float c10 = mix(brickVoxel(i + ivec3(0, 1, 0)), brickVoxel(i + ivec3(1, 1, 0)), t.x);
+			float c01 = mix(brickVoxel(i + ivec3(0, 0, 1)), brickVoxel(i + ivec3(1, 0, 1)), t.x);
+			float c11 = mix(brickVoxel(i + ivec3(0, 1, 1)), brickVoxel(i + ivec3(1, 1, 1)), t.x);
+			return mix(mix(c00, c10, t.y), mix(c01, c11, t.y), t.z);
+		}
+`, b.Min.X, b.Min.Y, b.Min.Z, b.VoxelSize, b.Band,
+		layout.IndirectionDims[0], layout.IndirectionDims[1], layout.IndirectionDims[2],
+		layout.AtlasBricks[0], layout.AtlasBricks[1], layout.AtlasBricks[2],
+		sdf.BrickSize, sdf.BrickEmptyOutside, sdf.BrickEmptyInside, sdf.BrickSize, sdf.BrickSize)
+}
+
+// ErrMultipleBrickMaps is returned for scenes with more than one brick map,
+// the shader samples a single pair of brick map textures.
+var ErrMultipleBrickMaps = errors.New("glsl: only one brick map per scene is supported")
+
+// findBrickMaps appends the brick maps in the tree to maps.
+func findBrickMaps(sdfObj sdf.Sdf, maps []*sdf.BrickMap) []*sdf.BrickMap {
+	switch obj := sdfObj.(type) {
+	case *sdf.BrickMap:
+		return append(maps, obj)
+	case sdf.Color:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.Repeat:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.Mirror:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.PolarRepeat:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.Twist:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.Bend:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.Taper:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.Round:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.Onion:
+		return findBrickMaps(obj.Sub, maps)
+	case sdf.Union:
+		for _, sub := range obj {
+			maps = findBrickMaps(sub, maps)
+		}
+	}
+	return maps
+}
+
+// findBrickMap returns the brick map of the tree, or nil if it has none.
+func findBrickMap(sdfObj sdf.Sdf) (*sdf.BrickMap, error) {
+	maps := findBrickMaps(sdfObj, nil)
+	switch len(maps) {
+	case 0:
+		return nil, nil
+	case 1:
+		return maps[0], nil
 	}
+	return nil, ErrMultipleBrickMaps
 }
 
-func SDF2GLSL(sdfObj sdf.Sdf) string {
+func SDF2GLSL(sdfObj sdf.Sdf) (string, error) {
 	base := sdffragmentShaderSource
 	result := ""
-	SDF2GLSL_inner(sdfObj, &result)
+	if err := SDF2GLSL_inner(sdfObj, &result); err != nil {
+		return "", err
+	}
+	// the march steps by the distance, deformed distances are scaled down to not overshoot.
+	if l := sdf.Lipschitz(sdfObj); l > 1 {
+		result = fmt.Sprintf("%v\nd = d / %v;", result, l)
+	}
+	b, err := findBrickMap(sdfObj)
+	if err != nil {
+		return "", err
+	}
+	functions := ""
+	if b != nil {
+		functions = BrickMapGLSL(b)
+	}
+	base = strings.Replace(base, "// SDF_FUNCTIONS", functions, 1)
 	result2 := strings.Replace(base, "// SDF_INNER", result, 1)
 	result2 = strings.Replace(result2, "// SDF_COLOR_INNER", result, 1)
-	return result2
+	return result2, nil
 }
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/supersdf-go/engine/sdf"
@@ -17,6 +18,104 @@ func TestSdf2Glsl(t *testing.T) {
 			Sub:   sdf.Sphere{Center: vec3.New(1, 3, 0), Radius: 1.5}},
 	}
 
-	glsl := SDF2GLSL(sdf0)
+	glsl, err := SDF2GLSL(sdf0)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Printf("glsl: %v\n", glsl)
 }
+
+func TestSdf2GlslUnsupported(t *testing.T) {
+	_, err := SDF2GLSL(sdf.Union{sdf.Sphere{Radius: 1}, sdf.Color{Sub: sdf.Cube{}}})
+	if _, ok := err.(UnsupportedSdfError); !ok {
+		t.Errorf("Expected an unsupported node error, got %v", err)
+	}
+}
+
+func TestBrickMap2Glsl(t *testing.T) {
+	b := sdf.BakeBrickMap(sdf.Sphere{Center: vec3.New(0, 0, 0), Radius: 1},
+		vec3.New(-2, -2, -2), vec3.New(2, 2, 2), 0.1, 0.3)
+	glsl, err := SDF2GLSL(sdf.Union{b, sdf.Sphere{Center: vec3.New(3, 0, 0), Radius: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(glsl, "float brickmap(vec3 p)") {
+		t.Error("Expected the brick map sampling function")
+	}
+	if !strings.Contains(glsl, "d = brickmap(p);") {
+		t.Error("Expected the brick map to be sampled")
+	}
+	other := sdf.BakeBrickMap(sdf.Sphere{Center: vec3.New(4, 0, 0), Radius: 1},
+		vec3.New(2, -2, -2), vec3.New(6, 2, 2), 0.1, 0.3)
+	if _, err := SDF2GLSL(sdf.Union{b, sdf.Color{Sub: other}}); err != ErrMultipleBrickMaps {
+		t.Errorf("Expected a second brick map to be rejected, got %v", err)
+	}
+}
+
+func TestDomain2Glsl(t *testing.T) {
+	ball := sdf.Sphere{Center: vec3.New(1, 0, 0), Radius: 0.5}
+	glsl, err := SDF2GLSL(sdf.Union{
+		sdf.Repeat{Period: vec3.New(2, 0, 3), Limit: vec3.New(4.5, 0, 0), Sub: ball},
+		sdf.Mirror{Center: vec3.New(0, 1, 0), Y: true, Sub: ball},
+		sdf.PolarRepeat{Count: 6, Sub: ball},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"p.x -= 2 * clamp(round(p.x / 2), -4, 4);",
+		"p.z -= 3 * round(p.z / 3);",
+		"p.y = abs(p.y - 1) + 1;",
+		"float sector = 6.28318530718 / 6.0;",
+		"p = pSaved;",
+	} {
+		if !strings.Contains(glsl, expected) {
+			t.Errorf("Expected %q in\n%v", expected, glsl)
+		}
+	}
+	if strings.Contains(glsl, "p.y -= ") {
+		t.Errorf("Expected no repetition on an axis without period")
+	}
+	if strings.Count(glsl, "{vec3 pSaved = p;") != 3 {
+		t.Errorf("Expected each domain node in its own block")
+	}
+}
+
+func TestDeformation2Glsl(t *testing.T) {
+	post := sdf.Sphere{Radius: 1}
+	scene := sdf.Union{
+		sdf.Twist{Axis: vec3.New(0, 2, 0), Rate: 1, Sub: sdf.Sphere{Radius: 1}},
+		sdf.Bend{Rate: 0.5, Sub: sdf.Sphere{Radius: 1}},
+		sdf.Taper{Rate: 0.25, Sub: sdf.Sphere{Radius: 1}},
+		sdf.Round{Radius: 0.2, Sub: sdf.Sphere{Radius: 1}},
+		sdf.Onion{Thickness: 0.1, Sub: sdf.Sphere{Radius: 1}},
+	}
+	glsl, err := SDF2GLSL(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"vec3 axis = vec3(0, 1, 0);",
+		"float a = -1 * along;",
+		"float a = 0.5 * q.x;",
+		"/ max(0.1, 1.0 + 0.25 * (p.y - 0));",
+		"p = pSaved; d -= 0.2;}",
+		"p = pSaved; d = abs(d) - 0.1;}",
+		fmt.Sprintf("d = d / %v;", sdf.Lipschitz(scene)),
+	} {
+		if !strings.Contains(glsl, expected) {
+			t.Errorf("Expected %q in\n%v", expected, glsl)
+		}
+	}
+	exact, err := SDF2GLSL(sdf.Round{Radius: 0.2, Sub: post})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(exact, "d = d /") {
+		t.Errorf("Expected no scaling for exact distances")
+	}
+	_, err = SDF2GLSL(sdf.Displace{Func: func(vec3.Vec3) float32 { return 0 }, Sub: post})
+	if _, ok := err.(UnsupportedSdfError); !ok {
+		t.Errorf("Expected displacement to be unsupported, got %v", err)
+	}
+}
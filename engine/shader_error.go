@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ShaderError is a failed shader compile or link. Error shows the messages of
+// the info log next to the lines of the source they refer to.
+type ShaderError struct {
+	// Stage is "vertex", "fragment" or "link".
+	Stage  string
+	Log    string
+	Source string
+}
+
+// shaderLogLine matches the line numbers of the common driver formats:
+// "0:12(5): error" (Mesa), "0(12) : error" (NVIDIA) and "ERROR: 0:12:" (AMD, Apple).
+var shaderLogLine = regexp.MustCompile(`^\s*(?:ERROR:|WARNING:)?\s*\d+[:(](\d+)\)?`)
+
+// shaderContextLines is the number of source lines shown around an error.
+const shaderContextLines = 2
+
+// shaderLogLineNumber returns the source line a message of the info log refers to, or 0.
+func shaderLogLineNumber(message string) int {
+	m := shaderLogLine.FindStringSubmatch(message)
+	if m == nil {
+		return 0
+	}
+	line, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+	return line
+}
+
+func (e *ShaderError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v shader failed", e.Stage)
+	lines := strings.Split(strings.TrimRight(e.Source, "\x00"), "\n")
+	for _, message := range strings.Split(strings.TrimSpace(strings.TrimRight(e.Log, "\x00")), "\n") {
+		message = strings.TrimSpace(message)
+		if message == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%v", message)
+		line := shaderLogLineNumber(message)
+		if line <= 0 || line > len(lines) || e.Source == "" {
+			continue
+		}
+		for i := max(1, line-shaderContextLines); i <= min(len(lines), line+shaderContextLines); i++ {
+			marker := " "
+			if i == line {
+				marker = ">"
+			}
+			fmt.Fprintf(&b, "\n  %v %4d | %v", marker, i, strings.TrimSpace(lines[i-1]))
+		}
+	}
+	return b.String()
+}
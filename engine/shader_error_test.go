@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShaderLogLineNumber(t *testing.T) {
+	tests := map[string]int{
+		"0:12(5): error: `foo' undeclared":                    12,
+		"0(7) : error C1008: undefined variable \"foo\"":      7,
+		"ERROR: 0:31: 'foo' : undeclared identifier":          31,
+		"WARNING: 0:3: extension not supported":               3,
+		"error: linking with uncompiled/unspecialized shader": 0,
+	}
+	for message, expected := range tests {
+		if line := shaderLogLineNumber(message); line != expected {
+			t.Errorf("%q: expected line %v, got %v", message, expected, line)
+		}
+	}
+}
+
+func TestShaderErrorContext(t *testing.T) {
+	source := "#version 410\nvoid main(){\n  float a = 1.0;\n  foo = a;\n}\n\x00"
+	err := &ShaderError{Stage: "fragment", Log: "0:4(3): error: `foo' undeclared\n\x00", Source: source}
+	msg := err.Error()
+	for _, expected := range []string{
+		"fragment shader failed",
+		"0:4(3): error: `foo' undeclared",
+		">    4 | foo = a;",
+		"     2 | void main(){",
+		"     5 | }",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected %q in:\n%v", expected, msg)
+		}
+	}
+	if strings.Contains(msg, "#version") {
+		t.Errorf("Expected only two lines of context:\n%v", msg)
+	}
+}
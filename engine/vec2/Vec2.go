@@ -1,5 +1,7 @@
 package vec2
 
+import "math"
+
 type Vec2 struct {
 	X, Y float32
 }
@@ -7,3 +9,94 @@ type Vec2 struct {
 func New(x, y float32) Vec2 {
 	return Vec2{X: x, Y: y}
 }
+
+func (v1 Vec2) Add(v2 Vec2) Vec2 {
+	return Vec2{v1.X + v2.X, v1.Y + v2.Y}
+}
+
+func (v1 Vec2) Sub(v2 Vec2) Vec2 {
+	return Vec2{v1.X - v2.X, v1.Y - v2.Y}
+}
+
+// Subtract is the same as Sub.
+func (v1 Vec2) Subtract(v2 Vec2) Vec2 {
+	return v1.Sub(v2)
+}
+
+// Mul multiplies component-wise.
+func (v1 Vec2) Mul(v2 Vec2) Vec2 {
+	return Vec2{v1.X * v2.X, v1.Y * v2.Y}
+}
+
+// Div divides component-wise.
+func (v1 Vec2) Div(v2 Vec2) Vec2 {
+	return Vec2{v1.X / v2.X, v1.Y / v2.Y}
+}
+
+// MultiplyScalar multiplies a vector by a scalar.
+func (v Vec2) MultiplyScalar(scalar float32) Vec2 {
+	return Vec2{v.X * scalar, v.Y * scalar}
+}
+
+func (v Vec2) Abs() Vec2 {
+	return Vec2{float32(math.Abs(float64(v.X))), float32(math.Abs(float64(v.Y)))}
+}
+
+// DotProduct calculates the dot product of two vectors.
+func (v1 Vec2) DotProduct(v2 Vec2) float32 {
+	return v1.X*v2.X + v1.Y*v2.Y
+}
+
+// Length calculates the magnitude of a vector.
+func (v Vec2) Length() float32 {
+	return float32(math.Sqrt(float64(v.X*v.X + v.Y*v.Y)))
+}
+
+// Distance is the length of the difference of two points.
+func (v1 Vec2) Distance(v2 Vec2) float32 {
+	return v1.Sub(v2).Length()
+}
+
+// Normalize normalizes the vector to have a magnitude of 1.
+func (v Vec2) Normalize() Vec2 {
+	magnitude := v.Length()
+	if magnitude == 0 {
+		return Vec2{} // Avoid division by zero
+	}
+	return v.MultiplyScalar(1 / magnitude)
+}
+
+// Min returns the smaller of each component.
+func (v1 Vec2) Min(v2 Vec2) Vec2 {
+	return Vec2{min(v1.X, v2.X), min(v1.Y, v2.Y)}
+}
+
+// Max returns the larger of each component.
+func (v1 Vec2) Max(v2 Vec2) Vec2 {
+	return Vec2{max(v1.X, v2.X), max(v1.Y, v2.Y)}
+}
+
+// Clamp limits each component to the range of lo and hi.
+func (v Vec2) Clamp(lo, hi Vec2) Vec2 {
+	return v.Max(lo).Min(hi)
+}
+
+// Lerp interpolates from v1 at t = 0 to v2 at t = 1.
+func (v1 Vec2) Lerp(v2 Vec2, t float32) Vec2 {
+	return Vec2{v1.X + (v2.X-v1.X)*t, v1.Y + (v2.Y-v1.Y)*t}
+}
+
+// Reflect mirrors the vector on a surface with a unit normal.
+func (v Vec2) Reflect(normal Vec2) Vec2 {
+	return v.Sub(normal.MultiplyScalar(2 * v.DotProduct(normal)))
+}
+
+// ApproxEqual returns true if each component differs by at most epsilon.
+func (v1 Vec2) ApproxEqual(v2 Vec2, epsilon float32) bool {
+	d := v1.Sub(v2).Abs()
+	return d.X <= epsilon && d.Y <= epsilon
+}
+
+func (v Vec2) YX() Vec2 {
+	return Vec2{v.Y, v.X}
+}
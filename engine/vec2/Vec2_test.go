@@ -0,0 +1,51 @@
+package vec2
+
+import "testing"
+
+func TestVec2(t *testing.T) {
+	a, b := New(1, -2), New(3, 4)
+	tests := []struct {
+		name     string
+		got      Vec2
+		expected Vec2
+	}{
+		{"Add", a.Add(b), New(4, 2)},
+		{"Sub", a.Sub(b), New(-2, -6)},
+		{"Subtract", a.Subtract(b), New(-2, -6)},
+		{"Mul", a.Mul(b), New(3, -8)},
+		{"Div", b.Div(New(2, 8)), New(1.5, 0.5)},
+		{"MultiplyScalar", a.MultiplyScalar(2), New(2, -4)},
+		{"Abs", a.Abs(), New(1, 2)},
+		{"Normalize", b.Normalize(), New(0.6, 0.8)},
+		{"NormalizeZero", Vec2{}.Normalize(), Vec2{}},
+		{"Min", a.Min(b), New(1, -2)},
+		{"Max", a.Max(b), New(3, 4)},
+		{"Clamp", New(-5, 5).Clamp(New(0, 0), New(1, 1)), New(0, 1)},
+		{"Lerp", a.Lerp(b, 0.5), New(2, 1)},
+		{"Reflect", New(1, -1).Reflect(New(0, 1)), New(1, 1)},
+		{"YX", a.YX(), New(-2, 1)},
+	}
+	for _, tc := range tests {
+		if !tc.got.ApproxEqual(tc.expected, 1e-6) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+
+	scalars := []struct {
+		name     string
+		got      float32
+		expected float32
+	}{
+		{"DotProduct", a.DotProduct(b), -5},
+		{"Length", b.Length(), 5},
+		{"Distance", New(1, 1).Distance(New(4, 5)), 5},
+	}
+	for _, tc := range scalars {
+		if tc.got != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+	if a.ApproxEqual(New(1, -1.9), 0.05) || !a.ApproxEqual(New(1, -1.99), 0.05) {
+		t.Errorf("Unexpected ApproxEqual")
+	}
+}
@@ -1,6 +1,10 @@
 package vec3
 
-import "math"
+import (
+	"math"
+
+	"github.com/supersdf-go/engine/vec2"
+)
 
 type Vec3 struct {
 	X float32
@@ -14,15 +18,36 @@ func New(x, y, z float32) Vec3 {
 	}
 }
 
+// Add returns the sum of two vectors.
+//
+// Deprecated: use v1.Add(v2).
 func Add(v1, v2 Vec3) Vec3 {
+	return v1.Add(v2)
+}
+
+func (v1 Vec3) Add(v2 Vec3) Vec3 {
 	return Vec3{v1.X + v2.X, v1.Y + v2.Y, v1.Z + v2.Z}
 }
 
-// Subtract returns the difference between two vectors.
-func (v1 Vec3) Subtract(v2 Vec3) Vec3 {
+func (v1 Vec3) Sub(v2 Vec3) Vec3 {
 	return Vec3{v1.X - v2.X, v1.Y - v2.Y, v1.Z - v2.Z}
 }
 
+// Subtract returns the difference between two vectors, the same as Sub.
+func (v1 Vec3) Subtract(v2 Vec3) Vec3 {
+	return v1.Sub(v2)
+}
+
+// Mul multiplies component-wise.
+func (v1 Vec3) Mul(v2 Vec3) Vec3 {
+	return Vec3{v1.X * v2.X, v1.Y * v2.Y, v1.Z * v2.Z}
+}
+
+// Div divides component-wise.
+func (v1 Vec3) Div(v2 Vec3) Vec3 {
+	return Vec3{v1.X / v2.X, v1.Y / v2.Y, v1.Z / v2.Z}
+}
+
 // MultiplyScalar multiplies a vector by a scalar.
 func (v Vec3) MultiplyScalar(scalar float32) Vec3 {
 	return Vec3{v.X * scalar, v.Y * scalar, v.Z * scalar}
@@ -51,6 +76,11 @@ func (v Vec3) Length() float32 {
 	return float32(math.Sqrt(float64(v.X*v.X + v.Y*v.Y + v.Z*v.Z)))
 }
 
+// Distance is the length of the difference of two points.
+func (v1 Vec3) Distance(v2 Vec3) float32 {
+	return v1.Sub(v2).Length()
+}
+
 // Normalize normalizes the vector to have a magnitude of 1.
 func (v Vec3) Normalize() Vec3 {
 	magnitude := v.Length()
@@ -59,3 +89,50 @@ func (v Vec3) Normalize() Vec3 {
 	}
 	return v.MultiplyScalar(1 / magnitude)
 }
+
+// Min returns the smaller of each component.
+func (v1 Vec3) Min(v2 Vec3) Vec3 {
+	return Vec3{min(v1.X, v2.X), min(v1.Y, v2.Y), min(v1.Z, v2.Z)}
+}
+
+// Max returns the larger of each component.
+func (v1 Vec3) Max(v2 Vec3) Vec3 {
+	return Vec3{max(v1.X, v2.X), max(v1.Y, v2.Y), max(v1.Z, v2.Z)}
+}
+
+// Clamp limits each component to the range of lo and hi.
+func (v Vec3) Clamp(lo, hi Vec3) Vec3 {
+	return v.Max(lo).Min(hi)
+}
+
+// Lerp interpolates from v1 at t = 0 to v2 at t = 1.
+func (v1 Vec3) Lerp(v2 Vec3, t float32) Vec3 {
+	return Vec3{v1.X + (v2.X-v1.X)*t, v1.Y + (v2.Y-v1.Y)*t, v1.Z + (v2.Z-v1.Z)*t}
+}
+
+// Reflect mirrors the vector on a surface with a unit normal.
+func (v Vec3) Reflect(normal Vec3) Vec3 {
+	return v.Sub(normal.MultiplyScalar(2 * v.DotProduct(normal)))
+}
+
+// ApproxEqual returns true if each component differs by at most epsilon.
+func (v1 Vec3) ApproxEqual(v2 Vec3, epsilon float32) bool {
+	d := v1.Sub(v2).Abs()
+	return d.X <= epsilon && d.Y <= epsilon && d.Z <= epsilon
+}
+
+func (v Vec3) XY() vec2.Vec2 {
+	return vec2.New(v.X, v.Y)
+}
+
+func (v Vec3) XZ() vec2.Vec2 {
+	return vec2.New(v.X, v.Z)
+}
+
+func (v Vec3) YZ() vec2.Vec2 {
+	return vec2.New(v.Y, v.Z)
+}
+
+func (v Vec3) ZYX() Vec3 {
+	return Vec3{v.Z, v.Y, v.X}
+}
@@ -0,0 +1,71 @@
+package vec3
+
+import (
+	"testing"
+
+	"github.com/supersdf-go/engine/vec2"
+)
+
+func TestVec3(t *testing.T) {
+	a, b := New(1, -2, 3), New(2, 3, 6)
+	tests := []struct {
+		name     string
+		got      Vec3
+		expected Vec3
+	}{
+		{"Add", a.Add(b), New(3, 1, 9)},
+		{"AddFunc", Add(a, b), New(3, 1, 9)},
+		{"Sub", a.Sub(b), New(-1, -5, -3)},
+		{"Subtract", a.Subtract(b), New(-1, -5, -3)},
+		{"Mul", a.Mul(b), New(2, -6, 18)},
+		{"Div", b.Div(New(2, 3, 4)), New(1, 1, 1.5)},
+		{"MultiplyScalar", a.MultiplyScalar(-1), New(-1, 2, -3)},
+		{"Abs", a.Abs(), New(1, 2, 3)},
+		{"CrossProduct", New(1, 0, 0).CrossProduct(New(0, 1, 0)), New(0, 0, 1)},
+		{"Normalize", b.Normalize(), New(2.0/7, 3.0/7, 6.0/7)},
+		{"NormalizeZero", Vec3{}.Normalize(), Vec3{}},
+		{"Min", a.Min(b), New(1, -2, 3)},
+		{"Max", a.Max(b), New(2, 3, 6)},
+		{"Clamp", New(-5, 0.5, 5).Clamp(New(0, 0, 0), New(1, 1, 1)), New(0, 0.5, 1)},
+		{"Lerp", a.Lerp(b, 0.25), New(1.25, -0.75, 3.75)},
+		{"Reflect", New(1, -1, 0).Reflect(New(0, 1, 0)), New(1, 1, 0)},
+		{"ZYX", a.ZYX(), New(3, -2, 1)},
+	}
+	for _, tc := range tests {
+		if !tc.got.ApproxEqual(tc.expected, 1e-6) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+
+	scalars := []struct {
+		name     string
+		got      float32
+		expected float32
+	}{
+		{"DotProduct", a.DotProduct(b), 14},
+		{"Length", b.Length(), 7},
+		{"Distance", a.Distance(a.Add(b)), 7},
+	}
+	for _, tc := range scalars {
+		if tc.got != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+
+	swizzles := []struct {
+		name          string
+		got, expected vec2.Vec2
+	}{
+		{"XY", a.XY(), vec2.New(1, -2)},
+		{"XZ", a.XZ(), vec2.New(1, 3)},
+		{"YZ", a.YZ(), vec2.New(-2, 3)},
+	}
+	for _, tc := range swizzles {
+		if tc.got != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+	if a.ApproxEqual(New(1, -2, 3.1), 0.05) || !a.ApproxEqual(New(1, -2, 3.01), 0.05) {
+		t.Errorf("Unexpected ApproxEqual")
+	}
+}
@@ -1,6 +1,11 @@
 package vec4
 
-import "math"
+import (
+	"math"
+
+	"github.com/supersdf-go/engine/vec2"
+	"github.com/supersdf-go/engine/vec3"
+)
 
 type Vec4 struct {
 	X float32
@@ -14,28 +19,58 @@ func New(x, y, z, w float32) Vec4 {
 		X: x, Y: y, Z: z, W: w,
 	}
 }
-func (v1 *Vec4) Add(v2 Vec4) Vec4 {
+
+// FromVec3 extends a vector with w, 1 for points and 0 for directions.
+func FromVec3(v vec3.Vec3, w float32) Vec4 {
+	return Vec4{v.X, v.Y, v.Z, w}
+}
+
+func (v1 Vec4) Add(v2 Vec4) Vec4 {
 	return Vec4{v1.X + v2.X, v1.Y + v2.Y, v1.Z + v2.Z, v1.W + v2.W}
 }
 
-// Subtract returns the difference between two vectors.
-func (v1 *Vec4) Subtract(v2 Vec4) Vec4 {
+func (v1 Vec4) Sub(v2 Vec4) Vec4 {
 	return Vec4{v1.X - v2.X, v1.Y - v2.Y, v1.Z - v2.Z, v1.W - v2.W}
 }
 
+// Subtract returns the difference between two vectors, the same as Sub.
+func (v1 Vec4) Subtract(v2 Vec4) Vec4 {
+	return v1.Sub(v2)
+}
+
+// Mul multiplies component-wise.
+func (v1 Vec4) Mul(v2 Vec4) Vec4 {
+	return Vec4{v1.X * v2.X, v1.Y * v2.Y, v1.Z * v2.Z, v1.W * v2.W}
+}
+
+// Div divides component-wise.
+func (v1 Vec4) Div(v2 Vec4) Vec4 {
+	return Vec4{v1.X / v2.X, v1.Y / v2.Y, v1.Z / v2.Z, v1.W / v2.W}
+}
+
 // MultiplyScalar multiplies a vector by a scalar.
-func (v *Vec4) MultiplyScalar(scalar float32) Vec4 {
+func (v Vec4) MultiplyScalar(scalar float32) Vec4 {
 	return Vec4{v.X * scalar, v.Y * scalar, v.Z * scalar, v.W * scalar}
 }
 
+func (v Vec4) Abs() Vec4 {
+	abs := func(f float32) float32 { return float32(math.Abs(float64(f))) }
+	return Vec4{abs(v.X), abs(v.Y), abs(v.Z), abs(v.W)}
+}
+
 // DotProduct calculates the dot product of two vectors.
-func (v1 *Vec4) DotProduct(v2 Vec4) float32 {
+func (v1 Vec4) DotProduct(v2 Vec4) float32 {
 	return v1.X*v2.X + v1.Y*v2.Y + v1.Z*v2.Z + v1.W*v2.W
 }
 
 // Length calculates the magnitude of a vector.
 func (v Vec4) Length() float32 {
-	return float32(math.Sqrt(float64(v.X*v.X + v.Y*v.Y + v.Z*v.Z)))
+	return float32(math.Sqrt(float64(v.X*v.X + v.Y*v.Y + v.Z*v.Z + v.W*v.W)))
+}
+
+// Distance is the length of the difference of two vectors.
+func (v1 Vec4) Distance(v2 Vec4) float32 {
+	return v1.Sub(v2).Length()
 }
 
 // Normalize normalizes the vector to have a magnitude of 1.
@@ -46,3 +81,42 @@ func (v Vec4) Normalize() Vec4 {
 	}
 	return v.MultiplyScalar(1 / magnitude)
 }
+
+// Min returns the smaller of each component.
+func (v1 Vec4) Min(v2 Vec4) Vec4 {
+	return Vec4{min(v1.X, v2.X), min(v1.Y, v2.Y), min(v1.Z, v2.Z), min(v1.W, v2.W)}
+}
+
+// Max returns the larger of each component.
+func (v1 Vec4) Max(v2 Vec4) Vec4 {
+	return Vec4{max(v1.X, v2.X), max(v1.Y, v2.Y), max(v1.Z, v2.Z), max(v1.W, v2.W)}
+}
+
+// Clamp limits each component to the range of lo and hi.
+func (v Vec4) Clamp(lo, hi Vec4) Vec4 {
+	return v.Max(lo).Min(hi)
+}
+
+// Lerp interpolates from v1 at t = 0 to v2 at t = 1.
+func (v1 Vec4) Lerp(v2 Vec4, t float32) Vec4 {
+	return v1.Add(v2.Sub(v1).MultiplyScalar(t))
+}
+
+// Reflect mirrors the vector on a surface with a unit normal.
+func (v Vec4) Reflect(normal Vec4) Vec4 {
+	return v.Sub(normal.MultiplyScalar(2 * v.DotProduct(normal)))
+}
+
+// ApproxEqual returns true if each component differs by at most epsilon.
+func (v1 Vec4) ApproxEqual(v2 Vec4, epsilon float32) bool {
+	d := v1.Sub(v2).Abs()
+	return d.X <= epsilon && d.Y <= epsilon && d.Z <= epsilon && d.W <= epsilon
+}
+
+func (v Vec4) XYZ() vec3.Vec3 {
+	return vec3.New(v.X, v.Y, v.Z)
+}
+
+func (v Vec4) XY() vec2.Vec2 {
+	return vec2.New(v.X, v.Y)
+}
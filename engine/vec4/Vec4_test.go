@@ -0,0 +1,62 @@
+package vec4
+
+import (
+	"testing"
+
+	"github.com/supersdf-go/engine/vec2"
+	"github.com/supersdf-go/engine/vec3"
+)
+
+func TestVec4(t *testing.T) {
+	a, b := New(1, -2, 3, -4), New(2, 4, 4, 8)
+	tests := []struct {
+		name     string
+		got      Vec4
+		expected Vec4
+	}{
+		{"Add", a.Add(b), New(3, 2, 7, 4)},
+		{"Sub", a.Sub(b), New(-1, -6, -1, -12)},
+		{"Subtract", a.Subtract(b), New(-1, -6, -1, -12)},
+		{"Mul", a.Mul(b), New(2, -8, 12, -32)},
+		{"Div", b.Div(New(2, 2, 2, 2)), New(1, 2, 2, 4)},
+		{"MultiplyScalar", a.MultiplyScalar(2), New(2, -4, 6, -8)},
+		{"Abs", a.Abs(), New(1, 2, 3, 4)},
+		{"Normalize", New(0, 0, 0, 2).Normalize(), New(0, 0, 0, 1)},
+		{"NormalizeZero", Vec4{}.Normalize(), Vec4{}},
+		{"Min", a.Min(b), New(1, -2, 3, -4)},
+		{"Max", a.Max(b), New(2, 4, 4, 8)},
+		{"Clamp", New(-5, 0.5, 5, 1).Clamp(New(0, 0, 0, 0), New(1, 1, 1, 1)), New(0, 0.5, 1, 1)},
+		{"Lerp", a.Lerp(b, 0.5), New(1.5, 1, 3.5, 2)},
+		{"Reflect", New(1, -1, 0, 0).Reflect(New(0, 1, 0, 0)), New(1, 1, 0, 0)},
+		{"FromVec3", FromVec3(vec3.New(1, 2, 3), 1), New(1, 2, 3, 1)},
+	}
+	for _, tc := range tests {
+		if !tc.got.ApproxEqual(tc.expected, 1e-6) {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+
+	scalars := []struct {
+		name     string
+		got      float32
+		expected float32
+	}{
+		{"DotProduct", a.DotProduct(b), -26},
+		// W counts towards the length.
+		{"Length", b.Length(), 10},
+		{"LengthW", New(0, 0, 0, 3).Length(), 3},
+		{"Distance", a.Distance(a.Add(b)), 10},
+	}
+	for _, tc := range scalars {
+		if tc.got != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.name, tc.expected, tc.got)
+		}
+	}
+
+	if a.XYZ() != vec3.New(1, -2, 3) || a.XY() != vec2.New(1, -2) {
+		t.Errorf("Unexpected swizzles %v %v", a.XYZ(), a.XY())
+	}
+	if a.ApproxEqual(New(1, -2, 3, -4.1), 0.05) || !a.ApproxEqual(New(1, -2, 3, -4.01), 0.05) {
+		t.Errorf("Unexpected ApproxEqual")
+	}
+}
@@ -1,166 +1,282 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"io"
+	"image/png"
 	"math"
 	"os"
 
-	"github.com/go-gl/gl/v4.1-core/gl"
 	remotevm "github.com/rolfrm/remotevm"
 	. "github.com/supersdf-go/engine"
+	"github.com/supersdf-go/engine/console"
+	"github.com/supersdf-go/engine/quat"
+	"github.com/supersdf-go/engine/save"
+	sdf "github.com/supersdf-go/engine/sdf"
 	"github.com/supersdf-go/engine/vec2"
 	vec3 "github.com/supersdf-go/engine/vec3"
 	vec4 "github.com/supersdf-go/engine/vec4"
 )
 
-type Node struct {
-	polygon   *Polygon
-	subnodes  []Node
-	transform Mat4
-}
-
 type Game struct {
-	Entities []*Node
-	time     float32
-	fb       *Framebuffer
-	square   Polygon
-	location vec3.Vec3
+	Scene  *Node
+	time   float32
+	fb     *Framebuffer
+	fbErr  error
+	square Polygon
+	camera Camera
+	// previous is the camera before the last update, drawn interpolated towards camera.
+	previous Camera
+	fly      *FlyCamera
+	input    *InputMap
+	logStats bool
+	// physics moves the props.
+	physics *PhysicsWorld
 }
 
-func (n *Node) Draw(screen *Screen, tform Mat4) {
-	tform = tform.Multiply(n.transform)
-	if n.polygon != nil {
-		screen.Draw(*n.polygon, tform, n.polygon.Color)
-
+func (g *Game) Draw(screen *Screen, dt, alpha float32) {
+	if g.logStats {
+		g.logStats = false
+		fmt.Printf("Frame times: %v\n", screen.FrameStats())
+		fmt.Printf("Culling: %+v\n", screen.CullStats())
+	}
+	if g.Scene == nil {
+		return
 	}
-	if n.subnodes != nil {
-		for _, node := range n.subnodes {
-			node.Draw(screen, tform)
+	camera := g.previous.Lerp(g.camera, alpha)
+	if g.fb == nil && g.fbErr == nil {
+		g.fb, g.fbErr = screen.NewFramebuffer(64, 64)
+		if g.fbErr != nil {
+			fmt.Fprintf(os.Stderr, "Drawing without a framebuffer: %v\n", g.fbErr)
 		}
 	}
-}
-
-func (g *Game) Draw(screen *Screen) {
-	proj := PerspectiveMatrix(1.2, 1.0, 1, 1000.0)
-
-	screen.SetCamera(proj, g.location, vec3.New(0, 1, 0), vec3.New(1, 0, 0))
-	g.fb.Bind()
-	gl.Viewport(0, 0, 64, 64)
-	gl.Clear(gl.COLOR_BUFFER_BIT)
-	for _, e := range g.Entities {
-		e.Draw(screen, Mat4Identity())
+	if g.fb == nil {
+		camera.Apply(screen, float32(screen.ScreenWidth)/float32(screen.ScreenHeight))
+		g.Scene.Draw(screen)
+		return
 	}
+	camera.Apply(screen, 1.0)
+	screen.BindFramebuffer(g.fb)
+	screen.Viewport(0, 0, 64, 64)
+	screen.Clear()
+	g.Scene.Draw(screen)
 
-	g.fb.Unbind()
+	screen.BindFramebuffer(nil)
 	screen.SetCamera(Mat4Identity(), vec3.New(0, 0, 0), vec3.New(0, 1, 0), vec3.New(1, 0, 0))
 
-	gl.Viewport(0, 0, int32(screen.ScreenWidth), int32(screen.ScreenHeight))
+	screen.ResetViewport()
 
 	screen.DrawTextured(g.square, Mat4Identity(), g.fb.Texture)
 }
 
+func (g *Game) SceneFile() string {
+	return "./scene.sdf"
+}
+
+func (g *Game) ConsoleAddress() (string, string) {
+	return "tcp", "localhost:4243"
+}
+
+func (g *Game) SetupConsole(handlers *console.Handlers) {
+	handlers.SetCamera = func(position vec3.Vec3) error {
+		g.camera.Position = position
+		return nil
+	}
+}
+
 func (g *Game) Layout(width int, height int) (int, int) {
 	return width, height
 }
 
-func (g *Game) Update(eventManager *EventManager) {
-	//eventManager.ReadKeyEvents()
-	g.time += 0.016
-	if len(g.Entities) == 0 {
-
-		p0 := Polygon{Color: vec4.New(1.0, 1.0, 1.0, 1.0)}
-		p0.Load3DUv([]vec3.Vec3{
-			vec3.New(-1, -1, 0),
-			vec3.New(1, -1, 0),
-			vec3.New(-1, 1, 0),
-			vec3.New(1, -1, 0),
-			vec3.New(1, 1, 0),
-			vec3.New(-1, 1, 0),
-		}, []vec2.Vec2{
-			vec2.New(0, 0),
-			vec2.New(1, 0),
-			vec2.New(0, 1),
-			vec2.New(1, 0),
-			vec2.New(1, 1),
-			vec2.New(0, 1),
-		})
-		g.square = p0
-
-		p1 := Polygon{Color: vec4.New(1.0, 1.0, 1.0, 1.0)}
-		points := []vec3.Vec3{
-			vec3.New(-1, -1, 1),
-			vec3.New(1, -1, 1),
-			vec3.New(-1, 1, 1),
-			vec3.New(1, -1, 1),
-			vec3.New(1, 1, 1),
-			vec3.New(-1, 1, 1)}
-		outPoints := []vec3.Vec3{}
-		for i := 0; i < 4; i++ {
-			rx := RotationMatrix(math.Pi/2*float32(i), vec3.New(1, 0, 0))
-			outPoints = append(outPoints, rx.ApplyN(points)...)
-		}
-		for i := 1; i < 4; i += 2 {
-			rx := RotationMatrix(math.Pi/2*float32(i), vec3.New(0, 1, 0))
-			outPoints = append(outPoints, rx.ApplyN(points)...)
-		}
+// build creates the scene graph, before the save is loaded into it.
+func (g *Game) build() {
+	p0 := Polygon{Color: vec4.New(1.0, 1.0, 1.0, 1.0)}
+	p0.Load3DUv([]vec3.Vec3{
+		vec3.New(-1, -1, 0),
+		vec3.New(1, -1, 0),
+		vec3.New(-1, 1, 0),
+		vec3.New(1, -1, 0),
+		vec3.New(1, 1, 0),
+		vec3.New(-1, 1, 0),
+	}, []vec2.Vec2{
+		vec2.New(0, 0),
+		vec2.New(1, 0),
+		vec2.New(0, 1),
+		vec2.New(1, 0),
+		vec2.New(1, 1),
+		vec2.New(0, 1),
+	})
+	g.square = p0
+
+	p1 := Polygon{Color: vec4.New(1.0, 1.0, 1.0, 1.0)}
+	points := []vec3.Vec3{
+		vec3.New(-1, -1, 1),
+		vec3.New(1, -1, 1),
+		vec3.New(-1, 1, 1),
+		vec3.New(1, -1, 1),
+		vec3.New(1, 1, 1),
+		vec3.New(-1, 1, 1)}
+	outPoints := []vec3.Vec3{}
+	for i := 0; i < 4; i++ {
+		rx := RotationMatrix(math.Pi/2*float32(i), vec3.New(1, 0, 0))
+		outPoints = append(outPoints, rx.ApplyN(points)...)
+	}
+	for i := 1; i < 4; i += 2 {
+		rx := RotationMatrix(math.Pi/2*float32(i), vec3.New(0, 1, 0))
+		outPoints = append(outPoints, rx.ApplyN(points)...)
+	}
 
-		p1.Load3D(outPoints)
-
-		e1 := Node{polygon: &p1, transform: Mat4Scale(2, 2, 2)}
-		e2 := Node{polygon: &p1, transform: Mat4Scale(2, 2, 2).Multiply(Mat4Translation(2, 0, 0))}
-
-		/*p2 := Polygon{Color: vec4.New(1.0, 0.0, 0.0, 1.0)}
-		p2.Load3D([]vec3.Vec3{
-			vec3.New(-1, -1, 0),
-			vec3.New(0.0, 1, 0),
-			vec3.New(1, -1, 0),
-		})
-		e2 := Node{polygon: &p2, transform: Mat4Scale(0.9, 0.9, 0.9)}
-		*/
-		g.Entities = []*Node{&e1, &e2}
-		fb, e := NewFramebuffer(64, 64)
-		if e != nil {
-			panic(e)
+	p1.Load3D(outPoints)
+
+	g.Scene = NewNode("root")
+	e1 := NewNode("cube1", &p1)
+	e1.SetTransform(Mat4Scale(2, 2, 2))
+	e2 := NewNode("cube2", &p1)
+	e2.SetTransform(Mat4Scale(2, 2, 2).Multiply(Mat4Translation(2, 0, 0)))
+
+	/*p2 := Polygon{Color: vec4.New(1.0, 0.0, 0.0, 1.0)}
+	p2.Load3D([]vec3.Vec3{
+		vec3.New(-1, -1, 0),
+		vec3.New(0.0, 1, 0),
+		vec3.New(1, -1, 0),
+	})
+	e2 := Node{polygon: &p2, transform: Mat4Scale(0.9, 0.9, 0.9)}
+	*/
+	g.Scene.AddChild(e1)
+	g.Scene.AddChild(e2)
+
+	// a box falling onto the floor, moved by the physics world on the root.
+	floor := &SdfComponent{Sdf: sdf.Cube{Center: vec3.New(0, -5, 0), HalfSize: vec3.New(20, 1, 20)}}
+	g.Scene.Components = append(g.Scene.Components, floor)
+	prop := NewNode("prop")
+	mesh := NewNode("prop-mesh", &p1)
+	mesh.SetTransform(Mat4Scale(0.5, 0.5, 0.5))
+	prop.AddChild(mesh)
+	g.Scene.AddChild(prop)
+	body := NewBoxBody(vec3.New(-6, 4, -4), vec3.New(0.5, 0.5, 0.5), 1)
+	body.Orientation = quat.FromAxisAngle(vec3.New(1, 0, 1), 0.5)
+	body.Node = prop
+	g.physics = NewPhysicsWorld(nil)
+	g.physics.Add(body)
+	g.Scene.Components = append(g.Scene.Components, g.physics)
+}
+
+func (g *Game) Update(eventManager *EventManager, dt float32) {
+	g.input.Events = eventManager
+	g.time += dt
+	g.previous = g.camera
+	g.fly.Update(g.input, dt)
+	if g.input.Pressed("LogStats") {
+		g.logStats = true
+	}
+	for _, pick := range g.input.Picks("Pick") {
+		if pick.OK {
+			fmt.Printf("Picked %T at %v\n", pick.Hit.Leaf, pick.Hit.Point)
 		}
-		g.fb = fb
 	}
+	g.Scene.Update(dt)
 }
 
-func main() {
-	game := Game{}
+const saveFile = "./save.bin"
+
+// newInputMap creates the default bindings. Events is set on the first update.
+func newInputMap() *InputMap {
+	input := NewInputMap(nil)
+	BindCameraDefaults(input)
+	input.BindButton("LogStats", KeyBinding(KeyF3))
+	input.BindButton("Pick", MouseButtonBinding(MouseButtonLeft))
+	return input
+}
 
-	commands := []remotevm.Command{
+func newSaveSystem(game *Game) *save.System {
+	saves := save.New(1)
+	saves.Register(save.Vec3Provider{Name: "camera-location", Value: &game.camera.Position})
+	saves.Register(game.input.SaveProvider("input-bindings"))
+	saves.Register(game.Scene.SaveProvider("entities"))
+	saves.Register(game.physics.SaveProvider("bodies"))
+	// the SDF scene is not saved, it is loaded from SceneFile and the nodes built in code.
+
+	// save files from before the save system only hold the location, with the coordinates reversed.
+	saves.AddMigration(save.Migration{Version: 0, Commands: []remotevm.Command{
 		{
 			Name:      "load-location",
 			Arguments: []remotevm.Type{remotevm.Type_F64, remotevm.Type_F64, remotevm.Type_F64},
-			Func: func(x, y, z float64) {
-				game.location = vec3.New(float32(x), float32(y), float32(z))
-				fmt.Printf("Loaded location: %v\n ", game.location)
+			Func: func(z, y, x float64) {
+				game.camera.Position = vec3.New(float32(x), float32(y), float32(z))
 			},
 		},
-	}
+	}})
+	return saves
+}
 
-	file, err := os.Open("./save.bin")
-	if err == nil {
-		remotevm.EvalStream(commands, file, io.Discard)
-	} else {
-		panic(err.Error())
-	}
+var headless = flag.Int("headless", 0, "render this many frames without a window and write them as PNG files")
 
-	RunApp(&game)
-	file, err = os.OpenFile("./save.bin", os.O_TRUNC|os.O_CREATE|os.O_WRONLY, os.ModeAppend|os.ModePerm)
+// writeFrames renders frames with the software backend to frame-N.png.
+func writeFrames(game *Game, frames int) error {
+	images, err := RunHeadless(game, frames)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	for i, img := range images {
+		file, err := os.Create(fmt.Sprintf("frame-%03d.png", i))
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(file, img); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const configFile = "./app.json"
+
+// loadConfig reads the app config from app.json, SUPERSDF_ variables and flags, in increasing priority.
+func loadConfig() AppConfig {
+	config := DefaultAppConfig()
+	if err := config.LoadFile(configFile); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Unable to load %v: %v\n", configFile, err)
 	}
-	codeStr := remotevm.CodeStream{Stream: file}
+	if err := config.LoadEnv("SUPERSDF_"); err != nil {
+		fmt.Printf("Invalid environment: %v\n", err)
+	}
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	return config
+}
 
-	codeStr.Write(remotevm.Op_Ld, float64(game.location.X),
-		remotevm.Op_Ld, float64(game.location.Y),
-		remotevm.Op_Ld, float64(game.location.Z),
-		remotevm.Op_Call, byte(0))
-	s, e := file.Stat()
-	fmt.Printf("ending... %v %v", s.Size(), e)
-	file.Close()
+func main() {
+	config := loadConfig()
+	game := Game{camera: NewCamera(vec3.New(0, 0, 0)), input: newInputMap()}
+	game.fly = NewFlyCamera(&game.camera)
+	game.build()
+	saves := newSaveSystem(&game)
+	if err := saves.Load(saveFile); err != nil {
+		fmt.Printf("Unable to load %v: %v\n", saveFile, err)
+	}
+	fmt.Printf("Loaded location: %v\n", game.camera.Position)
+	game.previous = game.camera
 
+	if *headless > 0 {
+		if err := writeFrames(&game, *headless); err != nil {
+			fmt.Fprintf(os.Stderr, "Headless run failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	runErr := RunAppWithConfig(&game, config)
+	if runErr != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", runErr)
+	}
+	if err := saves.Save(saveFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to save %v: %v\n", saveFile, err)
+		os.Exit(1)
+	}
+	if runErr != nil {
+		os.Exit(1)
+	}
 }